@@ -56,6 +56,30 @@ func main() {
 	announcementService := services.NewAnnouncementService(notidatabase)
 	announcementHandler := handlers.NewAnnouncementHandler(announcementService)
 
+	collectionService := services.NewCollectionService(notidatabase)
+	collectionHandler := handlers.NewCollectionHandler(collectionService)
+
+	planService := services.NewPlanService(notidatabase, collectionService)
+	planHandler := handlers.NewPlanHandler(planService)
+
+	ledgerService := services.NewLedgerService(notidatabase)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+
+	invoiceService := services.NewInvoiceService(notidatabase)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
+
+	webhookService := services.NewWebhookService(notidatabase)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+
+	// Start background jobs
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go paymentService.StartReconciler(jobCtx, envDuration("RECONCILE_INTERVAL", 15*time.Minute), envDuration("RECONCILE_MIN_AGE", 30*time.Minute))
+	go paymentService.StartExpirySweeper(jobCtx, envDuration("EXPIRY_SWEEP_INTERVAL", 5*time.Minute))
+	go planService.StartPlanScheduler(jobCtx, envDuration("PLAN_SCHEDULER_INTERVAL", time.Hour))
+	go paymentService.StartSettlementScheduler(jobCtx, envDuration("SETTLEMENT_INTERVAL", time.Hour))
+	go webhookService.StartWebhookDispatcher(jobCtx, envDuration("WEBHOOK_DISPATCH_INTERVAL", 15*time.Second))
+
 	// Set up router
 	router := mux.NewRouter()
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -65,6 +89,8 @@ func main() {
 	router.HandleFunc("/api/user", userHandler.CreateUser).Methods("POST")
 	router.HandleFunc("/api/user", userHandler.GetUsers).Methods("GET")
 	router.HandleFunc("/api/login", userHandler.LoginUserHandler).Methods("POST")
+	router.HandleFunc("/api/user/{userID}/payout", userHandler.UpdatePayout).Methods("PATCH")
+	router.HandleFunc("/api/user/{userID}/payout-schedule", userHandler.UpdatePayoutSchedule).Methods("PATCH")
 
 	router.HandleFunc("/api/announcement", announcementHandler.CreateAnnouncement).Methods("POST")
 	router.HandleFunc("/api/announcements", announcementHandler.GetAnnouncements).Methods("GET")
@@ -74,10 +100,78 @@ func main() {
 
 	router.HandleFunc("/api/payment", paymentHandler.CreatePayment).Methods("POST")
 	router.HandleFunc("/api/payments", paymentHandler.GetPayments).Methods("GET")
+	router.HandleFunc("/api/payments/search", paymentHandler.SearchPayments).Methods("GET")
+	router.HandleFunc("/api/payments/export", paymentHandler.ExportPayments).Methods("GET")
 	router.HandleFunc("/api/payment/webhook", paymentHandler.Webhook).Methods("POST")
 	router.HandleFunc("/api/updatepayment/{paymentID}", paymentHandler.UpdatePayment).Methods("PATCH", "PUT")
 	router.HandleFunc("/api/userid/{userID}/payments", paymentHandler.GetPaymentsByUserID).Methods("GET")
+	router.HandleFunc("/api/userid/{userID}/outstanding", paymentHandler.GetOutstandingTotal).Methods("GET")
 	router.HandleFunc("/api/payment/{paymentID}", paymentHandler.GetPaymentHandler).Methods("GET")
+	router.HandleFunc("/api/payment/{paymentID}/refund", paymentHandler.RefundPayment).Methods("POST")
+	router.HandleFunc("/api/payment/{paymentID}/refunds", paymentHandler.GetRefunds).Methods("GET")
+	router.HandleFunc("/api/payment/{paymentID}/receipt", paymentHandler.GetReceipt).Methods("GET")
+	router.HandleFunc("/api/payment/cash", paymentHandler.RecordCashPayment).Methods("POST")
+	router.HandleFunc("/api/payment/{paymentID}/proof", paymentHandler.UploadProof).Methods("POST")
+	router.HandleFunc("/api/payment/{paymentID}/proof", paymentHandler.GetProof).Methods("GET")
+	router.HandleFunc("/api/payment/{paymentID}/review", paymentHandler.ReviewOfflinePayment).Methods("POST")
+	router.HandleFunc("/api/payments/offline/pending", paymentHandler.GetOfflineQueue).Methods("GET")
+
+	router.HandleFunc("/api/collection", collectionHandler.CreateCollection).Methods("POST")
+	router.HandleFunc("/api/collections", collectionHandler.GetCollections).Methods("GET")
+	router.HandleFunc("/api/collection/{collectionID}/status", collectionHandler.GetCollectionStatus).Methods("GET")
+	router.HandleFunc("/api/collection/{collectionID}/export", collectionHandler.ExportCollectionRoster).Methods("GET")
+	router.HandleFunc("/api/collection/{collectionID}/rules", collectionHandler.SetFeeRules).Methods("PATCH")
+	router.HandleFunc("/api/me/obligations", collectionHandler.GetMyObligations).Methods("GET")
+	router.HandleFunc("/api/obligation/{obligationID}/pay", paymentHandler.PayObligation).Methods("POST")
+	router.HandleFunc("/api/obligation/{obligationID}/adjustments", paymentHandler.AdjustObligation).Methods("POST")
+	router.HandleFunc("/api/obligation/{obligationID}/adjustments", paymentHandler.GetAdjustments).Methods("GET")
+	router.HandleFunc("/api/obligation/{obligationID}/invoice", invoiceHandler.GetObligationInvoice).Methods("GET")
+	router.HandleFunc("/api/invoice/{invoiceID}", invoiceHandler.GetInvoice).Methods("GET")
+	router.HandleFunc("/api/invoice/{invoiceID}/void", invoiceHandler.VoidInvoice).Methods("POST")
+	router.HandleFunc("/api/me/invoices", invoiceHandler.GetMyInvoices).Methods("GET")
+
+	router.HandleFunc("/api/plan", planHandler.CreatePlan).Methods("POST")
+	router.HandleFunc("/api/plans", planHandler.GetPlans).Methods("GET")
+	router.HandleFunc("/api/plan/{planID}/members", planHandler.EnrollMembers).Methods("POST")
+	router.HandleFunc("/api/plan/{planID}/status", planHandler.UpdatePlanStatus).Methods("PATCH")
+
+	router.HandleFunc("/api/fees/policies", paymentHandler.GetFeePolicies).Methods("GET")
+	router.HandleFunc("/api/fees/policy", paymentHandler.SetFeePolicy).Methods("PUT")
+	router.HandleFunc("/api/fees/quote", paymentHandler.QuoteFees).Methods("GET")
+
+	router.HandleFunc("/api/ledger/balances", ledgerHandler.GetBalances).Methods("GET")
+	router.HandleFunc("/api/ledger/accounts/{account}", ledgerHandler.GetAccount).Methods("GET")
+	router.HandleFunc("/api/ledger/verify", ledgerHandler.VerifyLedger).Methods("GET")
+	router.HandleFunc("/api/me/balance", ledgerHandler.GetMyBalance).Methods("GET")
+	router.HandleFunc("/api/me/statement", paymentHandler.GetMyStatement).Methods("GET")
+	router.HandleFunc("/api/me/payments/events", paymentHandler.StreamPaymentEvents).Methods("GET")
+
+	router.HandleFunc("/api/settlements", paymentHandler.GetSettlements).Methods("GET")
+	router.HandleFunc("/api/settlements/run", paymentHandler.RunSettlements).Methods("POST")
+	router.HandleFunc("/api/settlement/{settlementID}", paymentHandler.GetSettlementReport).Methods("GET")
+
+	router.HandleFunc("/api/payouts/approval-policy", paymentHandler.GetPayoutApprovalPolicy).Methods("GET")
+	router.HandleFunc("/api/payouts/approval-policy", paymentHandler.SetPayoutApprovalPolicy).Methods("PUT")
+	router.HandleFunc("/api/payouts/approvals/pending", paymentHandler.GetPendingPayoutApprovals).Methods("GET")
+	router.HandleFunc("/api/payouts/approval/{approvalID}/review", paymentHandler.ReviewPayoutApproval).Methods("POST")
+
+	router.HandleFunc("/api/reports/summary", paymentHandler.GetReportSummary).Methods("GET")
+	router.HandleFunc("/api/reports/totals", paymentHandler.GetPeriodTotals).Methods("GET")
+	router.HandleFunc("/api/reports/collections", paymentHandler.GetCollectionCompletion).Methods("GET")
+	router.HandleFunc("/api/reports/delinquents", paymentHandler.GetTopDelinquents).Methods("GET")
+	router.HandleFunc("/api/reports/channels", paymentHandler.GetChannelBreakdown).Methods("GET")
+
+	router.HandleFunc("/api/webhooks", webhookHandler.CreateEndpoint).Methods("POST")
+	router.HandleFunc("/api/webhooks", webhookHandler.GetEndpoints).Methods("GET")
+	router.HandleFunc("/api/webhook/{webhookID}", webhookHandler.UpdateEndpoint).Methods("PATCH")
+	router.HandleFunc("/api/webhook/{webhookID}", webhookHandler.DeleteEndpoint).Methods("DELETE")
+	router.HandleFunc("/api/webhook/{webhookID}/rotate-secret", webhookHandler.RotateSecret).Methods("POST")
+	router.HandleFunc("/api/webhook/{webhookID}/ping", webhookHandler.PingEndpoint).Methods("POST")
+	router.HandleFunc("/api/webhook/{webhookID}/deliveries", webhookHandler.GetDeliveries).Methods("GET")
+	router.HandleFunc("/api/webhook/delivery/{deliveryID}/redeliver", webhookHandler.Redeliver).Methods("POST")
+
+	router.HandleFunc("/api/reconciliation/run", paymentHandler.RunReconciliation).Methods("POST")
+	router.HandleFunc("/api/reconciliation/reports", paymentHandler.GetReconciliationReports).Methods("GET")
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -93,3 +187,18 @@ func main() {
 	log.Printf("Server running on port %s", port)
 	log.Fatal(server.ListenAndServe())
 }
+
+// envDuration reads a Go duration (e.g. "15m") from the environment, falling
+// back to def when the variable is unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s=%q, using %s", name, v, def)
+		return def
+	}
+	return d
+}
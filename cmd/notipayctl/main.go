@@ -0,0 +1,124 @@
+// Command notipayctl runs NotiPay maintenance jobs on demand.
+//
+// Usage:
+//
+//	notipayctl reconcile [-min-age 30m]
+//	notipayctl expire
+//	notipayctl migrate-money
+//	notipayctl run-plans
+//	notipayctl verify-ledger
+//	notipayctl settle
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/db"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: notipayctl <command> [flags]\n\ncommands:\n")
+	fmt.Fprintf(os.Stderr, "  reconcile      compare non-terminal payments with Xendit and fix status drift\n")
+	fmt.Fprintf(os.Stderr, "  expire         mark PENDING payments past their expiry as EXPIRED\n")
+	fmt.Fprintf(os.Stderr, "  migrate-money  convert legacy float payment amounts to centavos\n")
+	fmt.Fprintf(os.Stderr, "  run-plans      generate collections for billing plan cycles that have started\n")
+	fmt.Fprintf(os.Stderr, "  verify-ledger  check that every ledger transaction balances\n")
+	fmt.Fprintf(os.Stderr, "  settle         disburse batched payouts whose settlement window has closed\n")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	// Load .env
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Warning: Error loading .env: %s", err)
+	}
+
+	uri := os.Getenv("MONGOURI")
+	if uri == "" {
+		log.Fatal("MONGOURI environment variable not set")
+	}
+	if err := db.Connect(uri); err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := db.Disconnect(ctx); err != nil {
+			log.Printf("Error disconnecting from MongoDB: %v", err)
+		}
+	}()
+
+	notidatabase := db.Client.Database("notipaydb")
+	paymentService := services.NewPaymentService(notidatabase)
+
+	switch os.Args[1] {
+	case "reconcile":
+		fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+		minAge := fs.Duration("min-age", 30*time.Minute, "only check payments created at least this long ago")
+		fs.Parse(os.Args[2:])
+
+		report, err := paymentService.Reconcile(context.Background(), *minAge)
+		if report != nil {
+			printJSON(report)
+		}
+		if err != nil {
+			log.Fatalf("Reconciliation failed: %v", err)
+		}
+	case "expire":
+		expired, err := paymentService.ExpireStalePayments(context.Background())
+		if err != nil {
+			log.Fatalf("Expiry sweep failed: %v", err)
+		}
+		printJSON(map[string]int{"expired": expired})
+	case "migrate-money":
+		migrated, err := paymentService.MigrateMoney(context.Background())
+		if err != nil {
+			log.Fatalf("Money migration failed: %v", err)
+		}
+		printJSON(map[string]int64{"migrated": migrated})
+	case "run-plans":
+		planService := services.NewPlanService(notidatabase, services.NewCollectionService(notidatabase))
+		generated, err := planService.RunDuePlans(context.Background())
+		if err != nil {
+			log.Fatalf("Billing plan run failed: %v", err)
+		}
+		printJSON(map[string]int{"generated": generated})
+	case "verify-ledger":
+		check, err := services.NewLedgerService(notidatabase).Verify(context.Background())
+		if err != nil {
+			log.Fatalf("Ledger verification failed: %v", err)
+		}
+		printJSON(check)
+		if !check.OK {
+			os.Exit(1)
+		}
+	case "settle":
+		settled, err := paymentService.RunSettlements(context.Background())
+		if err != nil {
+			log.Fatalf("Settlement run failed: %v", err)
+		}
+		printJSON(map[string]int{"settled": settled})
+	default:
+		usage()
+	}
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Failed to encode output: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// AdjustObligation handles POST /api/obligation/{obligationID}/adjustments
+func (h *PaymentHandler) AdjustObligation(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Type   string      `json:"type"`   // WAIVE, DISCOUNT or CREDIT
+		Amount json.Number `json:"amount"` // ignored for WAIVE
+		Reason string      `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, `{"error":"Reason is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	amount := models.NewMoney(0, "PHP")
+	if req.Amount != "" {
+		var err error
+		amount, err = models.ParseMoney(req.Amount.String(), "PHP")
+		if err != nil {
+			http.Error(w, `{"error":"Amount must be a positive amount with at most 2 decimals"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	obligationID := mux.Vars(r)["obligationID"]
+	adjustment, err := h.service.AdjustObligation(r.Context(), obligationID, req.Type, amount, req.Reason, claims["user_id"].(string))
+	if err != nil {
+		log.Printf("Failed to adjust obligation %s: %v", obligationID, err)
+		if strings.Contains(err.Error(), "obligation not found") {
+			http.Error(w, `{"error":"obligation not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to adjust obligation: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(adjustment); err != nil {
+		log.Printf("Failed to encode adjustment: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetAdjustments handles GET /api/obligation/{obligationID}/adjustments
+func (h *PaymentHandler) GetAdjustments(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	obligationID := mux.Vars(r)["obligationID"]
+	adjustments, err := h.service.GetAdjustments(r.Context(), obligationID)
+	if err != nil {
+		log.Printf("Failed to fetch adjustments for obligation %s: %v", obligationID, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch adjustments: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(adjustments); err != nil {
+		log.Printf("Failed to encode adjustments: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
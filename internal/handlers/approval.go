@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// GetPayoutApprovalPolicy handles GET /api/payouts/approval-policy
+func (h *PaymentHandler) GetPayoutApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	policy, err := h.service.GetPayoutApprovalPolicy(r.Context())
+	if err != nil {
+		log.Printf("Failed to fetch payout approval policy: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch payout approval policy: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		log.Printf("Failed to encode payout approval policy: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// SetPayoutApprovalPolicy handles PUT /api/payouts/approval-policy
+func (h *PaymentHandler) SetPayoutApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		AmountThreshold   json.Number `json:"amount_threshold"`   // 0 or omitted: no amount rule
+		NewPayoutHours    int         `json:"new_payout_hours"`   // 0: no new-number rule
+		RequiredApprovals int         `json:"required_approvals"` // 1 or 2
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	threshold := models.NewMoney(0, "PHP")
+	if req.AmountThreshold != "" {
+		var err error
+		if threshold, err = models.ParseMoney(req.AmountThreshold.String(), "PHP"); err != nil {
+			http.Error(w, `{"error":"Invalid amount_threshold"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	policy, err := h.service.SetPayoutApprovalPolicy(r.Context(), &models.PayoutApprovalPolicy{
+		AmountThreshold:   threshold,
+		NewPayoutHours:    req.NewPayoutHours,
+		RequiredApprovals: req.RequiredApprovals,
+	}, claims["user_id"].(string))
+	if err != nil {
+		log.Printf("Failed to set payout approval policy: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to set payout approval policy: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		log.Printf("Failed to encode payout approval policy: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetPendingPayoutApprovals handles GET /api/payouts/approvals/pending
+func (h *PaymentHandler) GetPendingPayoutApprovals(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	approvals, err := h.service.GetPendingPayoutApprovals(r.Context())
+	if err != nil {
+		log.Printf("Failed to fetch pending payout approvals: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch pending approvals: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(approvals); err != nil {
+		log.Printf("Failed to encode pending payout approvals: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// ReviewPayoutApproval handles POST /api/payouts/approval/{approvalID}/review
+func (h *PaymentHandler) ReviewPayoutApproval(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Decision string `json:"decision"` // APPROVE or REJECT
+		Note     string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	decision := strings.ToUpper(strings.TrimSpace(req.Decision))
+	if decision != "APPROVE" && decision != "REJECT" {
+		http.Error(w, `{"error":"decision must be APPROVE or REJECT"}`, http.StatusBadRequest)
+		return
+	}
+	if decision == "REJECT" && strings.TrimSpace(req.Note) == "" {
+		http.Error(w, `{"error":"A note is required when rejecting"}`, http.StatusBadRequest)
+		return
+	}
+
+	approvalID := mux.Vars(r)["approvalID"]
+	approval, err := h.service.ReviewPayoutApproval(r.Context(), approvalID, claims["user_id"].(string), decision == "APPROVE", req.Note)
+	if err != nil {
+		log.Printf("Failed to review payout approval %s: %v", approvalID, err)
+		switch {
+		case strings.Contains(err.Error(), "approval not found"):
+			http.Error(w, `{"error":"approval not found"}`, http.StatusNotFound)
+		case strings.Contains(err.Error(), "to themselves"):
+			http.Error(w, `{"error":"Admins cannot decide payouts to themselves"}`, http.StatusForbidden)
+		default:
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to review payout: %v"}`, err), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(approval); err != nil {
+		log.Printf("Failed to encode payout approval: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
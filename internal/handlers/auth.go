@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// requireUser verifies the bearer token on the request and returns its claims.
+// On failure it writes a 401 response and returns false.
+func requireUser(w http.ResponseWriter, r *http.Request) (jwt.MapClaims, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, `{"error":"Authorization header required"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		http.Error(w, `{"error":"Invalid token"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		http.Error(w, `{"error":"Invalid token claims"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	if _, ok := claims["user_id"].(string); !ok {
+		http.Error(w, `{"error":"Invalid user_id in token"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	return claims, true
+}
+
+// requireAdmin is requireUser for endpoints restricted to the "admin" role.
+// Non-admin callers get a 403 response.
+func requireAdmin(w http.ResponseWriter, r *http.Request) (jwt.MapClaims, bool) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if role, _ := claims["role"].(string); role != "admin" {
+		http.Error(w, `{"error":"Admin access required"}`, http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// CollectionHandler handles HTTP requests for collections and obligations
+type CollectionHandler struct {
+	service *services.CollectionService
+}
+
+// NewCollectionHandler creates a new CollectionHandler
+func NewCollectionHandler(service *services.CollectionService) *CollectionHandler {
+	return &CollectionHandler{service: service}
+}
+
+// CreateCollection handles POST /api/collection
+func (h *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Title       string           `json:"title"`
+		Description string           `json:"description"`
+		Amount      json.Number      `json:"amount"`
+		DueDate     string           `json:"due_date"` // RFC3339
+		PayeeID     string           `json:"payee_id"` // defaults to the creating admin
+		AllMembers  bool             `json:"all_members"`
+		MemberIDs   []string         `json:"member_ids"`
+		Penalty     *penaltyRequest  `json:"penalty"`  // optional late fee rule
+		Discount    *discountRequest `json:"discount"` // optional early-payment discount
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		http.Error(w, `{"error":"Title is required"}`, http.StatusBadRequest)
+		return
+	}
+	amount, err := models.ParseMoney(req.Amount.String(), "PHP")
+	if err != nil || !amount.IsPositive() {
+		http.Error(w, `{"error":"Amount must be a positive amount with at most 2 decimals"}`, http.StatusBadRequest)
+		return
+	}
+	dueDate, err := time.Parse(time.RFC3339, req.DueDate)
+	if err != nil {
+		http.Error(w, `{"error":"Invalid due_date format, must be RFC3339"}`, http.StatusBadRequest)
+		return
+	}
+	if !req.AllMembers && len(req.MemberIDs) == 0 {
+		http.Error(w, `{"error":"Either all_members or member_ids is required"}`, http.StatusBadRequest)
+		return
+	}
+	penalty, discount, err := parseFeeRules(req.Penalty, req.Discount)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.service.CreateCollection(r.Context(), &models.Collection{
+		Title:           req.Title,
+		Description:     req.Description,
+		Amount:          amount,
+		DueDate:         dueDate,
+		PayeeID:         req.PayeeID,
+		AllMembers:      req.AllMembers,
+		TargetMemberIDs: req.MemberIDs,
+		Penalty:         penalty,
+		Discount:        discount,
+		CreatedBy:       claims["user_id"].(string),
+	})
+	if err != nil {
+		log.Printf("Failed to create collection: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to create collection: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(collection); err != nil {
+		log.Printf("Failed to encode collection: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetCollections handles GET /api/collections
+func (h *CollectionHandler) GetCollections(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	collections, err := h.service.GetCollections(r.Context())
+	if err != nil {
+		log.Printf("Failed to fetch collections: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch collections: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(collections); err != nil {
+		log.Printf("Failed to encode collections: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetCollectionStatus handles GET /api/collection/{collectionID}/status
+func (h *CollectionHandler) GetCollectionStatus(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	collectionID := mux.Vars(r)["collectionID"]
+	status, err := h.service.GetCollectionStatus(r.Context(), collectionID)
+	if err != nil {
+		log.Printf("Failed to fetch status for collection %s: %v", collectionID, err)
+		if strings.Contains(err.Error(), "collection not found") {
+			http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch collection status: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("Failed to encode collection status: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetMyObligations handles GET /api/me/obligations
+func (h *CollectionHandler) GetMyObligations(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	userID := claims["user_id"].(string)
+	obligations, err := h.service.GetMemberObligations(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to fetch obligations for user %s: %v", userID, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch obligations: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(obligations); err != nil {
+		log.Printf("Failed to encode obligations: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
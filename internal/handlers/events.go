@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment so
+// proxies and mobile networks keep the connection open.
+const eventKeepAlive = 25 * time.Second
+
+// writeEvent writes one Server-Sent Event and flushes it to the client.
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, event models.PaymentEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: payment\nid: %s-%d\ndata: %s\n\n", event.PaymentID, event.UpdatedAt.UnixNano(), data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// StreamPaymentEvents handles GET /api/me/payments/events, a Server-Sent
+// Events stream of status changes to payments the caller pays or receives.
+// With ?payment_id= it follows that one payment, starting with its current
+// status so a change made before the stream opened is not missed.
+func (h *PaymentHandler) StreamPaymentEvents(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := claims["user_id"].(string)
+	paymentID := strings.TrimSpace(r.URL.Query().Get("payment_id"))
+
+	// Subscribe before reading the current status so nothing falls in between;
+	// a change caught by both is sent twice, which clients can tell by updated_at
+	events, unsubscribe := h.service.SubscribePaymentEvents(userID)
+	defer unsubscribe()
+
+	var current *models.Payment
+	if paymentID != "" {
+		payment, err := h.service.GetPaymentByID(r.Context(), paymentID)
+		if err != nil {
+			log.Printf("Failed to fetch payment %s for event stream: %v", paymentID, err)
+			if strings.Contains(err.Error(), "payment not found") {
+				http.Error(w, `{"error":"payment not found"}`, http.StatusNotFound)
+				return
+			}
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch payment: %v"}`, err), http.StatusBadRequest)
+			return
+		}
+		if payment.PayerID != userID && payment.PayeeID != userID {
+			http.Error(w, `{"error":"Unauthorized to follow this payment"}`, http.StatusForbidden)
+			return
+		}
+		current = payment
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to lift write deadline for event stream: %v", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	if current != nil {
+		if err := writeEvent(w, rc, models.NewPaymentEvent(current)); err != nil {
+			return
+		}
+	} else if err := rc.Flush(); err != nil {
+		log.Printf("Event stream for user %s cannot be flushed: %v", userID, err)
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			if paymentID != "" && event.PaymentID != paymentID {
+				continue
+			}
+			if err := writeEvent(w, rc, event); err != nil {
+				log.Printf("Event stream for user %s closed: %v", userID, err)
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprintf(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// extendWriteDeadline lets an export stream past the server's write timeout,
+// up to the time the export itself is allowed.
+func extendWriteDeadline(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(services.ExportTimeout)); err != nil {
+		log.Printf("Failed to extend write deadline for export: %v", err)
+	}
+}
+
+// ExportPayments handles GET /api/payments/export?format=csv|xlsx with the
+// same filters as /api/payments/search.
+func (h *PaymentHandler) ExportPayments(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := parsePaymentFilter(query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+	sheet, err := newSheetWriter(w, query.Get("format"), "payments-"+time.Now().In(manila).Format("20060102"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+	extendWriteDeadline(w)
+
+	// Headers are sent with the first row, so failures after it can only be logged
+	sheet.WriteRow("Payment ID", "Reference", "Created", "Payer", "Payer Email", "Payer ID", "Payee ID",
+		"Title", "Status", "Method", "Channel", "Amount", "Refunded", "Net Payout", "Charge ID", "Disbursement ID")
+	err = h.service.ExportPayments(r.Context(), filter, func(p *models.PaymentView) error {
+		net := p.Amount
+		if p.Fees != nil {
+			net = p.Fees.NetAmount
+		}
+		return sheet.WriteRow(p.ID, p.ReferenceID, p.CreatedAt, p.PayerName, p.PayerEmail, p.PayerID, p.PayeeID,
+			p.Title, p.Status, p.Method, p.ChannelCode, p.Amount, p.RefundedAmount, net, p.ChargeID, p.DisbursementID)
+	})
+	if err != nil {
+		log.Printf("Payment export failed: %v", err)
+		return
+	}
+	if err := sheet.Close(); err != nil {
+		log.Printf("Failed to finish payment export: %v", err)
+	}
+}
+
+// ExportCollectionRoster handles GET /api/collection/{collectionID}/export?format=csv|xlsx
+func (h *CollectionHandler) ExportCollectionRoster(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	collectionID := mux.Vars(r)["collectionID"]
+	status, err := h.service.GetCollectionStatus(r.Context(), collectionID)
+	if err != nil {
+		log.Printf("Failed to fetch roster for collection %s: %v", collectionID, err)
+		if strings.Contains(err.Error(), "collection not found") {
+			http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch collection status: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	sheet, err := newSheetWriter(w, r.URL.Query().Get("format"), "roster-"+collectionID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+	extendWriteDeadline(w)
+
+	sheet.WriteRow("Member", "Email", "Member ID", "Status", "Amount", "Penalties", "Discounts", "Adjustments", "Paid", "Balance", "Due Date", "Paid At")
+	for _, m := range status.Members {
+		err := sheet.WriteRow(m.FullName, m.Email, m.MemberID, m.Status, m.Amount, m.Penalties, m.Discounts,
+			m.Adjustments, m.AmountPaid, m.Balance, m.DueDate, m.PaidAt)
+		if err != nil {
+			log.Printf("Roster export for collection %s failed: %v", collectionID, err)
+			return
+		}
+	}
+	sheet.WriteRow()
+	sheet.WriteRow("Total", status.Total)
+	sheet.WriteRow("Paid", status.Paid)
+	sheet.WriteRow("Partially paid", status.Partial)
+	sheet.WriteRow("Unpaid", status.Unpaid)
+	sheet.WriteRow("Waived", status.Waived)
+	sheet.WriteRow("Collected", status.Collected)
+	if err := sheet.Close(); err != nil {
+		log.Printf("Failed to finish roster export for collection %s: %v", collectionID, err)
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// GetFeePolicies handles GET /api/fees/policies
+func (h *PaymentHandler) GetFeePolicies(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	policies, err := h.service.GetFeePolicies(r.Context())
+	if err != nil {
+		log.Printf("Failed to fetch fee policies: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch fee policies: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policies); err != nil {
+		log.Printf("Failed to encode fee policies: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// SetFeePolicy handles PUT /api/fees/policy
+func (h *PaymentHandler) SetFeePolicy(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PayeeID         string `json:"payee_id"`          // omit to set the default policy
+		Mode            string `json:"mode"`              // PAYER_PAYS, PAYEE_ABSORBS or PLATFORM_ABSORBS
+		PlatformRateBps int64  `json:"platform_rate_bps"` // 100 = 1%
+		Gateway         map[string]struct {
+			RateBps int64       `json:"rate_bps"`
+			Flat    json.Number `json:"flat"`
+		} `json:"gateway"` // keyed by method, e.g. "EWALLET"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	policy := &models.FeePolicy{
+		Mode:            req.Mode,
+		PlatformRateBps: req.PlatformRateBps,
+	}
+	if len(req.Gateway) > 0 {
+		policy.Gateway = make(map[string]models.FeeRate, len(req.Gateway))
+		for method, rate := range req.Gateway {
+			flat := models.NewMoney(0, "PHP")
+			if rate.Flat != "" {
+				var err error
+				if flat, err = models.ParseMoney(rate.Flat.String(), "PHP"); err != nil {
+					http.Error(w, fmt.Sprintf(`{"error":"Invalid flat fee for %s"}`, method), http.StatusBadRequest)
+					return
+				}
+			}
+			policy.Gateway[strings.ToUpper(method)] = models.FeeRate{RateBps: rate.RateBps, Flat: flat}
+		}
+	}
+
+	policy, err := h.service.SetFeePolicy(r.Context(), req.PayeeID, policy, claims["user_id"].(string))
+	if err != nil {
+		log.Printf("Failed to set fee policy: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to set fee policy: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		log.Printf("Failed to encode fee policy: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// QuoteFees handles GET /api/fees/quote?amount=150.00&method=EWALLET&payee_id=
+func (h *PaymentHandler) QuoteFees(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireUser(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	amount, err := models.ParseMoney(query.Get("amount"), "PHP")
+	if err != nil || !amount.IsPositive() {
+		http.Error(w, `{"error":"Amount must be a positive amount with at most 2 decimals"}`, http.StatusBadRequest)
+		return
+	}
+
+	fees, err := h.service.QuoteFees(r.Context(), query.Get("payee_id"), query.Get("method"), amount)
+	if err != nil {
+		log.Printf("Failed to quote fees: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to quote fees: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	if fees == nil {
+		zero := models.NewMoney(0, amount.Currency)
+		fees = &models.PaymentFees{ConvenienceFee: zero, GatewayFee: zero, PlatformFee: zero, NetAmount: amount}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(fees); err != nil {
+		log.Printf("Failed to encode fee quote: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
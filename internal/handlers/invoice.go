@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// InvoiceHandler handles HTTP requests for obligation invoices
+type InvoiceHandler struct {
+	service *services.InvoiceService
+}
+
+// NewInvoiceHandler creates a new InvoiceHandler
+func NewInvoiceHandler(service *services.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{service: service}
+}
+
+// invoiceError maps an invoice service error to a response.
+func invoiceError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "invalid"):
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusNotFound)
+	case strings.Contains(err.Error(), "does not belong"):
+		http.Error(w, `{"error":"Unauthorized to view this invoice"}`, http.StatusForbidden)
+	case strings.Contains(err.Error(), "waived"), strings.Contains(err.Error(), "retry"):
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch invoice: %v"}`, err), http.StatusInternalServerError)
+	}
+}
+
+// writeInvoice sends an invoice as JSON, or as a PDF or printable HTML page
+// with ?format=pdf or ?format=html.
+func (h *InvoiceHandler) writeInvoice(w http.ResponseWriter, r *http.Request, invoice *models.Invoice) {
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(invoice); err != nil {
+			log.Printf("Failed to encode invoice: %v", err)
+			http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+		}
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", invoice.Number+".pdf"))
+		if _, err := w.Write(h.service.RenderInvoicePDF(r.Context(), invoice)); err != nil {
+			log.Printf("Failed to write invoice %s: %v", invoice.Number, err)
+		}
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := h.service.RenderInvoiceHTML(r.Context(), w, invoice); err != nil {
+			log.Printf("Failed to render invoice %s: %v", invoice.Number, err)
+		}
+	default:
+		http.Error(w, `{"error":"format must be json, pdf or html"}`, http.StatusBadRequest)
+	}
+}
+
+// GetObligationInvoice handles GET /api/obligation/{obligationID}/invoice
+func (h *InvoiceHandler) GetObligationInvoice(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	role, _ := claims["role"].(string)
+
+	obligationID := mux.Vars(r)["obligationID"]
+	invoice, err := h.service.GetObligationInvoice(r.Context(), obligationID, claims["user_id"].(string), role == "admin")
+	if err != nil {
+		log.Printf("Failed to fetch invoice for obligation %s: %v", obligationID, err)
+		invoiceError(w, err)
+		return
+	}
+	h.writeInvoice(w, r, invoice)
+}
+
+// GetInvoice handles GET /api/invoice/{invoiceID}
+func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	role, _ := claims["role"].(string)
+
+	invoiceID := mux.Vars(r)["invoiceID"]
+	invoice, err := h.service.GetInvoice(r.Context(), invoiceID, claims["user_id"].(string), role == "admin")
+	if err != nil {
+		log.Printf("Failed to fetch invoice %s: %v", invoiceID, err)
+		invoiceError(w, err)
+		return
+	}
+	h.writeInvoice(w, r, invoice)
+}
+
+// GetMyInvoices handles GET /api/me/invoices
+func (h *InvoiceHandler) GetMyInvoices(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	userID := claims["user_id"].(string)
+	invoices, err := h.service.GetMemberInvoices(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to fetch invoices for user %s: %v", userID, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch invoices: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(invoices); err != nil {
+		log.Printf("Failed to encode invoices: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// VoidInvoice handles POST /api/invoice/{invoiceID}/void
+func (h *InvoiceHandler) VoidInvoice(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	invoiceID := mux.Vars(r)["invoiceID"]
+	invoice, err := h.service.VoidInvoice(r.Context(), invoiceID, claims["user_id"].(string), req.Reason)
+	if err != nil {
+		log.Printf("Failed to void invoice %s: %v", invoiceID, err)
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusNotFound)
+		case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		default:
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to void invoice: %v"}`, err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(invoice); err != nil {
+		log.Printf("Failed to encode invoice: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
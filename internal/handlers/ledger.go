@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// LedgerHandler handles HTTP requests for ledger balances
+type LedgerHandler struct {
+	service *services.LedgerService
+}
+
+// NewLedgerHandler creates a new LedgerHandler
+func NewLedgerHandler(service *services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{service: service}
+}
+
+// GetBalances handles GET /api/ledger/balances?prefix=payable:payee:
+func (h *LedgerHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	balances, err := h.service.GetBalances(r.Context(), r.URL.Query().Get("prefix"))
+	if err != nil {
+		log.Printf("Failed to fetch ledger balances: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch balances: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(balances); err != nil {
+		log.Printf("Failed to encode ledger balances: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetAccount handles GET /api/ledger/accounts/{account}?limit=100
+func (h *LedgerHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	account := mux.Vars(r)["account"]
+	var limit int64
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, `{"error":"limit must be a positive integer"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	balance, err := h.service.GetBalance(r.Context(), account)
+	if err != nil {
+		log.Printf("Failed to fetch balance of %s: %v", account, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch balance: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	transactions, err := h.service.GetTransactions(r.Context(), account, limit)
+	if err != nil {
+		log.Printf("Failed to fetch transactions of %s: %v", account, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch transactions: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := map[string]interface{}{"balance": balance, "transactions": transactions}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode ledger account: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// VerifyLedger handles GET /api/ledger/verify
+func (h *LedgerHandler) VerifyLedger(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	check, err := h.service.Verify(r.Context())
+	if err != nil {
+		log.Printf("Failed to verify ledger: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to verify ledger: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(check); err != nil {
+		log.Printf("Failed to encode ledger check: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetMyBalance handles GET /api/me/balance
+func (h *LedgerHandler) GetMyBalance(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	balance, err := h.service.GetMemberBalance(r.Context(), claims["user_id"].(string))
+	if err != nil {
+		log.Printf("Failed to fetch member balance: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch balance: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(balance); err != nil {
+		log.Printf("Failed to encode member balance: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// PayObligation handles POST /api/obligation/{obligationID}/pay
+func (h *PaymentHandler) PayObligation(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	obligationID := mux.Vars(r)["obligationID"]
+	var req struct {
+		Amount       json.Number `json:"amount"` // omit to pay the full balance
+		Method       string      `json:"method"`
+		ChannelCode  string      `json:"channel_code"`
+		MobileNumber string      `json:"mobile_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if strings.EqualFold(req.Method, models.MethodCash) {
+		http.Error(w, `{"error":"Cash payments are recorded by an admin"}`, http.StatusBadRequest)
+		return
+	}
+
+	amount := models.NewMoney(0, "PHP")
+	if req.Amount != "" {
+		var err error
+		amount, err = models.ParseMoney(req.Amount.String(), "PHP")
+		if err != nil || !amount.IsPositive() {
+			http.Error(w, `{"error":"Amount must be a positive amount with at most 2 decimals"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	payment, err := h.service.PayObligation(r.Context(), obligationID, claims["user_id"].(string), services.PaymentRequest{
+		Amount:       amount,
+		Method:       req.Method,
+		ChannelCode:  req.ChannelCode,
+		MobileNumber: req.MobileNumber,
+	})
+	if err != nil {
+		log.Printf("Failed to pay obligation %s: %v", obligationID, err)
+		switch {
+		case strings.Contains(err.Error(), "obligation not found"):
+			http.Error(w, `{"error":"obligation not found"}`, http.StatusNotFound)
+		case strings.Contains(err.Error(), "does not belong"):
+			http.Error(w, `{"error":"Unauthorized to pay this obligation"}`, http.StatusForbidden)
+		case strings.Contains(err.Error(), "being started"):
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusConflict)
+		default:
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to create payment: %v"}`, err), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(payment); err != nil {
+		log.Printf("Failed to encode payment: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// maxProofSize caps proof-of-payment uploads at 5 MB.
+const maxProofSize = 5 << 20
+
+// proofContentTypes are the receipt formats accepted as proof of payment.
+var proofContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// RecordCashPayment handles POST /api/payment/cash
+func (h *PaymentHandler) RecordCashPayment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PayerID      string      `json:"payer_id"`
+		PayeeID      string      `json:"payee_id"`
+		Amount       json.Number `json:"amount"` // omit with obligation_id to pay the full balance
+		Title        string      `json:"title"`
+		Description  string      `json:"description"`
+		ObligationID string      `json:"obligation_id"`
+		Approve      bool        `json:"approve"` // approve right away instead of queueing for review
+		Note         string      `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if req.PayerID == "" {
+		http.Error(w, `{"error":"payer_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	amount := models.NewMoney(0, "PHP")
+	if req.Amount != "" {
+		var err error
+		amount, err = models.ParseMoney(req.Amount.String(), "PHP")
+		if err != nil || !amount.IsPositive() {
+			http.Error(w, `{"error":"Amount must be a positive amount with at most 2 decimals"}`, http.StatusBadRequest)
+			return
+		}
+	} else if req.ObligationID == "" {
+		http.Error(w, `{"error":"Amount is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	preq := services.PaymentRequest{
+		PayerID:     req.PayerID,
+		PayeeID:     req.PayeeID,
+		Amount:      amount,
+		Title:       req.Title,
+		Description: req.Description,
+		Method:      models.MethodCash,
+	}
+	var payment *models.Payment
+	var err error
+	if req.ObligationID != "" {
+		payment, err = h.service.PayObligation(r.Context(), req.ObligationID, req.PayerID, preq)
+	} else {
+		payment, err = h.service.CreatePayment(r.Context(), preq)
+	}
+	if err == nil && req.Approve {
+		payment, err = h.service.ReviewOfflinePayment(r.Context(), payment.ID, claims["user_id"].(string), true, req.Note)
+	}
+	if err != nil {
+		log.Printf("Failed to record cash payment: %v", err)
+		if strings.Contains(err.Error(), "obligation not found") {
+			http.Error(w, `{"error":"obligation not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to record cash payment: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(payment); err != nil {
+		log.Printf("Failed to encode payment: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// UploadProof handles POST /api/payment/{paymentID}/proof as multipart form
+// data with the receipt in the "file" field.
+func (h *PaymentHandler) UploadProof(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	role, _ := claims["role"].(string)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxProofSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, `{"error":"Invalid upload, the file must be at most 5 MB"}`, http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, `{"error":"file is required"}`, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxProofSize {
+		http.Error(w, `{"error":"The file must be at most 5 MB"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Trust the file contents over the client's declared type
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	contentType := http.DetectContentType(sniff[:n])
+	if !proofContentTypes[contentType] {
+		http.Error(w, `{"error":"Proof must be a JPEG, PNG or WebP image or a PDF"}`, http.StatusBadRequest)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, `{"error":"Failed to read upload"}`, http.StatusInternalServerError)
+		return
+	}
+
+	paymentID := mux.Vars(r)["paymentID"]
+	payment, err := h.service.AttachProof(r.Context(), paymentID, claims["user_id"].(string), role == "admin", filepath.Base(header.Filename), contentType, file)
+	if err != nil {
+		log.Printf("Failed to attach proof to payment %s: %v", paymentID, err)
+		switch {
+		case strings.Contains(err.Error(), "payment not found"):
+			http.Error(w, `{"error":"payment not found"}`, http.StatusNotFound)
+		case strings.Contains(err.Error(), "does not belong"):
+			http.Error(w, `{"error":"Unauthorized to upload proof for this payment"}`, http.StatusForbidden)
+		default:
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to attach proof: %v"}`, err), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(payment); err != nil {
+		log.Printf("Failed to encode payment: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetProof handles GET /api/payment/{paymentID}/proof
+func (h *PaymentHandler) GetProof(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	role, _ := claims["role"].(string)
+
+	paymentID := mux.Vars(r)["paymentID"]
+	proof, file, err := h.service.OpenProof(r.Context(), paymentID, claims["user_id"].(string), role == "admin")
+	if err != nil {
+		log.Printf("Failed to open proof for payment %s: %v", paymentID, err)
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, `{"error":"proof not found"}`, http.StatusNotFound)
+		case strings.Contains(err.Error(), "does not belong"):
+			http.Error(w, `{"error":"Unauthorized to view this proof"}`, http.StatusForbidden)
+		default:
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to open proof: %v"}`, err), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", proof.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", proof.FileName))
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Failed to stream proof for payment %s: %v", paymentID, err)
+	}
+}
+
+// GetOfflineQueue handles GET /api/payments/offline/pending
+func (h *PaymentHandler) GetOfflineQueue(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	payments, err := h.service.GetOfflineQueue(r.Context())
+	if err != nil {
+		log.Printf("Failed to fetch offline review queue: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch review queue: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(payments); err != nil {
+		log.Printf("Failed to encode review queue: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// ReviewOfflinePayment handles POST /api/payment/{paymentID}/review
+func (h *PaymentHandler) ReviewOfflinePayment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Decision string `json:"decision"` // APPROVE or REJECT
+		Note     string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	decision := strings.ToUpper(strings.TrimSpace(req.Decision))
+	if decision != "APPROVE" && decision != "REJECT" {
+		http.Error(w, `{"error":"decision must be APPROVE or REJECT"}`, http.StatusBadRequest)
+		return
+	}
+	if decision == "REJECT" && strings.TrimSpace(req.Note) == "" {
+		http.Error(w, `{"error":"A note is required when rejecting"}`, http.StatusBadRequest)
+		return
+	}
+
+	paymentID := mux.Vars(r)["paymentID"]
+	payment, err := h.service.ReviewOfflinePayment(r.Context(), paymentID, claims["user_id"].(string), decision == "APPROVE", req.Note)
+	if err != nil {
+		log.Printf("Failed to review payment %s: %v", paymentID, err)
+		if strings.Contains(err.Error(), "payment not found") {
+			http.Error(w, `{"error":"payment not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to review payment: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(payment); err != nil {
+		log.Printf("Failed to encode payment: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
 	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
 )
 
@@ -89,6 +91,10 @@ func (h *PaymentHandler) UpdatePayment(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, `{"error":"payment not found"}`, http.StatusBadRequest)
 			return
 		}
+		if strings.Contains(err.Error(), "offline payments") || strings.Contains(err.Error(), "can only update") || strings.Contains(err.Error(), "not completed") {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusConflict)
+			return
+		}
 		http.Error(w, fmt.Sprintf(`{"error":"Failed to proceed: %v"}`, err), http.StatusInternalServerError)
 		return
 	}
@@ -130,18 +136,30 @@ func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		PayerID     string  `json:"payer_id"`
-		PayeeID     string  `json:"payee_id"`
-		Amount      float64 `json:"amount"`
-		Title       string  `json:"title"`
-		Description string  `json:"description"`
+		PayerID     string      `json:"payer_id"`
+		PayeeID     string      `json:"payee_id"`
+		Amount      json.Number `json:"amount"` // decimal in PHP, e.g. 150.25
+		Title       string      `json:"title"`
+		Description string      `json:"description"`
+		// Method is EWALLET (default), QR_CODE, VIRTUAL_ACCOUNT or BANK_TRANSFER
+		Method string `json:"method"`
+		// ChannelCode is the e-wallet or bank to pay with, e.g. "PH_PAYMAYA" or "BPI"
+		ChannelCode  string `json:"channel_code"`
+		MobileNumber string `json:"mobile_number"`
+		// ExpiresInMinutes overrides the default PENDING lifetime of the charge
+		ExpiresInMinutes int `json:"expires_in_minutes"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
 		return
 	}
 
-	if req.Amount <= 0 {
+	amount, err := models.ParseMoney(req.Amount.String(), "PHP")
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"Invalid amount: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	if !amount.IsPositive() {
 		http.Error(w, `{"error":"Amount must be positive"}`, http.StatusBadRequest)
 		return
 	}
@@ -153,8 +171,26 @@ func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"Description is required"}`, http.StatusBadRequest)
 		return
 	}
+	if req.ExpiresInMinutes < 0 {
+		http.Error(w, `{"error":"expires_in_minutes cannot be negative"}`, http.StatusBadRequest)
+		return
+	}
+	if strings.EqualFold(req.Method, models.MethodCash) {
+		http.Error(w, `{"error":"Cash payments are recorded by an admin"}`, http.StatusBadRequest)
+		return
+	}
 
-	payment, err := h.service.CreatePayment(r.Context(), req.PayerID, req.PayeeID, req.Amount, req.Title, req.Description)
+	payment, err := h.service.CreatePayment(r.Context(), services.PaymentRequest{
+		PayerID:      req.PayerID,
+		PayeeID:      req.PayeeID,
+		Amount:       amount,
+		Title:        req.Title,
+		Description:  req.Description,
+		Method:       req.Method,
+		ChannelCode:  req.ChannelCode,
+		MobileNumber: req.MobileNumber,
+		ExpiresIn:    time.Duration(req.ExpiresInMinutes) * time.Minute,
+	})
 	if err != nil {
 		log.Printf("Failed to create payment: %v", err)
 		http.Error(w, fmt.Sprintf(`{"error":"Failed to create payment: %v"}`, err), http.StatusInternalServerError)
@@ -278,7 +314,7 @@ func (h *PaymentHandler) GetPaymentsByUserID(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Check if the authenticated user is requesting their own payments
-	if authenticatedUserID != requestedUserID {
+	if role, _ := claims["role"].(string); authenticatedUserID != requestedUserID && role != "admin" {
 		http.Error(w, `{"error":"Unauthorized to view payments for this user"}`, http.StatusForbidden)
 		return
 	}
@@ -287,6 +323,12 @@ func (h *PaymentHandler) GetPaymentsByUserID(w http.ResponseWriter, r *http.Requ
 	statusFilter := r.URL.Query().Get("status")
 	startDate := r.URL.Query().Get("start_date")
 	endDate := r.URL.Query().Get("end_date")
+	role := r.URL.Query().Get("role") // payer (default) or payee
+
+	if role != "" && role != "payer" && role != "payee" {
+		http.Error(w, `{"error":"Invalid role, must be payer or payee"}`, http.StatusBadRequest)
+		return
+	}
 
 	// Validate status filter
 	if statusFilter != "" && statusFilter != "PENDING" && statusFilter != "SUCCEEDED" {
@@ -306,9 +348,13 @@ func (h *PaymentHandler) GetPaymentsByUserID(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Fetch payments for the requested user
-	payments, err := h.service.GetPayments(r.Context(), statusPtr, startDatePtr, endDatePtr)
+	payments, err := h.service.GetPaymentsByUserID(r.Context(), requestedUserID, role, statusPtr, startDatePtr, endDatePtr)
 	if err != nil {
 		log.Printf("Failed to fetch payments for user %s: %v", requestedUserID, err)
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch payments: %v"}`, err), http.StatusInternalServerError)
 		return
 	}
@@ -319,3 +365,34 @@ func (h *PaymentHandler) GetPaymentsByUserID(w http.ResponseWriter, r *http.Requ
 		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
 	}
 }
+
+// GetOutstandingTotal handles GET /api/userid/{userID}/outstanding
+func (h *PaymentHandler) GetOutstandingTotal(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	requestedUserID := mux.Vars(r)["userID"]
+	if requestedUserID == "" {
+		http.Error(w, `{"error":"User ID is required"}`, http.StatusBadRequest)
+		return
+	}
+	if claims["user_id"].(string) != requestedUserID {
+		http.Error(w, `{"error":"Unauthorized to view payments for this user"}`, http.StatusForbidden)
+		return
+	}
+
+	total, err := h.service.GetOutstandingTotal(r.Context(), requestedUserID)
+	if err != nil {
+		log.Printf("Failed to compute outstanding total for user %s: %v", requestedUserID, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to compute outstanding total: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"user_id": requestedUserID, "outstanding": total}); err != nil {
+		log.Printf("Failed to encode outstanding total: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
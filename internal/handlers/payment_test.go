@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+func testToken(t *testing.T, userID, role string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": userID, "role": role}).SignedString(jwtSecret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+// newTestPaymentHandler answers the payment service's index creation and
+// drops its event, so each test sees only its own commands.
+func newTestPaymentHandler(mt *mtest.T) *PaymentHandler {
+	mt.AddMockResponses(mtest.CreateSuccessResponse())
+	h := NewPaymentHandler(services.NewPaymentService(mt.DB))
+	mt.ClearEvents()
+	return h
+}
+
+func getPaymentsByUserID(h *PaymentHandler, token, userID, query string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/userid/"+userID+"/payments"+query, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r = mux.SetURLVars(r, map[string]string{"userID": userID})
+	w := httptest.NewRecorder()
+	h.GetPaymentsByUserID(w, r)
+	return w
+}
+
+func TestGetPaymentsByUserID(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	member := primitive.NewObjectID().Hex()
+	other := primitive.NewObjectID().Hex()
+
+	mt.Run("other member is forbidden", func(mt *mtest.T) {
+		h := newTestPaymentHandler(mt)
+		w := getPaymentsByUserID(h, testToken(mt.T, member, "user"), other, "")
+		if w.Code != http.StatusForbidden {
+			mt.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+	})
+
+	mt.Run("invalid role", func(mt *mtest.T) {
+		h := newTestPaymentHandler(mt)
+		w := getPaymentsByUserID(h, testToken(mt.T, member, "user"), member, "?role=treasurer")
+		if w.Code != http.StatusBadRequest {
+			mt.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	scopes := []struct {
+		name  string
+		query string
+		field string
+		other string
+	}{
+		{name: "payer by default", query: "", field: "payer_id", other: "payee_id"},
+		{name: "payer", query: "?role=payer", field: "payer_id", other: "payee_id"},
+		{name: "payee", query: "?role=payee", field: "payee_id", other: "payer_id"},
+	}
+	for _, tt := range scopes {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			h := newTestPaymentHandler(mt)
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.payments", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: "p1"},
+				{Key: tt.field, Value: member},
+				{Key: "status", Value: "SUCCEEDED"},
+			}))
+			w := getPaymentsByUserID(h, testToken(mt.T, member, "user"), member, tt.query)
+			if w.Code != http.StatusOK {
+				mt.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+
+			filter, ok := mt.GetStartedEvent().Command.Lookup("filter").DocumentOK()
+			if !ok {
+				mt.Fatal("find command has no filter")
+			}
+			if got, _ := filter.Lookup(tt.field).StringValueOK(); got != member {
+				mt.Errorf("filter %s = %q, want %q", tt.field, got, member)
+			}
+			if _, err := filter.LookupErr(tt.other); err == nil {
+				mt.Errorf("filter unexpectedly scoped by %s", tt.other)
+			}
+		})
+	}
+
+	mt.Run("admin sees any member", func(mt *mtest.T) {
+		h := newTestPaymentHandler(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.payments", mtest.FirstBatch))
+		w := getPaymentsByUserID(h, testToken(mt.T, member, "admin"), other, "?role=payee")
+		if w.Code != http.StatusOK {
+			mt.Errorf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// penaltyRequest is a late fee rule as sent by clients, with amounts in pesos.
+type penaltyRequest struct {
+	Type       string      `json:"type"`        // FLAT or PERCENT
+	Amount     json.Number `json:"amount"`      // FLAT only
+	RateBps    int64       `json:"rate_bps"`    // PERCENT only, 500 = 5%
+	Recurrence string      `json:"recurrence"`  // ONCE, DAILY, WEEKLY or MONTHLY
+	GraceDays  int         `json:"grace_days"`  // days after the due date
+	MaxPeriods int         `json:"max_periods"` // 0 = no cap
+}
+
+// discountRequest is an early-payment discount as sent by clients.
+type discountRequest struct {
+	Type       string      `json:"type"`        // FLAT or PERCENT
+	Amount     json.Number `json:"amount"`      // FLAT only
+	RateBps    int64       `json:"rate_bps"`    // PERCENT only
+	BeforeDays int         `json:"before_days"` // days before the due date
+}
+
+// parseFeeRules converts the optional penalty and discount of a request.
+func parseFeeRules(p *penaltyRequest, d *discountRequest) (*models.PenaltyRule, *models.EarlyDiscount, error) {
+	var penalty *models.PenaltyRule
+	var discount *models.EarlyDiscount
+	if p != nil {
+		penalty = &models.PenaltyRule{
+			Type:       strings.ToUpper(p.Type),
+			RateBps:    p.RateBps,
+			Recurrence: strings.ToUpper(p.Recurrence),
+			GraceDays:  p.GraceDays,
+			MaxPeriods: p.MaxPeriods,
+		}
+		if p.Amount != "" {
+			amount, err := models.ParseMoney(p.Amount.String(), "PHP")
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid penalty amount: %v", err)
+			}
+			penalty.Amount = amount
+		}
+	}
+	if d != nil {
+		discount = &models.EarlyDiscount{
+			Type:       strings.ToUpper(d.Type),
+			RateBps:    d.RateBps,
+			BeforeDays: d.BeforeDays,
+		}
+		if d.Amount != "" {
+			amount, err := models.ParseMoney(d.Amount.String(), "PHP")
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid discount amount: %v", err)
+			}
+			discount.Amount = amount
+		}
+	}
+	return penalty, discount, nil
+}
+
+// SetFeeRules handles PATCH /api/collection/{collectionID}/rules
+func (h *CollectionHandler) SetFeeRules(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		Penalty  *penaltyRequest  `json:"penalty"`  // null removes the rule
+		Discount *discountRequest `json:"discount"` // null removes the discount
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	penalty, discount, err := parseFeeRules(req.Penalty, req.Discount)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	collectionID := mux.Vars(r)["collectionID"]
+	collection, err := h.service.SetFeeRules(r.Context(), collectionID, penalty, discount)
+	if err != nil {
+		log.Printf("Failed to update fee rules of collection %s: %v", collectionID, err)
+		if strings.Contains(err.Error(), "collection not found") {
+			http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to update fee rules: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(collection); err != nil {
+		log.Printf("Failed to encode collection: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// PlanHandler handles HTTP requests for recurring billing plans
+type PlanHandler struct {
+	service *services.PlanService
+}
+
+// NewPlanHandler creates a new PlanHandler
+func NewPlanHandler(service *services.PlanService) *PlanHandler {
+	return &PlanHandler{service: service}
+}
+
+// CreatePlan handles POST /api/plan
+func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Title        string           `json:"title"`
+		Description  string           `json:"description"`
+		Amount       json.Number      `json:"amount"`
+		Frequency    string           `json:"frequency"` // MONTHLY, QUARTERLY, SEMESTRAL or CUSTOM
+		Schedule     string           `json:"schedule"`  // cron expression, CUSTOM only
+		DueAfterDays int              `json:"due_after_days"`
+		Prorate      bool             `json:"prorate"`
+		PayeeID      string           `json:"payee_id"`
+		AllMembers   bool             `json:"all_members"`
+		MemberIDs    []string         `json:"member_ids"`
+		StartDate    string           `json:"start_date"` // RFC3339, defaults to now
+		EndDate      string           `json:"end_date"`   // RFC3339, optional
+		Penalty      *penaltyRequest  `json:"penalty"`    // copied to every cycle
+		Discount     *discountRequest `json:"discount"`   // copied to every cycle
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	amount, err := models.ParseMoney(req.Amount.String(), "PHP")
+	if err != nil || !amount.IsPositive() {
+		http.Error(w, `{"error":"Amount must be a positive amount with at most 2 decimals"}`, http.StatusBadRequest)
+		return
+	}
+	var startDate, endDate time.Time
+	if req.StartDate != "" {
+		if startDate, err = time.Parse(time.RFC3339, req.StartDate); err != nil {
+			http.Error(w, `{"error":"Invalid start_date format, must be RFC3339"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.EndDate != "" {
+		if endDate, err = time.Parse(time.RFC3339, req.EndDate); err != nil {
+			http.Error(w, `{"error":"Invalid end_date format, must be RFC3339"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	if !req.AllMembers && len(req.MemberIDs) == 0 {
+		http.Error(w, `{"error":"Either all_members or member_ids is required"}`, http.StatusBadRequest)
+		return
+	}
+	penalty, discount, err := parseFeeRules(req.Penalty, req.Discount)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.service.CreatePlan(r.Context(), &models.BillingPlan{
+		Title:        req.Title,
+		Description:  req.Description,
+		Amount:       amount,
+		Frequency:    req.Frequency,
+		Schedule:     req.Schedule,
+		DueAfterDays: req.DueAfterDays,
+		Prorate:      req.Prorate,
+		Penalty:      penalty,
+		Discount:     discount,
+		PayeeID:      req.PayeeID,
+		AllMembers:   req.AllMembers,
+		StartDate:    startDate,
+		EndDate:      endDate,
+		CreatedBy:    claims["user_id"].(string),
+	}, req.MemberIDs)
+	if err != nil {
+		log.Printf("Failed to create billing plan: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to create billing plan: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		log.Printf("Failed to encode billing plan: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetPlans handles GET /api/plans
+func (h *PlanHandler) GetPlans(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	plans, err := h.service.GetPlans(r.Context())
+	if err != nil {
+		log.Printf("Failed to fetch billing plans: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch billing plans: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plans); err != nil {
+		log.Printf("Failed to encode billing plans: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// EnrollMembers handles POST /api/plan/{planID}/members
+func (h *PlanHandler) EnrollMembers(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	planID := mux.Vars(r)["planID"]
+	var req struct {
+		MemberIDs []string `json:"member_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.MemberIDs) == 0 {
+		http.Error(w, `{"error":"member_ids is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.service.EnrollMembers(r.Context(), planID, req.MemberIDs)
+	if err != nil {
+		h.writePlanError(w, planID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		log.Printf("Failed to encode billing plan: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// UpdatePlanStatus handles PATCH /api/plan/{planID}/status
+func (h *PlanHandler) UpdatePlanStatus(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	planID := mux.Vars(r)["planID"]
+	var req struct {
+		Status string `json:"status"` // ACTIVE, PAUSED or ENDED
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.service.SetPlanStatus(r.Context(), planID, strings.ToUpper(req.Status))
+	if err != nil {
+		h.writePlanError(w, planID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		log.Printf("Failed to encode billing plan: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+func (h *PlanHandler) writePlanError(w http.ResponseWriter, planID string, err error) {
+	log.Printf("Failed to update billing plan %s: %v", planID, err)
+	if strings.Contains(err.Error(), "billing plan not found") {
+		http.Error(w, `{"error":"billing plan not found"}`, http.StatusNotFound)
+		return
+	}
+	http.Error(w, fmt.Sprintf(`{"error":"Failed to update billing plan: %v"}`, err), http.StatusBadRequest)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// GetReceipt handles GET /api/payment/{paymentID}/receipt
+func (h *PaymentHandler) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	role, _ := claims["role"].(string)
+
+	paymentID := mux.Vars(r)["paymentID"]
+	payment, pdf, err := h.service.GetReceipt(r.Context(), paymentID, claims["user_id"].(string), role == "admin")
+	if err != nil {
+		log.Printf("Failed to issue receipt for payment %s: %v", paymentID, err)
+		switch {
+		case strings.Contains(err.Error(), "payment not found"):
+			http.Error(w, `{"error":"payment not found"}`, http.StatusNotFound)
+		case strings.Contains(err.Error(), "does not belong"):
+			http.Error(w, `{"error":"Unauthorized to view this receipt"}`, http.StatusForbidden)
+		case strings.Contains(err.Error(), "only issued"):
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusConflict)
+		default:
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to issue receipt: %v"}`, err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", payment.ReceiptNumber+".pdf"))
+	if _, err := w.Write(pdf); err != nil {
+		log.Printf("Failed to write receipt for payment %s: %v", paymentID, err)
+	}
+}
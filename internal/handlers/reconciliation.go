@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RunReconciliation handles POST /api/reconciliation/run
+func (h *PaymentHandler) RunReconciliation(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	// min_age is a Go duration such as "30m"; defaults to 30 minutes
+	minAge := 30 * time.Minute
+	if v := r.URL.Query().Get("min_age"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			http.Error(w, `{"error":"Invalid min_age, must be a duration like 30m"}`, http.StatusBadRequest)
+			return
+		}
+		minAge = d
+	}
+
+	report, err := h.service.Reconcile(r.Context(), minAge)
+	if err != nil {
+		log.Printf("Reconciliation failed: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Reconciliation failed: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode reconciliation report: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetReconciliationReports handles GET /api/reconciliation/reports
+func (h *PaymentHandler) GetReconciliationReports(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	limit := int64(20)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, `{"error":"Invalid limit"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	reports, err := h.service.GetReconciliationReports(r.Context(), limit)
+	if err != nil {
+		log.Printf("Failed to fetch reconciliation reports: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch reconciliation reports: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reports); err != nil {
+		log.Printf("Failed to encode reconciliation reports: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// RefundPayment handles POST /api/payment/{paymentID}/refund
+func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	paymentID := mux.Vars(r)["paymentID"]
+	if paymentID == "" {
+		http.Error(w, `{"error":"Payment ID is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Amount json.Number `json:"amount"` // omit for a full refund
+		Reason string      `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, `{"error":"Reason is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	amount := models.NewMoney(0, "PHP")
+	if req.Amount != "" {
+		var err error
+		amount, err = models.ParseMoney(req.Amount.String(), "PHP")
+		if err != nil || !amount.IsPositive() {
+			http.Error(w, `{"error":"Amount must be a positive amount with at most 2 decimals"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	refund, err := h.service.RefundPayment(r.Context(), paymentID, amount, req.Reason, claims["user_id"].(string))
+	if err != nil {
+		log.Printf("Failed to refund payment %s: %v", paymentID, err)
+		if strings.Contains(err.Error(), "payment not found") {
+			http.Error(w, `{"error":"payment not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to refund payment: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(refund); err != nil {
+		log.Printf("Failed to encode refund: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetRefunds handles GET /api/payment/{paymentID}/refunds
+func (h *PaymentHandler) GetRefunds(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	paymentID := mux.Vars(r)["paymentID"]
+	refunds, err := h.service.GetRefunds(r.Context(), paymentID)
+	if err != nil {
+		log.Printf("Failed to fetch refunds for payment %s: %v", paymentID, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch refunds: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(refunds); err != nil {
+		log.Printf("Failed to encode refunds: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// parseReportFilter reads collection_id, payee_id and from/to dates, which
+// are plain dates in Manila time or RFC 3339.
+func parseReportFilter(query url.Values) (services.ReportFilter, error) {
+	var filter services.ReportFilter
+	filter.CollectionID = strings.TrimSpace(query.Get("collection_id"))
+	filter.PayeeID = strings.TrimSpace(query.Get("payee_id"))
+
+	var err error
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = parseFilterTime("from", v, false); err != nil {
+			return filter, err
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = parseFilterTime("to", v, true); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// writeReport sends a report as JSON, or the error that kept it from being made.
+func writeReport(w http.ResponseWriter, name string, report interface{}, err error) {
+	if err != nil {
+		log.Printf("Failed to build %s report: %v", name, err)
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to build %s report: %v"}`, name, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode %s report: %v", name, err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// reportRequest checks the caller is an admin and reads the report filters.
+func reportRequest(w http.ResponseWriter, r *http.Request) (services.ReportFilter, bool) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return services.ReportFilter{}, false
+	}
+	filter, err := parseReportFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return filter, false
+	}
+	return filter, true
+}
+
+// GetReportSummary handles GET /api/reports/summary
+func (h *PaymentHandler) GetReportSummary(w http.ResponseWriter, r *http.Request) {
+	filter, ok := reportRequest(w, r)
+	if !ok {
+		return
+	}
+	summary, err := h.service.GetReportSummary(r.Context(), filter)
+	writeReport(w, "summary", summary, err)
+}
+
+// GetPeriodTotals handles GET /api/reports/totals?period=daily|weekly|monthly
+func (h *PaymentHandler) GetPeriodTotals(w http.ResponseWriter, r *http.Request) {
+	filter, ok := reportRequest(w, r)
+	if !ok {
+		return
+	}
+	period := strings.ToLower(r.URL.Query().Get("period"))
+	if period == "" {
+		period = "daily"
+	}
+	totals, err := h.service.GetPeriodTotals(r.Context(), filter, period)
+	writeReport(w, "totals", totals, err)
+}
+
+// GetCollectionCompletion handles GET /api/reports/collections
+func (h *PaymentHandler) GetCollectionCompletion(w http.ResponseWriter, r *http.Request) {
+	filter, ok := reportRequest(w, r)
+	if !ok {
+		return
+	}
+	report, err := h.service.GetCollectionCompletion(r.Context(), filter)
+	writeReport(w, "collection", report, err)
+}
+
+// GetTopDelinquents handles GET /api/reports/delinquents?limit=10
+func (h *PaymentHandler) GetTopDelinquents(w http.ResponseWriter, r *http.Request) {
+	filter, ok := reportRequest(w, r)
+	if !ok {
+		return
+	}
+	var limit int64
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, `{"error":"invalid limit"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	members, err := h.service.GetTopDelinquents(r.Context(), filter, limit)
+	writeReport(w, "delinquency", members, err)
+}
+
+// GetChannelBreakdown handles GET /api/reports/channels
+func (h *PaymentHandler) GetChannelBreakdown(w http.ResponseWriter, r *http.Request) {
+	filter, ok := reportRequest(w, r)
+	if !ok {
+		return
+	}
+	channels, err := h.service.GetChannelBreakdown(r.Context(), filter)
+	writeReport(w, "channel", channels, err)
+}
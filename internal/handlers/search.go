@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// manila is the time zone plain dates in filters are read in.
+var manila = time.FixedZone("PHT", 8*60*60)
+
+// parseFilterTime reads an RFC 3339 time or a YYYY-MM-DD date in Manila time.
+// With endOfDay a plain date means the end of that day, so the range includes it.
+func parseFilterTime(name, value string, endOfDay bool) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, manila)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, must be YYYY-MM-DD or RFC 3339", name)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// parsePaymentFilter reads the payment filters shared by search and export:
+// status (comma-separated), payer_id, payee_id, min_amount, max_amount,
+// method, channel_code, collection_id, q (title text) and from/to dates.
+func parsePaymentFilter(query url.Values) (services.PaymentFilter, error) {
+	var filter services.PaymentFilter
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status = strings.ToUpper(strings.TrimSpace(status)); status != "" {
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	filter.PayerID = strings.TrimSpace(query.Get("payer_id"))
+	filter.PayeeID = strings.TrimSpace(query.Get("payee_id"))
+	filter.Method = strings.ToUpper(strings.TrimSpace(query.Get("method")))
+	filter.ChannelCode = strings.ToUpper(strings.TrimSpace(query.Get("channel_code")))
+	filter.CollectionID = strings.TrimSpace(query.Get("collection_id"))
+	filter.Title = strings.TrimSpace(query.Get("q"))
+
+	for _, bound := range []struct {
+		name string
+		dst  **models.Money
+	}{{"min_amount", &filter.MinAmount}, {"max_amount", &filter.MaxAmount}} {
+		if v := query.Get(bound.name); v != "" {
+			amount, err := models.ParseMoney(v, "PHP")
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s", bound.name)
+			}
+			*bound.dst = &amount
+		}
+	}
+
+	var err error
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = parseFilterTime("from", v, false); err != nil {
+			return filter, err
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = parseFilterTime("to", v, true); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// SearchPayments handles GET /api/payments/search with the payment filters,
+// sort (created_at, amount, status or title), order (asc or desc), limit
+// and cursor.
+func (h *PaymentHandler) SearchPayments(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := parsePaymentFilter(query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+	search := services.PaymentSearch{
+		PaymentFilter: filter,
+		Sort:          query.Get("sort"),
+		Cursor:        query.Get("cursor"),
+	}
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		search.Asc = true
+	default:
+		http.Error(w, `{"error":"Invalid order, must be asc or desc"}`, http.StatusBadRequest)
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, `{"error":"Invalid limit"}`, http.StatusBadRequest)
+			return
+		}
+		search.Limit = n
+	}
+
+	page, err := h.service.SearchPayments(r.Context(), search)
+	if err != nil {
+		log.Printf("Failed to search payments: %v", err)
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to search payments: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		log.Printf("Failed to encode payments: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
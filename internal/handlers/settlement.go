@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// RunSettlements handles POST /api/settlements/run
+func (h *PaymentHandler) RunSettlements(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	settled, err := h.service.RunSettlements(r.Context())
+	if err != nil {
+		log.Printf("Settlement run failed: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Settlement run failed: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"settled": settled}); err != nil {
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetSettlements handles GET /api/settlements?payee_id=. Payees only see
+// their own settlements.
+func (h *PaymentHandler) GetSettlements(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	payeeID := r.URL.Query().Get("payee_id")
+	if role, _ := claims["role"].(string); role != "admin" {
+		payeeID = claims["user_id"].(string)
+	}
+
+	settlements, err := h.service.GetSettlements(r.Context(), payeeID)
+	if err != nil {
+		log.Printf("Failed to fetch settlements: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch settlements: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(settlements); err != nil {
+		log.Printf("Failed to encode settlements: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetSettlementReport handles GET /api/settlement/{settlementID}
+func (h *PaymentHandler) GetSettlementReport(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	settlementID := mux.Vars(r)["settlementID"]
+	report, err := h.service.GetSettlementReport(r.Context(), settlementID)
+	if err != nil {
+		log.Printf("Failed to fetch settlement %s: %v", settlementID, err)
+		if strings.Contains(err.Error(), "settlement not found") {
+			http.Error(w, `{"error":"settlement not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch settlement: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	if role, _ := claims["role"].(string); role != "admin" && report.PayeeID != claims["user_id"].(string) {
+		http.Error(w, `{"error":"Unauthorized to view this settlement"}`, http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode settlement report: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// sheetWriter streams rows of a spreadsheet export. Cells may be strings,
+// ints, models.Money or time.Time.
+type sheetWriter interface {
+	WriteRow(cells ...interface{}) error
+	Close() error
+}
+
+// newSheetWriter starts a CSV or XLSX download named name on w.
+func newSheetWriter(w http.ResponseWriter, format, name string) (sheetWriter, error) {
+	switch format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".csv"))
+		return &csvSheet{w: csv.NewWriter(w)}, nil
+	case "xlsx":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".xlsx"))
+		return newXLSXSheet(w)
+	default:
+		return nil, fmt.Errorf("invalid format, must be csv or xlsx")
+	}
+}
+
+// formatCell renders a cell as text.
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case models.Money:
+		return v.String()
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.In(manila).Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// csvSheet writes rows as CSV.
+type csvSheet struct {
+	w *csv.Writer
+}
+
+func (s *csvSheet) WriteRow(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		text := formatCell(cell)
+		// Keep spreadsheet apps from evaluating member-supplied text as a formula
+		if _, ok := cell.(string); ok && text != "" && strings.ContainsRune("=+-@", rune(text[0])) {
+			text = "'" + text
+		}
+		record[i] = text
+	}
+	return s.w.Write(record)
+}
+
+func (s *csvSheet) Close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// xlsxSheet writes a single-sheet workbook, streaming the sheet XML into the
+// zip as rows arrive. Strings are stored inline so no shared string table
+// has to be built up front.
+type xlsxSheet struct {
+	zip  *zip.Writer
+	xml  *bufio.Writer
+	rows int
+}
+
+// xlsxParts are the fixed parts of a one-sheet workbook.
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func newXLSXSheet(w io.Writer) (*xlsxSheet, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	s := &xlsxSheet{zip: zw, xml: bufio.NewWriter(f)}
+	s.xml.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return s, nil
+}
+
+// xlsxColumn returns the column letters for a zero-based index: A, B, ... AA.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func (s *xlsxSheet) WriteRow(cells ...interface{}) error {
+	s.rows++
+	fmt.Fprintf(s.xml, `<row r="%d">`, s.rows)
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(s.rows)
+		switch v := cell.(type) {
+		case int, int64:
+			fmt.Fprintf(s.xml, `<c r="%s"><v>%s</v></c>`, ref, formatCell(v))
+		case models.Money:
+			// Amounts stay numeric so officers can total them
+			fmt.Fprintf(s.xml, `<c r="%s"><v>%s</v></c>`, ref, v.String())
+		default:
+			fmt.Fprintf(s.xml, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(s.xml, []byte(formatCell(v)))
+			s.xml.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := s.xml.WriteString(`</row>`)
+	return err
+}
+
+func (s *xlsxSheet) Close() error {
+	s.xml.WriteString(`</sheetData></worksheet>`)
+	if err := s.xml.Flush(); err != nil {
+		return err
+	}
+	return s.zip.Close()
+}
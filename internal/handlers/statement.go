@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// GetMyStatement handles GET /api/me/statement?from=&to=&format=json|pdf
+func (h *PaymentHandler) GetMyStatement(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var from *time.Time
+	to := time.Now()
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = parseFilterTime("from", v, false); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		end, err := parseFilterTime("to", v, true)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+			return
+		}
+		to = *end
+	}
+	if from != nil && !from.Before(to) {
+		http.Error(w, `{"error":"from must be before to"}`, http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "pdf" {
+		http.Error(w, `{"error":"format must be json or pdf"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID := claims["user_id"].(string)
+	statement, err := h.service.GetStatement(r.Context(), userID, from, to)
+	if err != nil {
+		log.Printf("Failed to build statement for user %s: %v", userID, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to build statement: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "statement-"+to.Add(-time.Second).In(manila).Format("20060102")+".pdf"))
+		if _, err := w.Write(services.RenderStatementPDF(statement)); err != nil {
+			log.Printf("Failed to write statement for user %s: %v", userID, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statement); err != nil {
+		log.Printf("Failed to encode statement: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
 	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
 	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -166,3 +169,84 @@ func (h *UserHandler) LoginUserHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
 	}
 }
+
+// UpdatePayout handles PATCH /api/user/{userID}/payout
+func (h *UserHandler) UpdatePayout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	userID := mux.Vars(r)["userID"]
+	if role, _ := claims["role"].(string); claims["user_id"].(string) != userID && role != "admin" {
+		http.Error(w, `{"error":"Unauthorized to update this user"}`, http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		PayoutChannel string `json:"payout_channel"`
+		PayoutAccount string `json:"payout_account"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if req.PayoutChannel == "" || req.PayoutAccount == "" {
+		http.Error(w, `{"error":"payout_channel and payout_account are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.UpdatePayout(r.Context(), userID, req.PayoutChannel, req.PayoutAccount)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to update payout for user %s: %v", userID, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to update payout: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// UpdatePayoutSchedule handles PATCH /api/user/{userID}/payout-schedule
+func (h *UserHandler) UpdatePayoutSchedule(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	userID := mux.Vars(r)["userID"]
+	if role, _ := claims["role"].(string); claims["user_id"].(string) != userID && role != "admin" {
+		http.Error(w, `{"error":"Unauthorized to update this user"}`, http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		PayoutSchedule string `json:"payout_schedule"` // INSTANT, DAILY or WEEKLY
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.UpdatePayoutSchedule(r.Context(), userID, req.PayoutSchedule)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to update payout schedule for user %s: %v", userID, err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to update payout schedule: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
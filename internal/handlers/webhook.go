@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/services"
+)
+
+// WebhookHandler handles HTTP requests for outbound webhook endpoints
+type WebhookHandler struct {
+	service *services.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// webhookError maps a webhook service error to a response.
+func webhookError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusNotFound)
+	case strings.Contains(err.Error(), "invalid"):
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+	default:
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to %s: %v"}`, action, err), http.StatusInternalServerError)
+	}
+}
+
+// CreateEndpoint handles POST /api/webhooks. The response includes the
+// signing secret, which is not shown again.
+func (h *WebhookHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		URL         string   `json:"url"`
+		Description string   `json:"description"`
+		Events      []string `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := h.service.CreateEndpoint(r.Context(), &models.WebhookEndpoint{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		CreatedBy:   claims["user_id"].(string),
+	})
+	if err != nil {
+		log.Printf("Failed to register webhook endpoint: %v", err)
+		webhookError(w, "register webhook endpoint", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(endpoint); err != nil {
+		log.Printf("Failed to encode webhook endpoint: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// GetEndpoints handles GET /api/webhooks
+func (h *WebhookHandler) GetEndpoints(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	endpoints, err := h.service.GetEndpoints(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch webhook endpoints: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(endpoints); err != nil {
+		log.Printf("Failed to encode webhook endpoints: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// UpdateEndpoint handles PATCH /api/webhook/{webhookID}
+func (h *WebhookHandler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		URL         *string  `json:"url"`
+		Description *string  `json:"description"`
+		Events      []string `json:"events"`
+		Active      *bool    `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	webhookID := mux.Vars(r)["webhookID"]
+	endpoint, err := h.service.UpdateEndpoint(r.Context(), webhookID, services.WebhookEndpointUpdate{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		Active:      req.Active,
+	})
+	if err != nil {
+		log.Printf("Failed to update webhook endpoint %s: %v", webhookID, err)
+		webhookError(w, "update webhook endpoint", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(endpoint); err != nil {
+		log.Printf("Failed to encode webhook endpoint: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// DeleteEndpoint handles DELETE /api/webhook/{webhookID}
+func (h *WebhookHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	webhookID := mux.Vars(r)["webhookID"]
+	if err := h.service.DeleteEndpoint(r.Context(), webhookID); err != nil {
+		log.Printf("Failed to delete webhook endpoint %s: %v", webhookID, err)
+		webhookError(w, "delete webhook endpoint", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateSecret handles POST /api/webhook/{webhookID}/rotate-secret
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	webhookID := mux.Vars(r)["webhookID"]
+	endpoint, err := h.service.RotateSecret(r.Context(), webhookID)
+	if err != nil {
+		log.Printf("Failed to rotate secret of webhook endpoint %s: %v", webhookID, err)
+		webhookError(w, "rotate webhook secret", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(endpoint); err != nil {
+		log.Printf("Failed to encode webhook endpoint: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// PingEndpoint handles POST /api/webhook/{webhookID}/ping
+func (h *WebhookHandler) PingEndpoint(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	webhookID := mux.Vars(r)["webhookID"]
+	if err := h.service.PingEndpoint(r.Context(), webhookID); err != nil {
+		log.Printf("Failed to ping webhook endpoint %s: %v", webhookID, err)
+		webhookError(w, "ping webhook endpoint", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetDeliveries handles GET /api/webhook/{webhookID}/deliveries?status=&limit=
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var limit int64
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, `{"error":"invalid limit"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	webhookID := mux.Vars(r)["webhookID"]
+	deliveries, err := h.service.GetDeliveries(r.Context(), webhookID, strings.ToUpper(query.Get("status")), limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch webhook deliveries: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(deliveries); err != nil {
+		log.Printf("Failed to encode webhook deliveries: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
+
+// Redeliver handles POST /api/webhook/delivery/{deliveryID}/redeliver
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	deliveryID := mux.Vars(r)["deliveryID"]
+	delivery, err := h.service.Redeliver(r.Context(), deliveryID)
+	if err != nil {
+		log.Printf("Failed to redeliver webhook delivery %s: %v", deliveryID, err)
+		webhookError(w, "redeliver webhook", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(delivery); err != nil {
+		log.Printf("Failed to encode webhook delivery: %v", err)
+		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+	}
+}
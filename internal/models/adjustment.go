@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Adjustment is a manual change an admin made to what a member owes on an obligation.
+type Adjustment struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ObligationID string             `bson:"obligation_id" json:"obligation_id"`
+	CollectionID string             `bson:"collection_id" json:"collection_id"`
+	MemberID     string             `bson:"member_id" json:"member_id"`
+	Type         string             `bson:"type" json:"type"`                   // "WAIVE", "DISCOUNT" or "CREDIT"
+	Amount       Money              `bson:"amount" json:"amount"`               // Taken off the balance
+	BalanceAfter Money              `bson:"balance_after" json:"balance_after"` // Balance once the adjustment applied
+	Reason       string             `bson:"reason" json:"reason"`
+	CreatedBy    string             `bson:"created_by" json:"created_by"` // Admin user ID
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
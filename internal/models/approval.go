@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PayoutApprovalPolicy decides which disbursements wait for admin approval
+// before they are sent. A zero threshold or window turns that rule off.
+type PayoutApprovalPolicy struct {
+	ID                string    `bson:"_id" json:"-"`
+	AmountThreshold   Money     `bson:"amount_threshold" json:"amount_threshold"`     // Payouts of at least this much need approval
+	NewPayoutHours    int       `bson:"new_payout_hours" json:"new_payout_hours"`     // Payouts this soon after the payee changed their payout number need approval
+	RequiredApprovals int       `bson:"required_approvals" json:"required_approvals"` // 1 or 2 distinct admins
+	UpdatedBy         string    `bson:"updated_by" json:"updated_by"`
+	UpdatedAt         time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// PayoutDecision is one admin's decision on a held payout.
+type PayoutDecision struct {
+	AdminID   string    `bson:"admin_id" json:"admin_id"`
+	Decision  string    `bson:"decision" json:"decision"` // "APPROVE" or "REJECT"
+	Note      string    `bson:"note,omitempty" json:"note,omitempty"`
+	DecidedAt time.Time `bson:"decided_at" json:"decided_at"`
+}
+
+// PayoutApproval holds a disbursement for a payment or a settlement until
+// enough admins approve it. It records the destination it was approved for.
+type PayoutApproval struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PaymentID         string             `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
+	SettlementID      string             `bson:"settlement_id,omitempty" json:"settlement_id,omitempty"`
+	PayeeID           string             `bson:"payee_id" json:"payee_id"`
+	Amount            Money              `bson:"amount" json:"amount"`
+	PayoutChannel     string             `bson:"payout_channel" json:"payout_channel"`
+	PayoutAccount     string             `bson:"payout_account" json:"payout_account"`
+	Reasons           []string           `bson:"reasons" json:"reasons"` // "AMOUNT_THRESHOLD", "NEW_PAYOUT_ACCOUNT"
+	RequiredApprovals int                `bson:"required_approvals" json:"required_approvals"`
+	Decisions         []PayoutDecision   `bson:"decisions" json:"decisions"`
+	Status            string             `bson:"status" json:"status"` // "PENDING", "APPROVED", "REJECTED"
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection is a payment request (dues, fees) an admin issues to many members at once.
+type Collection struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Title           string             `bson:"title" json:"title"`
+	Description     string             `bson:"description" json:"description"`
+	Amount          Money              `bson:"amount" json:"amount"` // Owed by each member
+	DueDate         time.Time          `bson:"due_date" json:"due_date"`
+	PayeeID         string             `bson:"payee_id" json:"payee_id"`                             // Treasurer who receives the funds
+	AllMembers      bool               `bson:"all_members" json:"all_members"`                       // Issued to every member with role "user"
+	TargetMemberIDs []string           `bson:"target_member_ids" json:"target_member_ids"`           // Members the collection was issued to
+	Penalty         *PenaltyRule       `bson:"penalty,omitempty" json:"penalty,omitempty"`           // Late fee rule
+	Discount        *EarlyDiscount     `bson:"discount,omitempty" json:"discount,omitempty"`         // Early-payment discount
+	PlanID          string             `bson:"plan_id,omitempty" json:"plan_id,omitempty"`           // Billing plan that generated this cycle
+	PeriodStart     time.Time          `bson:"period_start,omitempty" json:"period_start,omitempty"` // Cycle covered, for plan collections
+	PeriodEnd       time.Time          `bson:"period_end,omitempty" json:"period_end,omitempty"`
+	CreatedBy       string             `bson:"created_by" json:"created_by"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Obligation is what one member owes for a collection.
+type Obligation struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CollectionID string             `bson:"collection_id" json:"collection_id"`
+	MemberID     string             `bson:"member_id" json:"member_id"`
+	PayeeID      string             `bson:"payee_id" json:"payee_id"`
+	Title        string             `bson:"title" json:"title"`
+	Description  string             `bson:"description" json:"description"`
+	Amount       Money              `bson:"amount" json:"amount"`
+	DueDate      time.Time          `bson:"due_date" json:"due_date"`
+	Status       string             `bson:"status" json:"status"`                               // "UNPAID", "PARTIALLY_PAID", "PAID", "WAIVED"
+	Penalties    Money              `bson:"penalties" json:"penalties"`                         // Late fees assessed so far
+	Discounts    Money              `bson:"discounts" json:"discounts"`                         // Early-payment discounts granted so far
+	Adjustments  Money              `bson:"adjustments" json:"adjustments"`                     // Waivers, discounts and credits granted by admins
+	AmountPaid   Money              `bson:"amount_paid" json:"amount_paid"`                     // Sum of succeeded payments, fees included
+	Balance      Money              `bson:"balance" json:"balance"`                             // Amount + Penalties - Discounts - Adjustments - AmountPaid; PAID once this reaches zero
+	PaymentIDs   []string           `bson:"payment_ids,omitempty" json:"payment_ids,omitempty"` // Succeeded payments made against it
+	InvoiceID    string             `bson:"invoice_id,omitempty" json:"invoice_id,omitempty"`   // Live invoice for it
+	PaymentLock  *ObligationLock    `bson:"payment_lock,omitempty" json:"-"`                    // Held while an installment is being started
+	PaidAt       time.Time          `bson:"paid_at,omitempty" json:"paid_at,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ObligationLock serializes the installments started on an obligation.
+type ObligationLock struct {
+	Token     string    `bson:"token"`
+	ExpiresAt time.Time `bson:"expires_at"` // Taken over after this if the holder never released it
+}
+
+// CollectionStatus is the paid/unpaid roster of a collection.
+type CollectionStatus struct {
+	Collection Collection       `json:"collection"`
+	Total      int              `json:"total"`
+	Paid       int              `json:"paid"`
+	Partial    int              `json:"partially_paid"`
+	Unpaid     int              `json:"unpaid"`
+	Waived     int              `json:"waived"`
+	Collected  Money            `json:"collected"`
+	Adjusted   Money            `json:"adjusted"` // Waived, discounted or credited by admins
+	Members    []ObligationView `json:"members"`
+}
+
+// ObligationView is an obligation joined with the member it belongs to.
+type ObligationView struct {
+	Obligation `bson:",inline"`
+	FullName   string `bson:"fullname" json:"fullname"`
+	Email      string `bson:"email" json:"email"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Fee modes decide who bears Xendit's fee on a charge.
+const (
+	FeePayerPays       = "PAYER_PAYS"       // A convenience fee is added to what the payer is charged
+	FeePayeeAbsorbs    = "PAYEE_ABSORBS"    // The fee comes out of the payee's payout
+	FeePlatformAbsorbs = "PLATFORM_ABSORBS" // The platform pays the fee; the payee gets the full amount
+)
+
+// FeeRate is a percentage plus a flat amount per charge.
+type FeeRate struct {
+	RateBps int64 `bson:"rate_bps" json:"rate_bps"` // 200 = 2%
+	Flat    Money `bson:"flat" json:"flat"`
+}
+
+// FeePolicy configures fees for one payee, or for everyone when ID is "default".
+type FeePolicy struct {
+	ID              string             `bson:"_id" json:"id"` // "default" or a payee user ID
+	Mode            string             `bson:"mode" json:"mode"`
+	PlatformRateBps int64              `bson:"platform_rate_bps" json:"platform_rate_bps"` // Platform's cut of each payment, taken from the payout
+	Gateway         map[string]FeeRate `bson:"gateway,omitempty" json:"gateway,omitempty"` // Xendit's fee per payment method, overriding the built-in rates
+	UpdatedBy       string             `bson:"updated_by" json:"updated_by"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// PaymentFees are the fees computed for a payment when it was charged.
+type PaymentFees struct {
+	Mode           string `bson:"mode" json:"mode"`
+	ConvenienceFee Money  `bson:"convenience_fee" json:"convenience_fee"` // Added to the charge, PAYER_PAYS only
+	GatewayFee     Money  `bson:"gateway_fee" json:"gateway_fee"`         // Xendit's fee on the charge
+	PlatformFee    Money  `bson:"platform_fee" json:"platform_fee"`
+	NetAmount      Money  `bson:"net_amount" json:"net_amount"` // Disbursed to the payee
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InvoiceLine is one charge or credit on an invoice.
+type InvoiceLine struct {
+	Description string `bson:"description" json:"description"`
+	Amount      Money  `bson:"amount" json:"amount"` // Negative for discounts and credits
+}
+
+// Invoice bills a member for one obligation. Numbers are sequential with no
+// gaps and are never reused, even when an invoice is voided.
+type Invoice struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Seq          int64              `bson:"seq" json:"-"`
+	Number       string             `bson:"number" json:"number"` // e.g., "INV-000042"
+	ObligationID string             `bson:"obligation_id" json:"obligation_id"`
+	OpenFor      string             `bson:"open_for,omitempty" json:"-"` // Obligation ID while not void, so an obligation has one live invoice
+	CollectionID string             `bson:"collection_id" json:"collection_id"`
+	MemberID     string             `bson:"member_id" json:"member_id"`
+	PayeeID      string             `bson:"payee_id" json:"payee_id"`
+	Title        string             `bson:"title" json:"title"`
+	Lines        []InvoiceLine      `bson:"lines" json:"lines"`
+	Total        Money              `bson:"total" json:"total"`
+	AmountPaid   Money              `bson:"amount_paid" json:"amount_paid"`
+	Balance      Money              `bson:"balance" json:"balance"`
+	DueDate      time.Time          `bson:"due_date" json:"due_date"`
+	Status       string             `bson:"status" json:"status"` // "OPEN", "PAID", "VOID"
+	VoidReason   string             `bson:"void_reason,omitempty" json:"void_reason,omitempty"`
+	VoidedBy     string             `bson:"voided_by,omitempty" json:"voided_by,omitempty"`
+	IssuedAt     time.Time          `bson:"issued_at" json:"issued_at"`
+	PaidAt       time.Time          `bson:"paid_at,omitempty" json:"paid_at,omitempty"`
+	VoidedAt     time.Time          `bson:"voided_at,omitempty" json:"voided_at,omitempty"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
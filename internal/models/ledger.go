@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LedgerTransaction is one balanced, append-only set of ledger entries
+// recording a single money movement. Debits always equal credits.
+type LedgerTransaction struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key          string             `bson:"key" json:"key"`   // Unique per movement, e.g. "charge:<payment_id>", so retries post once
+	Kind         string             `bson:"kind" json:"kind"` // "OBLIGATION", "CHARGE", "DISBURSEMENT", "REFUND", "ADJUSTMENT", "FEE"
+	PaymentID    string             `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
+	ObligationID string             `bson:"obligation_id,omitempty" json:"obligation_id,omitempty"`
+	Memo         string             `bson:"memo" json:"memo"`
+	Entries      []LedgerEntry      `bson:"entries" json:"entries"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// LedgerEntry moves an amount into (debit) or out of (credit) one account.
+type LedgerEntry struct {
+	Account   string `bson:"account" json:"account"`     // e.g. "receivable:member:<id>", "clearing:xendit"
+	Direction string `bson:"direction" json:"direction"` // "DEBIT" or "CREDIT"
+	Amount    Money  `bson:"amount" json:"amount"`
+}
+
+// LedgerBalance is an account's debits minus its credits.
+type LedgerBalance struct {
+	Account string `bson:"_id" json:"account"`
+	Debits  Money  `bson:"debits" json:"debits"`
+	Credits Money  `bson:"credits" json:"credits"`
+	Balance Money  `bson:"balance" json:"balance"` // Debits - Credits; liabilities show negative
+}
+
+// LedgerCheck is the result of verifying the ledger's invariants.
+type LedgerCheck struct {
+	Transactions int64    `json:"transactions"`
+	Debits       Money    `json:"debits"`
+	Credits      Money    `json:"credits"`
+	Unbalanced   []string `json:"unbalanced"` // Keys of transactions whose entries do not net to zero
+	OK           bool     `json:"ok"`
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Money is an exact amount in the minor unit of its currency (centavos for PHP).
+type Money struct {
+	Centavos int64  `bson:"centavos" json:"centavos"`
+	Currency string `bson:"currency" json:"currency"` // ISO 4217, e.g., "PHP"
+}
+
+// NewMoney returns an amount of centavos in the given currency.
+func NewMoney(centavos int64, currency string) Money {
+	return Money{Centavos: centavos, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "150" or "150.25" in major units.
+// More than two decimal places is rejected rather than rounded.
+func ParseMoney(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, fmt.Errorf("amount is required")
+	}
+	neg := s[0] == '-'
+	unsigned := s
+	if neg || s[0] == '+' {
+		unsigned = s[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(unsigned, ".")
+	// Only one leading sign; ParseInt would accept a second, e.g. "--5"
+	if whole == "" || strings.ContainsAny(whole, "+-") || (hasFrac && frac == "") {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > 2 {
+		return Money{}, fmt.Errorf("amount %q has more than 2 decimal places", s)
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || strings.ContainsAny(frac, "+-") {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if units > (math.MaxInt64-cents)/100 {
+		return Money{}, fmt.Errorf("amount %q is too large", s)
+	}
+	total := units*100 + cents
+	if neg {
+		total = -total
+	}
+	return Money{Centavos: total, Currency: currency}, nil
+}
+
+// String formats the amount in major units with two decimals, e.g. "150.25".
+func (m Money) String() string {
+	sign := ""
+	c := m.Centavos
+	if c < 0 {
+		sign = "-"
+		c = -c
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+// IsPositive reports whether the amount is greater than zero.
+func (m Money) IsPositive() bool {
+	return m.Centavos > 0
+}
+
+// Add returns m+o. Both amounts must share a currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("currency mismatch: %s and %s", m.Currency, o.Currency)
+	}
+	return Money{Centavos: m.Centavos + o.Centavos, Currency: m.Currency}, nil
+}
+
+// Sub returns m-o. Both amounts must share a currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("currency mismatch: %s and %s", m.Currency, o.Currency)
+	}
+	return Money{Centavos: m.Centavos - o.Centavos, Currency: m.Currency}, nil
+}
+
+// UnmarshalBSONValue decodes the {centavos, currency} document and also the
+// legacy numeric amounts written before Money existed (assumed PHP), so
+// unmigrated documents still load.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.EmbeddedDocument:
+		type money Money
+		var v money
+		if err := raw.Unmarshal(&v); err != nil {
+			return err
+		}
+		*m = Money(v)
+	case bsontype.Double:
+		*m = Money{Centavos: int64(math.Round(raw.Double() * 100)), Currency: "PHP"}
+	case bsontype.Int32:
+		*m = Money{Centavos: int64(raw.Int32()) * 100, Currency: "PHP"}
+	case bsontype.Int64:
+		*m = Money{Centavos: raw.Int64() * 100, Currency: "PHP"}
+	case bsontype.Decimal128:
+		centavos, err := decimalCentavos(raw.Decimal128())
+		if err != nil {
+			return err
+		}
+		*m = Money{Centavos: centavos, Currency: "PHP"}
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+	default:
+		return fmt.Errorf("cannot decode %s into Money", t)
+	}
+	return nil
+}
+
+// decimalCentavos converts a decimal amount in major units to centavos,
+// rounding half away from zero like the legacy float64 amounts.
+func decimalCentavos(d primitive.Decimal128) (int64, error) {
+	coef, exp, err := d.BigInt()
+	if err != nil {
+		return 0, fmt.Errorf("cannot decode decimal %s into Money: %v", d.String(), err)
+	}
+	exp += 2 // major units to centavos
+	if exp >= 0 {
+		coef.Mul(coef, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+	} else {
+		neg := coef.Sign() < 0
+		div := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exp)), nil)
+		q, r := new(big.Int).QuoRem(coef.Abs(coef), div, new(big.Int))
+		if r.Lsh(r, 1).Cmp(div) >= 0 {
+			q.Add(q, big.NewInt(1))
+		}
+		if neg {
+			q.Neg(q)
+		}
+		coef = q
+	}
+	if !coef.IsInt64() {
+		return 0, fmt.Errorf("decimal %s is too large for Money", d.String())
+	}
+	return coef.Int64(), nil
+}
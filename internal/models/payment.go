@@ -2,20 +2,126 @@ package models
 
 import (
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Payment methods a payer can choose at checkout.
+const (
+	MethodEWallet        = "EWALLET"         // Redirect to an e-wallet checkout
+	MethodQRCode         = "QR_CODE"         // Scan a QR Ph code with any banking or e-wallet app
+	MethodVirtualAccount = "VIRTUAL_ACCOUNT" // Transfer to a one-off bank account number
+	MethodCash           = "CASH"            // Handed to the treasurer, recorded by an admin
+	MethodBankTransfer   = "BANK_TRANSFER"   // Sent straight to the payee's bank, confirmed with an uploaded receipt
 )
 
 type Payment struct {
-	ID             string    `bson:"_id,omitempty" json:"id"`
-	ReferenceID    string    `bson:"reference_id" json:"reference_id"`
-	PayerID        string    `bson:"payer_id" json:"payer_id"`
-	PayeeID        string    `bson:"payee_id" json:"payee_id"`
-	Amount         float64   `bson:"amount" json:"amount"`
-	Title          string    `bson:"title" json:"title"`             // Payment title
-	Description    string    `bson:"description" json:"description"` // Payment description
-	Status         string    `bson:"status" json:"status"`           // e.g., "PENDING", "COMPLETED", "FAILED"
-	ChargeID       string    `bson:"charge_id" json:"charge_id"`
-	DisbursementID string    `bson:"disbursement_id" json:"disbursement_id"`
-	CheckoutURL    string    `bson:"checkout_url" json:"checkout_url"` // For frontend redirect
-	CreatedAt      time.Time `bson:"created_at" json:"created_at"`
-	UpdatedAt      time.Time `bson:"updated_at" json:"updated_at"`
+	ID             string            `bson:"_id,omitempty" json:"id"`
+	ReferenceID    string            `bson:"reference_id" json:"reference_id"`
+	PayerID        string            `bson:"payer_id" json:"payer_id"`
+	PayeeID        string            `bson:"payee_id" json:"payee_id"`
+	Amount         Money             `bson:"amount" json:"amount"`
+	ObligationID   string            `bson:"obligation_id,omitempty" json:"obligation_id,omitempty"` // Set when paying a collection obligation
+	InvoiceID      string            `bson:"invoice_id,omitempty" json:"invoice_id,omitempty"`       // Invoice of the obligation being paid
+	Breakdown      *PaymentBreakdown `bson:"breakdown,omitempty" json:"breakdown,omitempty"`         // Base, late fees and discounts for obligation payments
+	Fees           *PaymentFees      `bson:"fees,omitempty" json:"fees,omitempty"`                   // Convenience, gateway and platform fees, and the net payout
+	Title          string            `bson:"title" json:"title"`                                     // Payment title
+	Description    string            `bson:"description" json:"description"`                         // Payment description
+	Status         string            `bson:"status" json:"status"`                                   // e.g., "PENDING", "SUCCEEDED", "FAILED", "EXPIRED"; follows the payout once disbursed
+	Method         string            `bson:"method" json:"method"`                                   // One of the Method constants; empty means MethodEWallet
+	ChannelCode    string            `bson:"channel_code" json:"channel_code"`                       // e.g., "PH_GCASH", "QRPH", "BPI"
+	ChargeID       string            `bson:"charge_id" json:"charge_id"`                             // E-wallet charge, QR code or virtual account ID at Xendit
+	DisbursementID string            `bson:"disbursement_id" json:"disbursement_id"`
+	PayoutMode     string            `bson:"payout_mode,omitempty" json:"payout_mode,omitempty"`         // "BATCH" when waiting for a settlement window
+	SettlementID   string            `bson:"settlement_id,omitempty" json:"settlement_id,omitempty"`     // Settlement that paid it out
+	ApprovalID     string            `bson:"approval_id,omitempty" json:"approval_id,omitempty"`         // Payout approval the disbursement is held on
+	ApprovalStatus string            `bson:"approval_status,omitempty" json:"approval_status,omitempty"` // "PENDING", "APPROVED", "REJECTED"
+	PayoutChannel  string            `bson:"payout_channel,omitempty" json:"payout_channel,omitempty"`   // Channel the payee was paid out to
+	CheckoutURL    string            `bson:"checkout_url" json:"checkout_url"`                           // For frontend redirect
+	QRString       string            `bson:"qr_string,omitempty" json:"qr_string,omitempty"`             // QR Ph payload to render as a QR code
+	VirtualAccount *VirtualAccount   `bson:"virtual_account,omitempty" json:"virtual_account,omitempty"`
+	Proof          *ProofOfPayment   `bson:"proof,omitempty" json:"proof,omitempty"`                   // Uploaded receipt, offline payments only
+	Review         *PaymentReview    `bson:"review,omitempty" json:"review,omitempty"`                 // Admin decision on an offline payment
+	ReceiptSeq     int64             `bson:"receipt_seq,omitempty" json:"-"`                           // Numeric part of ReceiptNumber, unique across payments
+	ReceiptNumber  string            `bson:"receipt_number,omitempty" json:"receipt_number,omitempty"` // Official receipt number, taken when first issued
+	ReceiptSentAt  time.Time         `bson:"receipt_sent_at,omitempty" json:"receipt_sent_at,omitempty"`
+	RefundStatus   string            `bson:"refund_status,omitempty" json:"refund_status,omitempty"` // "PARTIALLY_REFUNDED" or "REFUNDED" once a refund succeeds
+	RefundedAmount Money             `bson:"refunded_amount" json:"refunded_amount"`                 // Sum of succeeded refunds
+	RefundReserved int64             `bson:"refund_reserved" json:"-"`                               // Centavos held by pending and succeeded refunds
+	ExpiresAt      time.Time         `bson:"expires_at" json:"expires_at"`                           // PENDING charges are marked EXPIRED after this
+	PaidAt         time.Time         `bson:"paid_at,omitempty" json:"paid_at,omitempty"`             // When the charge succeeded
+	CreatedAt      time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time         `bson:"updated_at" json:"updated_at"`
+}
+
+// ProofOfPayment is a receipt image or document stored in GridFS.
+type ProofOfPayment struct {
+	FileID      primitive.ObjectID `bson:"file_id" json:"file_id"`
+	FileName    string             `bson:"file_name" json:"file_name"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	Size        int64              `bson:"size" json:"size"`
+	UploadedBy  string             `bson:"uploaded_by" json:"uploaded_by"`
+	UploadedAt  time.Time          `bson:"uploaded_at" json:"uploaded_at"`
+}
+
+// PaymentReview records who approved or rejected an offline payment.
+type PaymentReview struct {
+	Decision   string    `bson:"decision" json:"decision"` // "APPROVED" or "REJECTED"
+	Note       string    `bson:"note" json:"note"`
+	ReviewedBy string    `bson:"reviewed_by" json:"reviewed_by"`
+	ReviewedAt time.Time `bson:"reviewed_at" json:"reviewed_at"`
+}
+
+// VirtualAccount is the bank account a payer transfers to for MethodVirtualAccount.
+type VirtualAccount struct {
+	BankCode      string `bson:"bank_code" json:"bank_code"`
+	AccountNumber string `bson:"account_number" json:"account_number"`
+	AccountName   string `bson:"account_name" json:"account_name"`
+}
+
+// PaymentView is a payment joined with the payer it came from.
+type PaymentView struct {
+	Payment    `bson:",inline"`
+	PayerName  string `bson:"payer_name" json:"payer_name"`
+	PayerEmail string `bson:"payer_email" json:"payer_email"`
+}
+
+// PaymentPage is one page of payment search results.
+type PaymentPage struct {
+	Payments    []Payment `json:"payments"`
+	Total       int64     `json:"total"`                 // Payments matching the filters across all pages
+	TotalAmount Money     `json:"total_amount"`          // Sum of their amounts
+	NextCursor  string    `json:"next_cursor,omitempty"` // Pass as cursor for the next page; empty on the last page
+}
+
+// PaymentEvent is a status change pushed to the payer and payee of a payment.
+type PaymentEvent struct {
+	PaymentID      string    `json:"payment_id"`
+	ReferenceID    string    `json:"reference_id"`
+	Status         string    `json:"status"`
+	RefundStatus   string    `json:"refund_status,omitempty"`
+	Title          string    `json:"title"`
+	Amount         Money     `json:"amount"`
+	PayerID        string    `json:"payer_id"`
+	PayeeID        string    `json:"payee_id"`
+	DisbursementID string    `json:"disbursement_id,omitempty"`
+	ReceiptNumber  string    `json:"receipt_number,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// NewPaymentEvent describes the current status of p.
+func NewPaymentEvent(p *Payment) PaymentEvent {
+	return PaymentEvent{
+		PaymentID:      p.ID,
+		ReferenceID:    p.ReferenceID,
+		Status:         p.Status,
+		RefundStatus:   p.RefundStatus,
+		Title:          p.Title,
+		Amount:         p.Amount,
+		PayerID:        p.PayerID,
+		PayeeID:        p.PayeeID,
+		DisbursementID: p.DisbursementID,
+		ReceiptNumber:  p.ReceiptNumber,
+		UpdatedAt:      p.UpdatedAt,
+	}
 }
@@ -0,0 +1,27 @@
+package models
+
+// PenaltyRule charges members who pay a collection after its due date.
+type PenaltyRule struct {
+	Type       string `bson:"type" json:"type"`                                   // "FLAT" or "PERCENT"
+	Amount     Money  `bson:"amount,omitempty" json:"amount,omitempty"`           // Charged per period, FLAT only
+	RateBps    int64  `bson:"rate_bps,omitempty" json:"rate_bps,omitempty"`       // Basis points of the obligation amount per period, PERCENT only (500 = 5%)
+	Recurrence string `bson:"recurrence" json:"recurrence"`                       // "ONCE", "DAILY", "WEEKLY" or "MONTHLY"
+	GraceDays  int    `bson:"grace_days" json:"grace_days"`                       // Days after the due date before the first penalty
+	MaxPeriods int    `bson:"max_periods,omitempty" json:"max_periods,omitempty"` // Caps recurring penalties; zero means no cap
+}
+
+// EarlyDiscount rewards members who pay a collection in full well before its due date.
+type EarlyDiscount struct {
+	Type       string `bson:"type" json:"type"`                             // "FLAT" or "PERCENT"
+	Amount     Money  `bson:"amount,omitempty" json:"amount,omitempty"`     // FLAT only
+	RateBps    int64  `bson:"rate_bps,omitempty" json:"rate_bps,omitempty"` // PERCENT only, basis points of the obligation amount
+	BeforeDays int    `bson:"before_days" json:"before_days"`               // Applies when paid at least this many days before the due date
+}
+
+// PaymentBreakdown shows how the amount charged for an obligation was derived.
+type PaymentBreakdown struct {
+	Base     Money `bson:"base" json:"base"`         // Portion of the obligation being paid
+	Penalty  Money `bson:"penalty" json:"penalty"`   // Late fees newly assessed with this payment
+	Discount Money `bson:"discount" json:"discount"` // Early-payment discount granted with this payment
+	Total    Money `bson:"total" json:"total"`       // Base + Penalty - Discount; what was charged
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BillingPlan issues a collection to its enrolled members every cycle, e.g.
+// monthly dues. Each cycle is an ordinary Collection with PlanID set.
+type BillingPlan struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Title        string             `bson:"title" json:"title"`
+	Description  string             `bson:"description" json:"description"`
+	Amount       Money              `bson:"amount" json:"amount"`                         // Owed per member per full cycle
+	Frequency    string             `bson:"frequency" json:"frequency"`                   // "MONTHLY", "QUARTERLY", "SEMESTRAL" or "CUSTOM"
+	Schedule     string             `bson:"schedule,omitempty" json:"schedule,omitempty"` // Cron expression for CUSTOM, e.g. "0 8 1 1,8 *"
+	DueAfterDays int                `bson:"due_after_days" json:"due_after_days"`         // Obligations fall due this long after a cycle starts
+	Penalty      *PenaltyRule       `bson:"penalty,omitempty" json:"penalty,omitempty"`   // Copied to every cycle's collection
+	Discount     *EarlyDiscount     `bson:"discount,omitempty" json:"discount,omitempty"` // Copied to every cycle's collection
+	Prorate      bool               `bson:"prorate" json:"prorate"`                       // Charge members who join mid-cycle for the remaining days only
+	PayeeID      string             `bson:"payee_id" json:"payee_id"`
+	AllMembers   bool               `bson:"all_members" json:"all_members"` // Every member with role "user" is enrolled
+	Enrollments  []PlanEnrollment   `bson:"enrollments" json:"enrollments"`
+	Status       string             `bson:"status" json:"status"` // "ACTIVE", "PAUSED", "ENDED"
+	StartDate    time.Time          `bson:"start_date" json:"start_date"`
+	EndDate      time.Time          `bson:"end_date,omitempty" json:"end_date,omitempty"` // No cycles start on or after this
+	NextRunAt    time.Time          `bson:"next_run_at" json:"next_run_at"`
+	LastRunAt    time.Time          `bson:"last_run_at,omitempty" json:"last_run_at,omitempty"` // Start of the current cycle
+	CreatedBy    string             `bson:"created_by" json:"created_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// PlanEnrollment records when a member joined a billing plan.
+type PlanEnrollment struct {
+	MemberID   string    `bson:"member_id" json:"member_id"`
+	EnrolledAt time.Time `bson:"enrolled_at" json:"enrolled_at"`
+}
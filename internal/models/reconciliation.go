@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReconciliationReport summarizes one comparison run of the payments
+// collection against Xendit.
+type ReconciliationReport struct {
+	ID         primitive.ObjectID       `bson:"_id,omitempty" json:"id"`
+	StartedAt  time.Time                `bson:"started_at" json:"started_at"`
+	FinishedAt time.Time                `bson:"finished_at" json:"finished_at"`
+	Checked    int                      `bson:"checked" json:"checked"`
+	Fixed      int                      `bson:"fixed" json:"fixed"`
+	Mismatches []ReconciliationMismatch `bson:"mismatches" json:"mismatches"`
+}
+
+// ReconciliationMismatch is a payment whose local state disagreed with the provider.
+type ReconciliationMismatch struct {
+	PaymentID      string `bson:"payment_id" json:"payment_id"`
+	Kind           string `bson:"kind" json:"kind"` // "charge", "disbursement" or "refund"
+	ProviderID     string `bson:"provider_id" json:"provider_id"`
+	LocalStatus    string `bson:"local_status" json:"local_status"`
+	ProviderStatus string `bson:"provider_status" json:"provider_status"`
+	Action         string `bson:"action" json:"action"` // e.g., "updated", "disbursed", "resubmitted", "manual_review"
+	Error          string `bson:"error,omitempty" json:"error,omitempty"`
+}
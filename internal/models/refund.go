@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Refund is money returned to the payer of a completed payment, in full or in part.
+type Refund struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PaymentID   string             `bson:"payment_id" json:"payment_id"`
+	ReferenceID string             `bson:"reference_id" json:"reference_id"`
+	RefundID    string             `bson:"refund_id" json:"refund_id"` // Refund ID at Xendit
+	Amount      Money              `bson:"amount" json:"amount"`
+	Reason      string             `bson:"reason" json:"reason"`
+	Status      string             `bson:"status" json:"status"`                   // "PENDING", "SUCCEEDED", "FAILED"
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"` // Why the refund request to Xendit failed
+	RequestedBy string             `bson:"requested_by" json:"requested_by"`       // Admin user ID
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
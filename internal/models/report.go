@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// ReportSummary is what was collected, refunded and paid out over a period,
+// and what members still owe now.
+type ReportSummary struct {
+	From         *time.Time `json:"from,omitempty"`
+	To           *time.Time `json:"to,omitempty"`
+	Payments     int        `json:"payments"`
+	Collected    Money      `json:"collected"`     // Charges received, fees included
+	Refunded     Money      `json:"refunded"`      // Refunds on those charges
+	Net          Money      `json:"net"`           // Collected - Refunded
+	GatewayFees  Money      `json:"gateway_fees"`  // Kept by Xendit
+	PlatformFees Money      `json:"platform_fees"` // Kept by the platform
+	Disbursed    Money      `json:"disbursed"`     // Paid out to payees
+	Outstanding  Money      `json:"outstanding"`   // Unpaid obligation balances, as of now
+	Overdue      Money      `json:"overdue"`       // Part of Outstanding past its due date
+}
+
+// PeriodTotal is what was collected in one day, week or month.
+type PeriodTotal struct {
+	Period    time.Time `json:"period"` // Start of the period in Asia/Manila
+	Payments  int       `json:"payments"`
+	Collected Money     `json:"collected"`
+	Refunded  Money     `json:"refunded"`
+	Net       Money     `json:"net"`
+}
+
+// CollectionCompletion is how far along a collection is.
+type CollectionCompletion struct {
+	CollectionID   string    `json:"collection_id"`
+	Title          string    `json:"title"`
+	DueDate        time.Time `json:"due_date"`
+	Members        int       `json:"members"`
+	Paid           int       `json:"paid"`
+	Partial        int       `json:"partially_paid"`
+	Unpaid         int       `json:"unpaid"`
+	Waived         int       `json:"waived"`
+	Billed         Money     `json:"billed"` // Amounts plus late fees
+	Collected      Money     `json:"collected"`
+	Outstanding    Money     `json:"outstanding"`
+	CompletionRate float64   `json:"completion_rate"` // Share of members paid or waived, 0 to 1
+}
+
+// DelinquentMember is a member with overdue obligations.
+type DelinquentMember struct {
+	MemberID    string    `json:"member_id"`
+	FullName    string    `json:"fullname"`
+	Email       string    `json:"email"`
+	Obligations int       `json:"obligations"` // Overdue obligations
+	Outstanding Money     `json:"outstanding"` // Balance left on them
+	OldestDue   time.Time `json:"oldest_due"`
+	DaysOverdue int       `json:"days_overdue"` // Since OldestDue
+}
+
+// ChannelTotal is what was collected through one payment method and channel.
+type ChannelTotal struct {
+	Method      string  `json:"method"`
+	ChannelCode string  `json:"channel_code"`
+	Payments    int     `json:"payments"`
+	Collected   Money   `json:"collected"`
+	Fees        Money   `json:"fees"`  // Gateway and platform fees
+	Share       float64 `json:"share"` // Of all collected in the period, 0 to 1
+}
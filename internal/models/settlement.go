@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Payout schedules a payee can choose.
+const (
+	PayoutInstant = "INSTANT" // One disbursement per succeeded charge
+	PayoutDaily   = "DAILY"   // One batched disbursement per day
+	PayoutWeekly  = "WEEKLY"  // One batched disbursement per week, on Mondays
+)
+
+// Settlement is one batched disbursement of a payee's cleared payments.
+type Settlement struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PayeeID        string             `bson:"payee_id" json:"payee_id"`
+	Schedule       string             `bson:"schedule" json:"schedule"`
+	WindowEnd      time.Time          `bson:"window_end" json:"window_end"` // Includes payments created before this
+	PaymentIDs     []string           `bson:"payment_ids" json:"payment_ids"`
+	Count          int                `bson:"count" json:"count"`
+	Gross          Money              `bson:"gross" json:"gross"` // Sum charged to payers
+	Fees           Money              `bson:"fees" json:"fees"`   // Gross minus Net
+	Net            Money              `bson:"net" json:"net"`     // Disbursed to the payee
+	PayoutChannel  string             `bson:"payout_channel" json:"payout_channel"`
+	DisbursementID string             `bson:"disbursement_id" json:"disbursement_id"`
+	ApprovalID     string             `bson:"approval_id,omitempty" json:"approval_id,omitempty"` // Payout approval the disbursement is held on
+	Status         string             `bson:"status" json:"status"`                               // "PROCESSING", "AWAITING_APPROVAL", "REJECTED", "PENDING", "SUCCEEDED", "FAILED"
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// SettlementReport is a settlement with the payments it paid out.
+type SettlementReport struct {
+	Settlement `bson:",inline"`
+	Payments   []Payment `json:"payments"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// StatementEntry is one line of a member's account statement. Amount is
+// positive for what the member is charged and negative for what is taken
+// off, so Balance after each entry is what they owe at that point.
+type StatementEntry struct {
+	Date         time.Time `json:"date"`
+	Type         string    `json:"type"` // "OBLIGATION", "LATE_FEE", "DISCOUNT", "PAYMENT", "ADJUSTMENT" or "REFUND"; refunds do not change the balance
+	Description  string    `json:"description"`
+	Reference    string    `json:"reference,omitempty"` // Receipt number, payment reference or invoice number
+	ObligationID string    `json:"obligation_id,omitempty"`
+	PaymentID    string    `json:"payment_id,omitempty"`
+	Amount       Money     `json:"amount"`
+	Balance      Money     `json:"balance"`
+}
+
+// Statement is a member's account over a date range: what they owed at the
+// start, what happened, and what they owe at the end.
+type Statement struct {
+	MemberID       string           `json:"member_id"`
+	FullName       string           `json:"fullname"`
+	Email          string           `json:"email"`
+	From           *time.Time       `json:"from,omitempty"`
+	To             time.Time        `json:"to"`
+	OpeningBalance Money            `json:"opening_balance"`
+	Charges        Money            `json:"charges"` // Sum of positive entries in the range
+	Credits        Money            `json:"credits"` // Sum of negative entries in the range, as a positive amount
+	ClosingBalance Money            `json:"closing_balance"`
+	Entries        []StatementEntry `json:"entries"`
+}
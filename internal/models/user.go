@@ -7,11 +7,15 @@ import (
 )
 
 type User struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	FullName    string             `bson:"fullname" json:"fullname"`
-	Email       string             `bson:"email" json:"email"`
-	HPassword   string             `bson:"password" json:"password"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	GCashNumber string             `bson:"gcash_number" json:"gcash_number"` // e.g., "09123456789"
-	Role        string             `bson:"role" json:"role"`                 // e.g., "admin", "user"
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FullName       string             `bson:"fullname" json:"fullname"`
+	Email          string             `bson:"email" json:"email"`
+	HPassword      string             `bson:"password" json:"password"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	GCashNumber    string             `bson:"gcash_number" json:"gcash_number"`                           // e.g., "09123456789"
+	Role           string             `bson:"role" json:"role"`                                           // e.g., "admin", "user"
+	PayoutChannel  string             `bson:"payout_channel,omitempty" json:"payout_channel,omitempty"`   // e.g., "PH_PAYMAYA"; empty pays out to GCashNumber
+	PayoutAccount  string             `bson:"payout_account,omitempty" json:"payout_account,omitempty"`   // Account number on PayoutChannel
+	PayoutSchedule string             `bson:"payout_schedule,omitempty" json:"payout_schedule,omitempty"` // PayoutInstant (default), PayoutDaily or PayoutWeekly
+	PayoutUpdated  time.Time          `bson:"payout_updated_at,omitempty" json:"payout_updated_at,omitempty"`
 }
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Events an outbound webhook endpoint can subscribe to.
+const (
+	EventPaymentSucceeded      = "payment.succeeded"      // The payer's charge went through
+	EventPaymentFailed         = "payment.failed"         // The charge was declined or voided
+	EventPaymentExpired        = "payment.expired"        // The charge was not completed in time
+	EventPaymentRefunded       = "payment.refunded"       // A refund on the payment succeeded
+	EventPaymentDisbursed      = "payment.disbursed"      // The payee was paid out
+	EventAnnouncementPublished = "announcement.published" // A new announcement was posted
+	EventAnnouncementUpdated   = "announcement.updated"   // An announcement was edited
+	EventWebhookPing           = "webhook.ping"           // Sent on request to test an endpoint
+)
+
+// WebhookEvents lists the events endpoints may subscribe to. "*" subscribes to all.
+var WebhookEvents = []string{
+	EventPaymentSucceeded,
+	EventPaymentFailed,
+	EventPaymentExpired,
+	EventPaymentRefunded,
+	EventPaymentDisbursed,
+	EventAnnouncementPublished,
+	EventAnnouncementUpdated,
+}
+
+// WebhookEndpoint is a URL outside NotiPay that is sent the events it
+// subscribes to, signed with its secret.
+type WebhookEndpoint struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL         string             `bson:"url" json:"url"`
+	Description string             `bson:"description" json:"description"`
+	Events      []string           `bson:"events" json:"events"`
+	Secret      string             `bson:"secret" json:"secret,omitempty"` // Only returned when created or rotated
+	Active      bool               `bson:"active" json:"active"`
+	CreatedBy   string             `bson:"created_by" json:"created_by"` // Admin user ID
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// WebhookAttempt is one try at delivering an event.
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMs int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// WebhookDelivery is one event queued for one endpoint, and the log of
+// attempts to deliver it.
+type WebhookDelivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EndpointID    string             `bson:"endpoint_id" json:"endpoint_id"`
+	EventID       string             `bson:"event_id" json:"event_id"` // Shared by every endpoint's copy of the event
+	Event         string             `bson:"event" json:"event"`
+	Payload       string             `bson:"payload" json:"payload"` // JSON body as sent
+	Status        string             `bson:"status" json:"status"`   // "PENDING", "SUCCEEDED", "FAILED"
+	Attempts      []WebhookAttempt   `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	DeliveredAt   time.Time          `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// AdjustObligation lets an admin take money off what a member owes. WAIVE
+// clears the whole remaining balance and ignores amount; DISCOUNT and CREDIT
+// take off the given amount. Balance already covered by pending charges
+// cannot be adjusted.
+func (s *PaymentService) AdjustObligation(ctx context.Context, obligationID, adjType string, amount models.Money, reason, adminID string) (*models.Adjustment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	adjType = strings.ToUpper(strings.TrimSpace(adjType))
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	switch adjType {
+	case "WAIVE", "DISCOUNT", "CREDIT":
+	default:
+		return nil, fmt.Errorf("invalid adjustment type %q, must be WAIVE, DISCOUNT or CREDIT", adjType)
+	}
+
+	obligation, err := s.getObligation(ctx, obligationID)
+	if err != nil {
+		return nil, err
+	}
+	// Installments cannot start while the pending total is being read
+	token, err := s.lockObligation(ctx, obligation.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.unlockObligation(obligation.ID, token)
+	if obligation, err = s.getObligation(ctx, obligationID); err != nil {
+		return nil, err
+	}
+	if obligation.Status == "PAID" || obligation.Status == "WAIVED" {
+		return nil, fmt.Errorf("obligation is already settled")
+	}
+	pending, err := s.pendingObligationTotal(ctx, obligation)
+	if err != nil {
+		return nil, err
+	}
+	balance := obligationBalance(obligation)
+	available := balance.Centavos - pending.Base
+	if available <= 0 {
+		return nil, fmt.Errorf("the remaining balance of %s is covered by payments still pending", balance.String())
+	}
+
+	if adjType == "WAIVE" {
+		amount = models.NewMoney(available, balance.Currency)
+	}
+	if amount.Currency != balance.Currency || !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be a positive %s amount", balance.Currency)
+	}
+	if amount.Centavos > available {
+		return nil, fmt.Errorf("amount %s exceeds the remaining balance of %s", amount.String(), models.NewMoney(available, balance.Currency).String())
+	}
+
+	// Record the adjustment first, so a balance is never reduced without one
+	now := time.Now()
+	adjustment := &models.Adjustment{
+		ID:           primitive.NewObjectID(),
+		ObligationID: obligationID,
+		CollectionID: obligation.CollectionID,
+		MemberID:     obligation.MemberID,
+		Type:         adjType,
+		Amount:       amount,
+		BalanceAfter: models.NewMoney(balance.Centavos-amount.Centavos, balance.Currency),
+		Reason:       reason,
+		CreatedBy:    adminID,
+		CreatedAt:    now,
+	}
+	if _, err := s.db.Collection("adjustments").InsertOne(ctx, adjustment); err != nil {
+		log.Printf("Failed to record adjustment on obligation %s: %v", obligationID, err)
+		return nil, fmt.Errorf("failed to record adjustment: %v", err)
+	}
+
+	update := []bson.M{
+		{"$set": bson.M{
+			"adjustments": bson.M{
+				"centavos": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$adjustments.centavos", 0}}, amount.Centavos}},
+				"currency": amount.Currency,
+			},
+		}},
+	}
+	update = append(update, recomputeObligationStages(now)...)
+
+	// Matching the balance read above keeps concurrent adjustments from stacking
+	// (or its absence, on obligations issued before balances were tracked)
+	filter := bson.M{"_id": obligation.ID, "balance.centavos": balance.Centavos}
+	if obligation.Balance.Currency == "" {
+		filter = bson.M{"_id": obligation.ID, "balance": bson.M{"$exists": false}}
+	}
+	var updated models.Obligation
+	err = s.db.Collection("obligations").FindOneAndUpdate(ctx,
+		filter,
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		s.discardAdjustment(adjustment.ID)
+		return nil, fmt.Errorf("obligation balance changed, please retry")
+	}
+	if err != nil {
+		// The update may still have gone through, so the record stays
+		log.Printf("Failed to adjust obligation %s, keeping adjustment %s for review: %v", obligationID, adjustment.ID.Hex(), err)
+		return nil, fmt.Errorf("failed to adjust obligation, check adjustment %s before retrying: %v", adjustment.ID.Hex(), err)
+	}
+	if updated.Balance != adjustment.BalanceAfter {
+		adjustment.BalanceAfter = updated.Balance
+		if _, err := s.db.Collection("adjustments").UpdateOne(ctx, bson.M{"_id": adjustment.ID}, bson.M{
+			"$set": bson.M{"balance_after": updated.Balance},
+		}); err != nil {
+			log.Printf("Failed to update balance after adjustment %s: %v", adjustment.ID.Hex(), err)
+		}
+	}
+	recordLedger(ctx, s.db, adjustmentLedger(obligation, adjustment))
+	syncInvoice(ctx, s.db, obligationID)
+
+	log.Printf("Obligation %s adjusted: Type=%s, Amount=%s, By=%s", obligationID, adjType, amount.String(), adminID)
+	return adjustment, nil
+}
+
+// discardAdjustment deletes an adjustment whose obligation update did not go
+// through.
+func (s *PaymentService) discardAdjustment(id primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.db.Collection("adjustments").DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		log.Printf("Failed to discard adjustment %s: %v", id.Hex(), err)
+	}
+}
+
+// GetAdjustments lists the adjustment history of an obligation, newest first.
+func (s *PaymentService) GetAdjustments(ctx context.Context, obligationID string) ([]models.Adjustment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("adjustments").Find(ctx, bson.M{"obligation_id": obligationID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		log.Printf("Failed to fetch adjustments for obligation %s: %v", obligationID, err)
+		return nil, fmt.Errorf("failed to fetch adjustments: %v", err)
+	}
+	adjustments := []models.Adjustment{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &adjustments); err != nil {
+		return nil, fmt.Errorf("failed to decode adjustments: %v", err)
+	}
+	return adjustments, nil
+}
@@ -50,6 +50,7 @@ func (s *AnnouncementService) CreateAnnouncement(ctx context.Context, announceme
 	if err != nil {
 		return primitive.ObjectID{}, err
 	}
+	emitWebhook(ctx, s.collection.Database(), models.EventAnnouncementPublished, bson.M{"announcement": announcement})
 
 	return result.InsertedID.(primitive.ObjectID), nil
 }
@@ -105,8 +106,13 @@ func (s *AnnouncementService) UpdateAnnouncement(ctx context.Context, announceme
 		},
 	}
 
-	_, err = s.collection.UpdateOne(ctx, bson.M{"_id": announcement.ID}, update)
-	return err
+	if _, err := s.collection.UpdateOne(ctx, bson.M{"_id": announcement.ID}, update); err != nil {
+		return err
+	}
+	if updated, err := s.GetAnnouncementByID(ctx, announcement.ID); err == nil {
+		emitWebhook(ctx, s.collection.Database(), models.EventAnnouncementUpdated, bson.M{"announcement": updated})
+	}
+	return nil
 }
 
 // DeleteAnnouncement removes an announcement by its ID
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// approvalPolicyID is the single payout approval policy document.
+const approvalPolicyID = "default"
+
+// heldApprovalStatuses are the approval states a payout cannot be sent in.
+var heldApprovalStatuses = []string{"PENDING", "REJECTED"}
+
+// payoutApprovalPolicy returns the payout approval policy. Without one no
+// payout needs approval.
+func (s *PaymentService) payoutApprovalPolicy(ctx context.Context) (*models.PayoutApprovalPolicy, error) {
+	var policy models.PayoutApprovalPolicy
+	err := s.db.Collection("payout_approval_policies").FindOne(ctx, bson.M{"_id": approvalPolicyID}).Decode(&policy)
+	if err == mongo.ErrNoDocuments {
+		return &models.PayoutApprovalPolicy{ID: approvalPolicyID, AmountThreshold: models.NewMoney(0, "PHP"), RequiredApprovals: 1}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch payout approval policy: %v", err)
+	}
+	return &policy, nil
+}
+
+// GetPayoutApprovalPolicy returns the payout approval policy in force.
+func (s *PaymentService) GetPayoutApprovalPolicy(ctx context.Context) (*models.PayoutApprovalPolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return s.payoutApprovalPolicy(ctx)
+}
+
+// SetPayoutApprovalPolicy replaces the payout approval policy. Payouts already
+// held keep waiting on the approvals they were opened with.
+func (s *PaymentService) SetPayoutApprovalPolicy(ctx context.Context, policy *models.PayoutApprovalPolicy, adminID string) (*models.PayoutApprovalPolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if policy.AmountThreshold.Centavos < 0 || policy.NewPayoutHours < 0 {
+		return nil, fmt.Errorf("amount_threshold and new_payout_hours cannot be negative")
+	}
+	if policy.RequiredApprovals != 1 && policy.RequiredApprovals != 2 {
+		return nil, fmt.Errorf("required_approvals must be 1 or 2")
+	}
+	policy.ID = approvalPolicyID
+	policy.UpdatedBy = adminID
+	policy.UpdatedAt = time.Now()
+	_, err := s.db.Collection("payout_approval_policies").ReplaceOne(ctx, bson.M{"_id": approvalPolicyID}, policy, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("Failed to save payout approval policy: %v", err)
+		return nil, fmt.Errorf("failed to save payout approval policy: %v", err)
+	}
+	log.Printf("Payout approval policy set: Threshold=%s, NewPayoutHours=%d, Approvals=%d, By=%s", policy.AmountThreshold.String(), policy.NewPayoutHours, policy.RequiredApprovals, adminID)
+	return policy, nil
+}
+
+// holdPayout checks a payout of amount to payee against the approval policy.
+// It returns nil when the payout may be sent now, or else the approval it is
+// held on, opening one when needed. An approval only clears the destination
+// it was given for, so a payee changing their number afterwards is held again.
+func (s *PaymentService) holdPayout(ctx context.Context, payee *models.User, amount models.Money, approvalID, paymentID, settlementID string) (*models.PayoutApproval, error) {
+	channel, account, err := payoutDestination(payee)
+	if err != nil {
+		// sendDisbursement reports the missing destination
+		return nil, nil
+	}
+
+	if approvalID != "" {
+		objID, err := primitive.ObjectIDFromHex(approvalID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid approval_id format: %v", err)
+		}
+		var approval models.PayoutApproval
+		if err := s.db.Collection("payout_approvals").FindOne(ctx, bson.M{"_id": objID}).Decode(&approval); err != nil {
+			return nil, fmt.Errorf("failed to fetch payout approval: %v", err)
+		}
+		if approval.Status != "APPROVED" {
+			return &approval, nil
+		}
+		if approval.PayoutChannel == channel && approval.PayoutAccount == account {
+			return nil, nil
+		}
+		log.Printf("Payee %s changed payout account after approval %s, holding again", approval.PayeeID, approvalID)
+	}
+
+	policy, err := s.payoutApprovalPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var reasons []string
+	if policy.AmountThreshold.IsPositive() && amount.Centavos >= policy.AmountThreshold.Centavos {
+		reasons = append(reasons, "AMOUNT_THRESHOLD")
+	}
+	if policy.NewPayoutHours > 0 && !payee.PayoutUpdated.IsZero() &&
+		time.Since(payee.PayoutUpdated) < time.Duration(policy.NewPayoutHours)*time.Hour {
+		reasons = append(reasons, "NEW_PAYOUT_ACCOUNT")
+	}
+	if len(reasons) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	approval := &models.PayoutApproval{
+		ID:                primitive.NewObjectID(),
+		PaymentID:         paymentID,
+		SettlementID:      settlementID,
+		PayeeID:           payee.ID.Hex(),
+		Amount:            amount,
+		PayoutChannel:     channel,
+		PayoutAccount:     account,
+		Reasons:           reasons,
+		RequiredApprovals: policy.RequiredApprovals,
+		Decisions:         []models.PayoutDecision{},
+		Status:            "PENDING",
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if _, err := s.db.Collection("payout_approvals").InsertOne(ctx, approval); err != nil {
+		log.Printf("Failed to save payout approval: %v", err)
+		return nil, fmt.Errorf("failed to save payout approval: %v", err)
+	}
+	log.Printf("Payout held for approval %s: Payee=%s, Amount=%s, Reasons=%s", approval.ID.Hex(), approval.PayeeID, amount.String(), strings.Join(reasons, ","))
+	return approval, nil
+}
+
+// GetPendingPayoutApprovals lists the payouts waiting for approval, oldest first.
+func (s *PaymentService) GetPendingPayoutApprovals(ctx context.Context) ([]models.PayoutApproval, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("payout_approvals").Find(ctx, bson.M{"status": "PENDING"}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		log.Printf("Failed to fetch pending payout approvals: %v", err)
+		return nil, fmt.Errorf("failed to fetch pending approvals: %v", err)
+	}
+	approvals := []models.PayoutApproval{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &approvals); err != nil {
+		return nil, fmt.Errorf("failed to decode pending approvals: %v", err)
+	}
+	return approvals, nil
+}
+
+// ReviewPayoutApproval records an admin's decision on a held payout. One
+// rejection rejects it; once enough distinct admins approve, the payout is
+// sent. Admins cannot decide payouts to themselves.
+func (s *PaymentService) ReviewPayoutApproval(ctx context.Context, approvalID, adminID string, approve bool, note string) (*models.PayoutApproval, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(approvalID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid approval_id format: %v", err)
+	}
+	var approval models.PayoutApproval
+	if err := s.db.Collection("payout_approvals").FindOne(ctx, bson.M{"_id": objID}).Decode(&approval); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("approval not found")
+		}
+		return nil, fmt.Errorf("failed to fetch payout approval: %v", err)
+	}
+	if approval.Status != "PENDING" {
+		return nil, fmt.Errorf("payout has already been %s", strings.ToLower(approval.Status))
+	}
+	if approval.PayeeID == adminID {
+		return nil, fmt.Errorf("admins cannot decide payouts to themselves")
+	}
+
+	decision := models.PayoutDecision{
+		AdminID:   adminID,
+		Decision:  "REJECT",
+		Note:      strings.TrimSpace(note),
+		DecidedAt: time.Now(),
+	}
+	update := bson.M{
+		"$push": bson.M{"decisions": decision},
+		"$set":  bson.M{"status": "REJECTED", "updated_at": decision.DecidedAt},
+	}
+	if approve {
+		decision.Decision = "APPROVE"
+		update = bson.M{
+			"$push": bson.M{"decisions": decision},
+			"$set":  bson.M{"updated_at": decision.DecidedAt},
+		}
+	}
+
+	// An admin counts once, and nobody can decide after the outcome is set
+	after := options.After
+	err = s.db.Collection("payout_approvals").FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "status": "PENDING", "decisions.admin_id": bson.M{"$ne": adminID}},
+		update,
+		&options.FindOneAndUpdateOptions{ReturnDocument: &after},
+	).Decode(&approval)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("payout has already been decided or approved by this admin")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record decision: %v", err)
+	}
+	log.Printf("Payout approval %s: %s by %s", approvalID, decision.Decision, adminID)
+
+	if approve {
+		approvals := 0
+		for _, d := range approval.Decisions {
+			if d.Decision == "APPROVE" {
+				approvals++
+			}
+		}
+		if approvals < approval.RequiredApprovals {
+			return &approval, nil
+		}
+		result, err := s.db.Collection("payout_approvals").UpdateOne(ctx, bson.M{"_id": objID, "status": "PENDING"}, bson.M{
+			"$set": bson.M{"status": "APPROVED", "updated_at": time.Now()},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to approve payout: %v", err)
+		}
+		if result.ModifiedCount == 0 {
+			return &approval, nil
+		}
+		approval.Status = "APPROVED"
+	}
+
+	s.releaseApproval(ctx, &approval)
+	return &approval, nil
+}
+
+// releaseApproval applies a decided approval to the payment or settlement it
+// holds, sending the payout when it was approved.
+func (s *PaymentService) releaseApproval(ctx context.Context, approval *models.PayoutApproval) {
+	if approval.PaymentID != "" {
+		_, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": approval.PaymentID, "approval_id": approval.ID.Hex()}, bson.M{
+			"$set": bson.M{"approval_status": approval.Status, "updated_at": time.Now()},
+		})
+		if err != nil {
+			log.Printf("Failed to update approval status of payment %s: %v", approval.PaymentID, err)
+			return
+		}
+		if approval.Status == "APPROVED" {
+			if err := s.CreateDisbursement(ctx, approval.PaymentID); err != nil {
+				log.Printf("Failed to disburse approved payment %s: %v", approval.PaymentID, err)
+			}
+		}
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(approval.SettlementID)
+	if err != nil {
+		log.Printf("Invalid settlement_id %s on approval %s", approval.SettlementID, approval.ID.Hex())
+		return
+	}
+	var settlement models.Settlement
+	if err := s.db.Collection("settlements").FindOne(ctx, bson.M{"_id": objID, "approval_id": approval.ID.Hex()}).Decode(&settlement); err != nil {
+		log.Printf("Failed to fetch settlement %s for approval %s: %v", approval.SettlementID, approval.ID.Hex(), err)
+		return
+	}
+	if approval.Status == "REJECTED" {
+		// The payments stay claimed so they are not batched again
+		settlement.Status = "REJECTED"
+		s.updateSettlement(ctx, &settlement)
+		return
+	}
+	var payee models.User
+	payeeObjID, _ := primitive.ObjectIDFromHex(settlement.PayeeID)
+	if err := s.db.Collection("user").FindOne(ctx, bson.M{"_id": payeeObjID}).Decode(&payee); err != nil {
+		log.Printf("Failed to fetch payee %s for settlement %s: %v", settlement.PayeeID, approval.SettlementID, err)
+		return
+	}
+	s.disburseSettlement(ctx, &settlement, &payee)
+}
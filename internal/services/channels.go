@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// paymentChannel describes a Xendit channel payers can pay through. E-wallet
+// channels double as payout channels for payees.
+type paymentChannel struct {
+	Name string
+	// Method is models.MethodEWallet, models.MethodQRCode or models.MethodVirtualAccount.
+	Method string
+	// MinCentavos and MaxCentavos are the per-charge limits Xendit enforces.
+	MinCentavos int64
+	MaxCentavos int64
+	// RequiresMobile means the charge needs channel_properties.mobile_number.
+	RequiresMobile bool
+	// RequiresCancelURL means the charge needs channel_properties.cancel_redirect_url.
+	RequiresCancelURL bool
+}
+
+// paymentChannels are the channels payers can choose from, keyed by Xendit channel code.
+var paymentChannels = map[string]paymentChannel{
+	"PH_GCASH":     {Name: "GCash", Method: models.MethodEWallet, MinCentavos: 100, MaxCentavos: 10000000, RequiresMobile: true},
+	"PH_PAYMAYA":   {Name: "Maya", Method: models.MethodEWallet, MinCentavos: 100, MaxCentavos: 10000000, RequiresMobile: true, RequiresCancelURL: true},
+	"PH_GRABPAY":   {Name: "GrabPay", Method: models.MethodEWallet, MinCentavos: 100, MaxCentavos: 10000000},
+	"PH_SHOPEEPAY": {Name: "ShopeePay", Method: models.MethodEWallet, MinCentavos: 100, MaxCentavos: 10000000},
+	"QRPH":         {Name: "QR Ph", Method: models.MethodQRCode, MinCentavos: 100, MaxCentavos: 5000000},
+	"BPI":          {Name: "BPI", Method: models.MethodVirtualAccount, MinCentavos: 100, MaxCentavos: 5000000},
+	"UNIONBANK":    {Name: "UnionBank", Method: models.MethodVirtualAccount, MinCentavos: 100, MaxCentavos: 5000000},
+	"RCBC":         {Name: "RCBC", Method: models.MethodVirtualAccount, MinCentavos: 100, MaxCentavos: 5000000},
+}
+
+// defaultChannels is the channel used for a method when the payer did not pick one.
+// Virtual accounts have no default; the payer must choose a bank.
+var defaultChannels = map[string]string{
+	models.MethodEWallet: "PH_GCASH",
+	models.MethodQRCode:  "QRPH",
+}
+
+// defaultChannel is used when a payee has not picked a payout channel.
+const defaultChannel = "PH_GCASH"
+
+// lookupChannel resolves the method and channel code a payer chose. An empty
+// method means an e-wallet, and an empty code uses the method's default.
+func lookupChannel(method, code string) (string, paymentChannel, error) {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = models.MethodEWallet
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = defaultChannels[method]
+	}
+	if code == "" {
+		return "", paymentChannel{}, fmt.Errorf("channel_code is required for %s", method)
+	}
+	channel, ok := paymentChannels[code]
+	if !ok {
+		return "", paymentChannel{}, fmt.Errorf("unsupported channel %q", code)
+	}
+	if channel.Method != method {
+		return "", paymentChannel{}, fmt.Errorf("channel %s does not support %s", code, method)
+	}
+	return code, channel, nil
+}
+
+// validateChannelAmount checks that amount is a positive PHP amount within
+// the limits of the given channel.
+func validateChannelAmount(code string, amount models.Money) error {
+	if amount.Currency != "PHP" {
+		return fmt.Errorf("unsupported currency %q, must be PHP", amount.Currency)
+	}
+	if !amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	channel, ok := paymentChannels[code]
+	if !ok {
+		return fmt.Errorf("unsupported channel %q", code)
+	}
+	if amount.Centavos < channel.MinCentavos {
+		return fmt.Errorf("amount must be at least %s for %s", models.NewMoney(channel.MinCentavos, "PHP").String(), code)
+	}
+	if amount.Centavos > channel.MaxCentavos {
+		return fmt.Errorf("amount must be at most %s for %s", models.NewMoney(channel.MaxCentavos, "PHP").String(), code)
+	}
+	return nil
+}
+
+// normalizeMobileNumber accepts a Philippine mobile number as 09XXXXXXXXX,
+// 639XXXXXXXXX or +639XXXXXXXXX and returns the +63 form Xendit expects.
+func normalizeMobileNumber(number string) (string, error) {
+	number = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(number))
+	var local string
+	switch {
+	case strings.HasPrefix(number, "+63"):
+		local = number[3:]
+	case strings.HasPrefix(number, "63"):
+		local = number[2:]
+	case strings.HasPrefix(number, "0"):
+		local = number[1:]
+	default:
+		return "", fmt.Errorf("mobile number must start with 09 or +639")
+	}
+	if len(local) != 10 || local[0] != '9' {
+		return "", fmt.Errorf("mobile number must be 11 digits starting with 09")
+	}
+	for _, c := range local {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("mobile number must contain only digits")
+		}
+	}
+	return "+63" + local, nil
+}
+
+// payoutDestination returns the channel and account number a payee is paid
+// out to. Payees without a payout channel are paid to their GCash number.
+func payoutDestination(payee *models.User) (string, string, error) {
+	code := payee.PayoutChannel
+	account := payee.PayoutAccount
+	if code == "" {
+		code = defaultChannel
+	}
+	if channel, ok := paymentChannels[code]; !ok || channel.Method != models.MethodEWallet {
+		return "", "", fmt.Errorf("unsupported payout channel %q", code)
+	}
+	if account == "" && code == defaultChannel {
+		account = payee.GCashNumber
+	}
+	if account == "" {
+		return "", "", fmt.Errorf("payee has no %s account number", code)
+	}
+	return code, account, nil
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// CollectionService manages collections issued by admins and the per-member
+// obligations they generate.
+type CollectionService struct {
+	db *mongo.Database
+}
+
+// NewCollectionService initializes a new CollectionService
+func NewCollectionService(db *mongo.Database) *CollectionService {
+	_, err := db.Collection("obligations").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "collection_id", Value: 1}, {Key: "member_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "member_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	if err != nil {
+		log.Fatalf("error creating index for obligations: %v", err)
+	}
+	return &CollectionService{db: db}
+}
+
+// CreateCollection saves a collection and issues an UNPAID obligation to each
+// targeted member, or to every member with role "user" when AllMembers is set.
+func (s *CollectionService) CreateCollection(ctx context.Context, collection *models.Collection) (*models.Collection, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	collection.Title = strings.TrimSpace(collection.Title)
+	collection.Description = strings.TrimSpace(collection.Description)
+	if collection.Title == "" {
+		return nil, fmt.Errorf("title cannot be empty")
+	}
+	if collection.Amount.Currency != "PHP" || !collection.Amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be a positive PHP amount")
+	}
+	if collection.DueDate.IsZero() {
+		return nil, fmt.Errorf("due_date is required")
+	}
+	if err := validatePenaltyRule(collection.Penalty, collection.Amount); err != nil {
+		return nil, err
+	}
+	if err := validateEarlyDiscount(collection.Discount, collection.Amount); err != nil {
+		return nil, err
+	}
+	if collection.PayeeID == "" {
+		collection.PayeeID = collection.CreatedBy
+	}
+	if _, err := primitive.ObjectIDFromHex(collection.PayeeID); err != nil {
+		return nil, fmt.Errorf("invalid payee_id format: %v", err)
+	}
+
+	memberIDs, err := s.resolveMembers(ctx, collection.AllMembers, collection.TargetMemberIDs)
+	if err != nil {
+		return nil, err
+	}
+	amounts := make(map[string]models.Money, len(memberIDs))
+	for _, memberID := range memberIDs {
+		amounts[memberID] = collection.Amount
+	}
+	return s.issueCollection(ctx, collection, amounts)
+}
+
+// issueCollection saves a collection and one UNPAID obligation per member for
+// the given amounts.
+func (s *CollectionService) issueCollection(ctx context.Context, collection *models.Collection, amounts map[string]models.Money) (*models.Collection, error) {
+	if len(amounts) == 0 {
+		return nil, fmt.Errorf("collection has no target members")
+	}
+	collection.TargetMemberIDs = make([]string, 0, len(amounts))
+	for memberID := range amounts {
+		collection.TargetMemberIDs = append(collection.TargetMemberIDs, memberID)
+	}
+	sort.Strings(collection.TargetMemberIDs)
+
+	now := time.Now()
+	collection.ID = primitive.NewObjectID()
+	collection.CreatedAt = now
+	collection.UpdatedAt = now
+	if _, err := s.db.Collection("collections").InsertOne(ctx, collection); err != nil {
+		log.Printf("Failed to save collection: %v", err)
+		return nil, fmt.Errorf("failed to save collection: %v", err)
+	}
+
+	obligations := make([]models.Obligation, 0, len(amounts))
+	docs := make([]interface{}, 0, len(amounts))
+	for _, memberID := range collection.TargetMemberIDs {
+		o := newObligation(collection, memberID, amounts[memberID], now)
+		obligations = append(obligations, o)
+		docs = append(docs, o)
+	}
+	if _, err := s.db.Collection("obligations").InsertMany(ctx, docs); err != nil {
+		log.Printf("Failed to issue obligations for collection %s: %v", collection.ID.Hex(), err)
+		return nil, fmt.Errorf("failed to issue obligations: %v", err)
+	}
+	for i := range obligations {
+		recordLedger(ctx, s.db, obligationLedger(&obligations[i]))
+		if _, err := issueInvoice(ctx, s.db, &obligations[i]); err != nil {
+			log.Printf("Failed to invoice obligation %s: %v", obligations[i].ID.Hex(), err)
+		}
+	}
+
+	log.Printf("Collection created: ID=%s, Title=%s, Members=%d", collection.ID.Hex(), collection.Title, len(amounts))
+	return collection, nil
+}
+
+// issueObligation adds one member to an existing collection.
+func (s *CollectionService) issueObligation(ctx context.Context, collection *models.Collection, memberID string, amount models.Money) error {
+	now := time.Now()
+	obligation := newObligation(collection, memberID, amount, now)
+	if _, err := s.db.Collection("obligations").InsertOne(ctx, obligation); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to issue obligation: %v", err)
+	}
+	recordLedger(ctx, s.db, obligationLedger(&obligation))
+	if _, err := issueInvoice(ctx, s.db, &obligation); err != nil {
+		log.Printf("Failed to invoice obligation %s: %v", obligation.ID.Hex(), err)
+	}
+	_, err := s.db.Collection("collections").UpdateOne(ctx, bson.M{"_id": collection.ID}, bson.M{
+		"$addToSet": bson.M{"target_member_ids": memberID},
+		"$set":      bson.M{"updated_at": now},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update collection: %v", err)
+	}
+	log.Printf("Obligation issued: Collection=%s, Member=%s, Amount=%s", collection.ID.Hex(), memberID, amount.String())
+	return nil
+}
+
+func newObligation(collection *models.Collection, memberID string, amount models.Money, now time.Time) models.Obligation {
+	return models.Obligation{
+		ID:           primitive.NewObjectID(),
+		CollectionID: collection.ID.Hex(),
+		MemberID:     memberID,
+		PayeeID:      collection.PayeeID,
+		Title:        collection.Title,
+		Description:  collection.Description,
+		Amount:       amount,
+		DueDate:      collection.DueDate,
+		Status:       "UNPAID",
+		Penalties:    models.NewMoney(0, amount.Currency),
+		Discounts:    models.NewMoney(0, amount.Currency),
+		Adjustments:  models.NewMoney(0, amount.Currency),
+		AmountPaid:   models.NewMoney(0, amount.Currency),
+		Balance:      amount,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// resolveMembers returns the hex IDs of the members a collection targets,
+// checking that explicitly listed members exist.
+func (s *CollectionService) resolveMembers(ctx context.Context, allMembers bool, ids []string) ([]string, error) {
+	filter := bson.M{"role": "user"}
+	var objIDs []primitive.ObjectID
+	if !allMembers {
+		seen := make(map[primitive.ObjectID]bool, len(ids))
+		for _, id := range ids {
+			id = strings.TrimSpace(id)
+			objID, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return nil, fmt.Errorf("invalid member id %q: %v", id, err)
+			}
+			if seen[objID] {
+				continue
+			}
+			seen[objID] = true
+			objIDs = append(objIDs, objID)
+		}
+		filter = bson.M{"_id": bson.M{"$in": objIDs}}
+	}
+
+	cur, err := s.db.Collection("user").Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		log.Printf("Failed to fetch members: %v", err)
+		return nil, fmt.Errorf("failed to fetch members: %v", err)
+	}
+	var users []models.User
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("failed to decode members: %v", err)
+	}
+	if !allMembers && len(users) != len(objIDs) {
+		return nil, fmt.Errorf("some target members were not found")
+	}
+
+	memberIDs := make([]string, 0, len(users))
+	for _, u := range users {
+		memberIDs = append(memberIDs, u.ID.Hex())
+	}
+	return memberIDs, nil
+}
+
+// GetCollections retrieves all collections, newest first.
+func (s *CollectionService) GetCollections(ctx context.Context) ([]models.Collection, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("collections").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collections: %v", err)
+	}
+	collections := []models.Collection{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &collections); err != nil {
+		return nil, fmt.Errorf("failed to decode collections: %v", err)
+	}
+	return collections, nil
+}
+
+// GetCollection retrieves a collection by its ID.
+func (s *CollectionService) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collection_id format: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var collection models.Collection
+	if err := s.db.Collection("collections").FindOne(ctx, bson.M{"_id": objID}).Decode(&collection); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("collection not found")
+		}
+		return nil, fmt.Errorf("failed to fetch collection: %v", err)
+	}
+	return &collection, nil
+}
+
+// GetCollectionStatus returns who has and hasn't paid a collection.
+func (s *CollectionService) GetCollectionStatus(ctx context.Context, id string) (*models.CollectionStatus, error) {
+	collection, err := s.GetCollection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"collection_id": id}},
+		{"$lookup": bson.M{
+			"from":     "user",
+			"let":      bson.M{"member": bson.M{"$toObjectId": "$member_id"}},
+			"pipeline": []bson.M{{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$member"}}}}},
+			"as":       "member",
+		}},
+		{"$unwind": bson.M{"path": "$member", "preserveNullAndEmptyArrays": true}},
+		{"$addFields": bson.M{"fullname": "$member.fullname", "email": "$member.email"}},
+		{"$project": bson.M{"member": 0}},
+		{"$sort": bson.D{{Key: "status", Value: -1}, {Key: "fullname", Value: 1}}},
+	}
+	cur, err := s.db.Collection("obligations").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("Failed to fetch roster for collection %s: %v", id, err)
+		return nil, fmt.Errorf("failed to fetch roster: %v", err)
+	}
+	members := []models.ObligationView{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &members); err != nil {
+		return nil, fmt.Errorf("failed to decode roster: %v", err)
+	}
+
+	status := &models.CollectionStatus{
+		Collection: *collection,
+		Total:      len(members),
+		Collected:  models.NewMoney(0, collection.Amount.Currency),
+		Adjusted:   models.NewMoney(0, collection.Amount.Currency),
+		Members:    members,
+	}
+	for _, m := range members {
+		switch m.Status {
+		case "PAID":
+			status.Paid++
+		case "PARTIALLY_PAID":
+			status.Partial++
+		case "WAIVED":
+			status.Waived++
+		default:
+			status.Unpaid++
+		}
+		status.Collected.Centavos += m.AmountPaid.Centavos
+		status.Adjusted.Centavos += m.Adjustments.Centavos
+	}
+	return status, nil
+}
+
+// GetMemberObligations lists a member's obligations, unpaid first then by due date.
+func (s *CollectionService) GetMemberObligations(ctx context.Context, memberID string) ([]models.Obligation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("obligations").Find(ctx, bson.M{"member_id": memberID}, options.Find().SetSort(bson.D{{Key: "status", Value: -1}, {Key: "due_date", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch obligations: %v", err)
+	}
+	obligations := []models.Obligation{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &obligations); err != nil {
+		return nil, fmt.Errorf("failed to decode obligations: %v", err)
+	}
+	return obligations, nil
+}
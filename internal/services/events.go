@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// paymentEventBuffer is how many events a slow subscriber may fall behind
+// before further events to it are dropped.
+const paymentEventBuffer = 16
+
+// paymentEvents fans payment status changes out to subscribers by user ID.
+// Subscribers only hear about changes made by this process, so clients
+// should re-fetch a payment after reconnecting.
+type paymentEvents struct {
+	mu   sync.Mutex
+	subs map[string]map[chan models.PaymentEvent]struct{}
+}
+
+func newPaymentEvents() *paymentEvents {
+	return &paymentEvents{subs: make(map[string]map[chan models.PaymentEvent]struct{})}
+}
+
+func (e *paymentEvents) subscribe(userID string) (chan models.PaymentEvent, func()) {
+	ch := make(chan models.PaymentEvent, paymentEventBuffer)
+	e.mu.Lock()
+	if e.subs[userID] == nil {
+		e.subs[userID] = make(map[chan models.PaymentEvent]struct{})
+	}
+	e.subs[userID][ch] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subs[userID], ch)
+			if len(e.subs[userID]) == 0 {
+				delete(e.subs, userID)
+			}
+			e.mu.Unlock()
+		})
+	}
+}
+
+// active reports whether anyone is listening, so publishers can skip
+// fetching payments nobody will hear about.
+func (e *paymentEvents) active() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.subs) > 0
+}
+
+// send delivers event to every subscriber of the given users without blocking.
+func (e *paymentEvents) send(event models.PaymentEvent, userIDs ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	seen := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		for ch := range e.subs[userID] {
+			select {
+			case ch <- event:
+			default:
+				log.Printf("Dropped event for payment %s to a slow subscriber of user %s", event.PaymentID, userID)
+			}
+		}
+	}
+}
+
+// SubscribePaymentEvents streams status changes of the payments a user pays
+// or receives. Call the returned function to stop.
+func (s *PaymentService) SubscribePaymentEvents(userID string) (<-chan models.PaymentEvent, func()) {
+	return s.events.subscribe(userID)
+}
+
+// publishPayment tells the payer and payee of a payment its current status.
+func (s *PaymentService) publishPayment(p *models.Payment) {
+	event := models.NewPaymentEvent(p)
+	if event.UpdatedAt.IsZero() {
+		event.UpdatedAt = time.Now()
+	}
+	s.events.send(event, p.PayerID, p.PayeeID)
+}
+
+// publishPayments re-reads the payments matching filter after an update and
+// publishes each.
+func (s *PaymentService) publishPayments(ctx context.Context, filter bson.M) {
+	if !s.events.active() {
+		return
+	}
+	var payments []models.Payment
+	if err := findAll(ctx, s.db.Collection("payments"), filter, &payments); err != nil {
+		log.Printf("Failed to fetch payments to publish: %v", err)
+		return
+	}
+	for i := range payments {
+		s.publishPayment(&payments[i])
+	}
+}
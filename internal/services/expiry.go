@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// defaultPaymentExpiry returns how long a charge stays PENDING before the
+// sweeper expires it, read from PAYMENT_EXPIRY (a Go duration, default 30m).
+func defaultPaymentExpiry() time.Duration {
+	if v := os.Getenv("PAYMENT_EXPIRY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid PAYMENT_EXPIRY %q, using 30m", v)
+	}
+	return 30 * time.Minute
+}
+
+// notLapsedFilter matches payments that are not PENDING charges past their
+// expiry. Payments created before expiry existed have no expires_at and are kept.
+func notLapsedFilter(now time.Time) []bson.M {
+	return []bson.M{
+		{"status": bson.M{"$ne": "PENDING"}},
+		{"disbursement_id": bson.M{"$ne": ""}},
+		{"expires_at": bson.M{"$exists": false}},
+		{"expires_at": time.Time{}},
+		{"expires_at": bson.M{"$gt": now}},
+	}
+}
+
+// ExpireStalePayments marks PENDING charges past their expiry as EXPIRED and
+// voids them at Xendit. Charges that turn out to have succeeded are moved to
+// SUCCEEDED instead. It returns the number of payments expired.
+func (s *PaymentService) ExpireStalePayments(ctx context.Context) (int, error) {
+	findCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	query := bson.M{
+		"status":          "PENDING",
+		"disbursement_id": "",
+		"expires_at":      bson.M{"$lte": time.Now(), "$gt": time.Time{}},
+	}
+	cur, err := s.db.Collection("payments").Find(findCtx, query)
+	if err != nil {
+		log.Printf("Failed to fetch stale payments: %v", err)
+		return 0, fmt.Errorf("failed to fetch stale payments: %v", err)
+	}
+	var payments []models.Payment
+	if err := cur.All(findCtx, &payments); err != nil {
+		log.Printf("Failed to decode stale payments: %v", err)
+		return 0, fmt.Errorf("failed to decode stale payments: %v", err)
+	}
+
+	expired := 0
+	for i := range payments {
+		ok, err := s.expirePayment(ctx, &payments[i])
+		if err != nil {
+			log.Printf("Failed to expire payment %s: %v", payments[i].ID, err)
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+	if expired > 0 {
+		log.Printf("Expired %d stale payments", expired)
+	}
+	return expired, nil
+}
+
+// expirePayment checks the charge one last time, voids it and marks the
+// payment EXPIRED. It reports whether the payment was expired.
+func (s *PaymentService) expirePayment(ctx context.Context, payment *models.Payment) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	providerStatus, err := fetchChargeStatus(ctx, payment)
+	if err != nil {
+		return false, err
+	}
+	if providerStatus != "PENDING" {
+		// The provider already settled it one way or the other
+		changed, err := s.applyChargeStatus(ctx, payment, providerStatus)
+		if err != nil || changed {
+			return false, err
+		}
+	}
+
+	if err := voidCharge(ctx, payment); err != nil {
+		// The charge lapses on Xendit's side anyway; keep expiring locally
+		log.Printf("Failed to void charge %s for payment %s: %v", payment.ChargeID, payment.ID, err)
+	}
+
+	result, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": payment.ID, "status": "PENDING"}, bson.M{
+		"$set": bson.M{
+			"status":     "EXPIRED",
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to update payment status: %v", err)
+	}
+	if result.ModifiedCount == 0 {
+		return false, nil
+	}
+	log.Printf("Payment %s expired, charge %s voided", payment.ID, payment.ChargeID)
+	payment.Status = "EXPIRED"
+	payment.UpdatedAt = time.Now()
+	s.publishPayment(payment)
+	emitPaymentWebhook(ctx, s.db, models.EventPaymentExpired, payment)
+	return true, nil
+}
+
+// StartExpirySweeper runs ExpireStalePayments every interval until ctx is cancelled.
+func (s *PaymentService) StartExpirySweeper(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting payment expiry sweeper: interval=%s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Payment expiry sweeper stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.ExpireStalePayments(ctx); err != nil {
+				log.Printf("Scheduled expiry sweep failed: %v", err)
+			}
+		}
+	}
+}
+
+// GetOutstandingTotal sums the PENDING, unexpired payments owed by a payer.
+func (s *PaymentService) GetOutstandingTotal(ctx context.Context, payerID string) (models.Money, error) {
+	if _, err := primitive.ObjectIDFromHex(payerID); err != nil {
+		log.Printf("Invalid payerID format: %s, error: %v", payerID, err)
+		return models.Money{}, fmt.Errorf("invalid payer_id format: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{
+			"payer_id":        payerID,
+			"status":          "PENDING",
+			"disbursement_id": "",
+			"$or":             notLapsedFilter(time.Now()),
+		}},
+		{"$group": bson.M{"_id": "$amount.currency", "total": bson.M{"$sum": "$amount.centavos"}}},
+	}
+	cur, err := s.db.Collection("payments").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("Failed to aggregate outstanding total for %s: %v", payerID, err)
+		return models.Money{}, fmt.Errorf("failed to compute outstanding total: %v", err)
+	}
+	defer cur.Close(ctx)
+
+	var result []struct {
+		Currency string `bson:"_id"`
+		Total    int64  `bson:"total"`
+	}
+	if err := cur.All(ctx, &result); err != nil {
+		log.Printf("Failed to decode outstanding total for %s: %v", payerID, err)
+		return models.Money{}, fmt.Errorf("failed to decode outstanding total: %v", err)
+	}
+	if len(result) == 0 {
+		return models.NewMoney(0, "PHP"), nil
+	}
+	if len(result) > 1 {
+		return models.Money{}, fmt.Errorf("outstanding payments span %d currencies", len(result))
+	}
+	return models.NewMoney(result[0].Total, result[0].Currency), nil
+}
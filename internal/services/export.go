@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// ExportTimeout bounds how long an export may stream.
+const ExportTimeout = 5 * time.Minute
+
+// ExportPayments calls fn for every payment matching filter, oldest first,
+// joined with the payer's name and email. Payments are streamed from the
+// cursor so large exports are never held in memory.
+func (s *PaymentService) ExportPayments(ctx context.Context, filter PaymentFilter, fn func(*models.PaymentView) error) error {
+	ctx, cancel := context.WithTimeout(ctx, ExportTimeout)
+	defer cancel()
+
+	query, err := s.paymentFilterQuery(ctx, filter)
+	if err != nil {
+		return err
+	}
+	pipeline := []bson.M{
+		{"$match": query},
+		{"$sort": bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{"$lookup": bson.M{
+			"from": "user",
+			"let": bson.M{"payer": bson.M{"$convert": bson.M{
+				"input": "$payer_id", "to": "objectId", "onError": nil, "onNull": nil,
+			}}},
+			"pipeline": []bson.M{
+				{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$payer"}}}},
+				{"$project": bson.M{"fullname": 1, "email": 1}},
+			},
+			"as": "payer",
+		}},
+		{"$unwind": bson.M{"path": "$payer", "preserveNullAndEmptyArrays": true}},
+		{"$addFields": bson.M{"payer_name": "$payer.fullname", "payer_email": "$payer.email"}},
+		{"$project": bson.M{"payer": 0}},
+	}
+	cur, err := s.db.Collection("payments").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("Failed to export payments: %v", err)
+		return fmt.Errorf("failed to export payments: %v", err)
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var view models.PaymentView
+		if err := cur.Decode(&view); err != nil {
+			return fmt.Errorf("failed to decode payment: %v", err)
+		}
+		if err := fn(&view); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("failed to export payments: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// defaultFeePolicyID is the policy used for payees without their own.
+const defaultFeePolicyID = "default"
+
+// defaultGatewayFees estimate Xendit's published PH rates per method. A fee
+// policy can override them when the account has negotiated rates.
+var defaultGatewayFees = map[string]models.FeeRate{
+	models.MethodEWallet:        {RateBps: 200},
+	models.MethodQRCode:         {RateBps: 70},
+	models.MethodVirtualAccount: {Flat: models.NewMoney(2500, "PHP")},
+}
+
+// validateFeePolicy normalizes and checks a fee policy before it is saved.
+func validateFeePolicy(policy *models.FeePolicy) error {
+	policy.Mode = strings.ToUpper(strings.TrimSpace(policy.Mode))
+	switch policy.Mode {
+	case models.FeePayerPays, models.FeePayeeAbsorbs, models.FeePlatformAbsorbs:
+	default:
+		return fmt.Errorf("invalid fee mode %q, must be PAYER_PAYS, PAYEE_ABSORBS or PLATFORM_ABSORBS", policy.Mode)
+	}
+	if policy.PlatformRateBps < 0 || policy.PlatformRateBps >= 10000 {
+		return fmt.Errorf("platform_rate_bps must be between 0 and 9999")
+	}
+	for method, rate := range policy.Gateway {
+		if _, ok := defaultGatewayFees[method]; !ok {
+			return fmt.Errorf("no gateway fee applies to method %q", method)
+		}
+		if rate.RateBps < 0 || rate.RateBps >= 10000 || rate.Flat.Centavos < 0 {
+			return fmt.Errorf("invalid gateway fee for %s", method)
+		}
+	}
+	return nil
+}
+
+// gatewayFee is Xendit's fee on a charge of amount, rounded up to the centavo.
+func gatewayFee(rate models.FeeRate, amount models.Money) models.Money {
+	return models.NewMoney((amount.Centavos*rate.RateBps+9999)/10000+rate.Flat.Centavos, amount.Currency)
+}
+
+// computeFees works out the fees on a payment of base under policy. In
+// PAYER_PAYS mode the convenience fee is grossed up so that what is left after
+// Xendit's fee still covers base. Offline methods carry no fees.
+func computeFees(policy *models.FeePolicy, method string, base models.Money) (*models.PaymentFees, error) {
+	if method == "" {
+		method = models.MethodEWallet
+	}
+	if isOfflineMethod(method) {
+		return nil, nil
+	}
+	rate, ok := policy.Gateway[method]
+	if !ok {
+		rate = defaultGatewayFees[method]
+	}
+
+	fees := &models.PaymentFees{
+		Mode:           policy.Mode,
+		ConvenienceFee: models.NewMoney(0, base.Currency),
+		PlatformFee:    applyRate(base, policy.PlatformRateBps),
+	}
+	gross := base
+	if policy.Mode == models.FeePayerPays {
+		gross = models.NewMoney((base.Centavos+rate.Flat.Centavos)*10000/(10000-rate.RateBps), base.Currency)
+		for gross.Centavos-gatewayFee(rate, gross).Centavos < base.Centavos {
+			gross.Centavos++
+		}
+		fees.ConvenienceFee = models.NewMoney(gross.Centavos-base.Centavos, base.Currency)
+	}
+	fees.GatewayFee = gatewayFee(rate, gross)
+
+	net := gross.Centavos - fees.PlatformFee.Centavos
+	if policy.Mode != models.FeePlatformAbsorbs {
+		net -= fees.GatewayFee.Centavos
+	}
+	if net <= 0 {
+		return nil, fmt.Errorf("amount %s is too small to cover fees", base.String())
+	}
+	fees.NetAmount = models.NewMoney(net, base.Currency)
+	return fees, nil
+}
+
+// feePolicy returns the payee's fee policy, falling back to the default
+// policy and then to the platform absorbing fees with no cut of its own.
+func (s *PaymentService) feePolicy(ctx context.Context, payeeID string) (*models.FeePolicy, error) {
+	cur, err := s.db.Collection("fee_policies").Find(ctx, bson.M{"_id": bson.M{"$in": []string{payeeID, defaultFeePolicyID}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch fee policy: %v", err)
+	}
+	var policies []models.FeePolicy
+	if err := cur.All(ctx, &policies); err != nil {
+		return nil, fmt.Errorf("failed to decode fee policy: %v", err)
+	}
+	var policy *models.FeePolicy
+	for i := range policies {
+		if policies[i].ID == payeeID || policy == nil {
+			policy = &policies[i]
+		}
+	}
+	if policy == nil {
+		policy = &models.FeePolicy{ID: defaultFeePolicyID, Mode: models.FeePlatformAbsorbs}
+	}
+	return policy, nil
+}
+
+// QuoteFees returns the fees a payment of amount to payeeID would carry, so
+// checkout can show the payer's convenience fee before charging.
+func (s *PaymentService) QuoteFees(ctx context.Context, payeeID, method string, amount models.Money) (*models.PaymentFees, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if payeeID == "" {
+		payeeID = defaultPayeeID
+	}
+	policy, err := s.feePolicy(ctx, payeeID)
+	if err != nil {
+		return nil, err
+	}
+	return computeFees(policy, strings.ToUpper(strings.TrimSpace(method)), amount)
+}
+
+// SetFeePolicy creates or replaces the fee policy for a payee, or the default
+// policy when payeeID is empty. Payments already charged keep their fees.
+func (s *PaymentService) SetFeePolicy(ctx context.Context, payeeID string, policy *models.FeePolicy, adminID string) (*models.FeePolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := validateFeePolicy(policy); err != nil {
+		return nil, err
+	}
+	policy.ID = defaultFeePolicyID
+	if payeeID != "" {
+		policy.ID = payeeID
+	}
+	policy.UpdatedBy = adminID
+	policy.UpdatedAt = time.Now()
+	_, err := s.db.Collection("fee_policies").ReplaceOne(ctx, bson.M{"_id": policy.ID}, policy, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("Failed to save fee policy %s: %v", policy.ID, err)
+		return nil, fmt.Errorf("failed to save fee policy: %v", err)
+	}
+	log.Printf("Fee policy %s set: Mode=%s, PlatformRateBps=%d, By=%s", policy.ID, policy.Mode, policy.PlatformRateBps, adminID)
+	return policy, nil
+}
+
+// GetFeePolicies lists the default and per-payee fee policies.
+func (s *PaymentService) GetFeePolicies(ctx context.Context) ([]models.FeePolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("fee_policies").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch fee policies: %v", err)
+	}
+	policies := []models.FeePolicy{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &policies); err != nil {
+		return nil, fmt.Errorf("failed to decode fee policies: %v", err)
+	}
+	return policies, nil
+}
+
+// creditedAmount is the part of a payment that counts toward what the payer
+// owes, leaving out the convenience fee.
+func creditedAmount(p *models.Payment) models.Money {
+	if p.Fees == nil {
+		return p.Amount
+	}
+	return models.NewMoney(p.Amount.Centavos-p.Fees.ConvenienceFee.Centavos, p.Amount.Currency)
+}
+
+// payoutAmount is what the payee is disbursed for a payment.
+func payoutAmount(p *models.Payment) models.Money {
+	if p.Fees == nil {
+		return p.Amount
+	}
+	return p.Fees.NetAmount
+}
+
+// feeLedger books the fees on a succeeded charge: Xendit keeps its fee out of
+// clearing, the platform takes its cut from the payee, and unless the
+// platform absorbs it the gateway fee is recovered from the payee as well.
+func feeLedger(p *models.Payment) models.LedgerTransaction {
+	f := p.Fees
+	txn := models.LedgerTransaction{
+		Key:       "fee:" + p.ID,
+		Kind:      "FEE",
+		PaymentID: p.ID,
+		Memo:      f.Mode,
+		Entries: []models.LedgerEntry{
+			debit(accountGatewayFees, f.GatewayFee),
+			credit(accountXenditClearing, f.GatewayFee),
+			debit(payeePayable(p.PayeeID), f.PlatformFee),
+			credit(accountPlatformFees, f.PlatformFee),
+		},
+	}
+	if f.Mode != models.FeePlatformAbsorbs {
+		txn.Entries = append(txn.Entries,
+			debit(payeePayable(p.PayeeID), f.GatewayFee),
+			credit(accountGatewayFees, f.GatewayFee),
+		)
+	}
+	return txn
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+type InvoiceService struct {
+	db *mongo.Database
+}
+
+func NewInvoiceService(db *mongo.Database) *InvoiceService {
+	_, err := db.Collection("invoices").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.M{"seq": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"open_for": 1}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "member_id", Value: 1}, {Key: "issued_at", Value: -1}}},
+	})
+	if err != nil {
+		log.Fatalf("error creating index for invoices: %v", err)
+	}
+	return &InvoiceService{db: db}
+}
+
+// invoiceFromObligation fills an invoice's lines and totals from the current
+// state of its obligation. An obligation waived in full voids its invoice.
+func invoiceFromObligation(inv *models.Invoice, o *models.Obligation, now time.Time) {
+	currency := o.Amount.Currency
+	inv.Title = o.Title
+	inv.DueDate = o.DueDate
+	inv.Lines = []models.InvoiceLine{{Description: o.Title, Amount: o.Amount}}
+	if o.Penalties.Centavos > 0 {
+		inv.Lines = append(inv.Lines, models.InvoiceLine{Description: "Late fees", Amount: o.Penalties})
+	}
+	if o.Discounts.Centavos > 0 {
+		inv.Lines = append(inv.Lines, models.InvoiceLine{Description: "Early payment discount", Amount: models.NewMoney(-o.Discounts.Centavos, currency)})
+	}
+	if o.Adjustments.Centavos > 0 {
+		inv.Lines = append(inv.Lines, models.InvoiceLine{Description: "Waivers and credits", Amount: models.NewMoney(-o.Adjustments.Centavos, currency)})
+	}
+	total := int64(0)
+	for _, line := range inv.Lines {
+		total += line.Amount.Centavos
+	}
+	inv.Total = models.NewMoney(total, currency)
+	inv.AmountPaid = models.NewMoney(o.AmountPaid.Centavos, currency)
+	inv.Balance = models.NewMoney(obligationBalance(o).Centavos, currency)
+	if inv.Balance.Centavos < 0 {
+		inv.Balance.Centavos = 0
+	}
+	inv.UpdatedAt = now
+
+	switch o.Status {
+	case "PAID":
+		inv.Status = "PAID"
+		inv.PaidAt = o.PaidAt
+	case "WAIVED":
+		inv.Status = "VOID"
+		inv.VoidReason = "Obligation waived"
+		inv.VoidedAt = now
+		inv.OpenFor = ""
+	default:
+		inv.Status = "OPEN"
+	}
+}
+
+// issueInvoice returns the live invoice of an obligation, issuing one when it
+// has none. The number is one past the highest issued so far, and the unique
+// index on it turns a concurrent issue into a retry, so numbers have no gaps.
+func issueInvoice(ctx context.Context, db *mongo.Database, o *models.Obligation) (*models.Invoice, error) {
+	obligationID := o.ID.Hex()
+	for attempt := 0; attempt < 5; attempt++ {
+		var existing models.Invoice
+		err := db.Collection("invoices").FindOne(ctx, bson.M{"open_for": obligationID}).Decode(&existing)
+		if err == nil {
+			return &existing, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("failed to fetch invoice: %v", err)
+		}
+		if o.Status == "WAIVED" {
+			return nil, fmt.Errorf("obligation was waived and has nothing to invoice")
+		}
+
+		var last models.Invoice
+		err = db.Collection("invoices").FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"seq": -1}).SetProjection(bson.M{"seq": 1})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("failed to fetch last invoice number: %v", err)
+		}
+		now := time.Now()
+		inv := &models.Invoice{
+			ID:           primitive.NewObjectID(),
+			Seq:          last.Seq + 1,
+			Number:       fmt.Sprintf("INV-%06d", last.Seq+1),
+			ObligationID: obligationID,
+			OpenFor:      obligationID,
+			CollectionID: o.CollectionID,
+			MemberID:     o.MemberID,
+			PayeeID:      o.PayeeID,
+			IssuedAt:     now,
+		}
+		invoiceFromObligation(inv, o, now)
+		if _, err := db.Collection("invoices").InsertOne(ctx, inv); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to save invoice: %v", err)
+		}
+		if _, err := db.Collection("obligations").UpdateOne(ctx, bson.M{"_id": o.ID}, bson.M{"$set": bson.M{"invoice_id": inv.ID.Hex()}}); err != nil {
+			log.Printf("Failed to link invoice %s to obligation %s: %v", inv.Number, obligationID, err)
+		}
+		log.Printf("Invoice issued: Number=%s, Obligation=%s, Member=%s, Total=%s", inv.Number, obligationID, o.MemberID, inv.Total.String())
+		return inv, nil
+	}
+	return nil, fmt.Errorf("failed to issue invoice: too many concurrent issues, please retry")
+}
+
+// syncInvoice brings an obligation's live invoice in line with it after a
+// payment or adjustment. Obligations without an invoice are left alone.
+func syncInvoice(ctx context.Context, db *mongo.Database, obligationID string) {
+	objID, err := primitive.ObjectIDFromHex(obligationID)
+	if err != nil {
+		return
+	}
+	var o models.Obligation
+	if err := db.Collection("obligations").FindOne(ctx, bson.M{"_id": objID}).Decode(&o); err != nil {
+		log.Printf("Failed to fetch obligation %s to sync its invoice: %v", obligationID, err)
+		return
+	}
+	var inv models.Invoice
+	if err := db.Collection("invoices").FindOne(ctx, bson.M{"open_for": obligationID}).Decode(&inv); err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Failed to fetch invoice of obligation %s: %v", obligationID, err)
+		}
+		return
+	}
+	invoiceFromObligation(&inv, &o, time.Now())
+	update := bson.M{"$set": bson.M{
+		"title":       inv.Title,
+		"lines":       inv.Lines,
+		"total":       inv.Total,
+		"amount_paid": inv.AmountPaid,
+		"balance":     inv.Balance,
+		"due_date":    inv.DueDate,
+		"status":      inv.Status,
+		"paid_at":     inv.PaidAt,
+		"void_reason": inv.VoidReason,
+		"voided_at":   inv.VoidedAt,
+		"updated_at":  inv.UpdatedAt,
+	}}
+	if inv.OpenFor == "" {
+		update["$unset"] = bson.M{"open_for": ""}
+	}
+	if _, err := db.Collection("invoices").UpdateOne(ctx, bson.M{"_id": inv.ID, "status": bson.M{"$ne": "VOID"}}, update); err != nil {
+		log.Printf("Failed to sync invoice %s: %v", inv.Number, err)
+	}
+}
+
+// getObligationForInvoice loads an obligation and checks the caller may see it.
+func (s *InvoiceService) getObligationForInvoice(ctx context.Context, obligationID, userID string, isAdmin bool) (*models.Obligation, error) {
+	objID, err := primitive.ObjectIDFromHex(obligationID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid obligation_id format: %v", err)
+	}
+	var o models.Obligation
+	if err := s.db.Collection("obligations").FindOne(ctx, bson.M{"_id": objID}).Decode(&o); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("obligation not found")
+		}
+		return nil, fmt.Errorf("failed to fetch obligation: %v", err)
+	}
+	if o.MemberID != userID && o.PayeeID != userID && !isAdmin {
+		return nil, fmt.Errorf("obligation does not belong to this member")
+	}
+	return &o, nil
+}
+
+// GetObligationInvoice returns the live invoice of an obligation, issuing it
+// on first request for obligations created before invoicing.
+func (s *InvoiceService) GetObligationInvoice(ctx context.Context, obligationID, userID string, isAdmin bool) (*models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	o, err := s.getObligationForInvoice(ctx, obligationID, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if o.Status == "WAIVED" && o.InvoiceID != "" {
+		return s.GetInvoice(ctx, o.InvoiceID, userID, isAdmin)
+	}
+	inv, err := issueInvoice(ctx, s.db, o)
+	if err != nil {
+		return nil, err
+	}
+	syncInvoice(ctx, s.db, obligationID)
+	invoiceFromObligation(inv, o, time.Now())
+	return inv, nil
+}
+
+// GetInvoice returns an invoice the caller is the member, payee or admin of.
+func (s *InvoiceService) GetInvoice(ctx context.Context, invoiceID, userID string, isAdmin bool) (*models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invoice_id format: %v", err)
+	}
+	var inv models.Invoice
+	if err := s.db.Collection("invoices").FindOne(ctx, bson.M{"_id": objID}).Decode(&inv); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("invoice not found")
+		}
+		return nil, fmt.Errorf("failed to fetch invoice: %v", err)
+	}
+	if inv.MemberID != userID && inv.PayeeID != userID && !isAdmin {
+		return nil, fmt.Errorf("invoice does not belong to this member")
+	}
+	return &inv, nil
+}
+
+// GetMemberInvoices lists a member's invoices, newest first.
+func (s *InvoiceService) GetMemberInvoices(ctx context.Context, memberID string) ([]models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("invoices").Find(ctx, bson.M{"member_id": memberID}, options.Find().SetSort(bson.M{"issued_at": -1}))
+	if err != nil {
+		log.Printf("Failed to fetch invoices for member %s: %v", memberID, err)
+		return nil, fmt.Errorf("failed to fetch invoices: %v", err)
+	}
+	invoices := []models.Invoice{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &invoices); err != nil {
+		return nil, fmt.Errorf("failed to decode invoices: %v", err)
+	}
+	return invoices, nil
+}
+
+// VoidInvoice cancels an OPEN invoice, for example one issued with a
+// mistake. Its number stays used; the next request for the obligation's
+// invoice issues a fresh one.
+func (s *InvoiceService) VoidInvoice(ctx context.Context, invoiceID, adminID, reason string) (*models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invoice_id format: %v", err)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	now := time.Now()
+	after := options.After
+	var inv models.Invoice
+	err = s.db.Collection("invoices").FindOneAndUpdate(ctx, bson.M{"_id": objID, "status": "OPEN"}, bson.M{
+		"$set":   bson.M{"status": "VOID", "void_reason": reason, "voided_by": adminID, "voided_at": now, "updated_at": now},
+		"$unset": bson.M{"open_for": ""},
+	}, &options.FindOneAndUpdateOptions{ReturnDocument: &after}).Decode(&inv)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("invoice not found or not open")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to void invoice: %v", err)
+	}
+	objOblID, _ := primitive.ObjectIDFromHex(inv.ObligationID)
+	if _, err := s.db.Collection("obligations").UpdateOne(ctx, bson.M{"_id": objOblID, "invoice_id": inv.ID.Hex()}, bson.M{"$unset": bson.M{"invoice_id": ""}}); err != nil {
+		log.Printf("Failed to unlink voided invoice %s: %v", inv.Number, err)
+	}
+	log.Printf("Invoice %s voided by %s: %s", inv.Number, adminID, reason)
+	return &inv, nil
+}
+
+// RenderInvoicePDF draws an invoice as a PDF.
+func (s *InvoiceService) RenderInvoicePDF(ctx context.Context, inv *models.Invoice) []byte {
+	member, _ := lookupUser(ctx, s.db, inv.MemberID)
+
+	pdf := newPDF()
+	y := drawOrganization(pdf, loadOrganization())
+	pdf.Text(pdfMargin, y, 14, true, "INVOICE")
+	pdf.TextRight(pdfPageWidth-pdfMargin, y, 11, true, "No. "+inv.Number)
+	y += 16
+	pdf.TextRight(pdfPageWidth-pdfMargin, y, 9, false, "Issued: "+inv.IssuedAt.In(manila).Format("January 2, 2006"))
+	y += 14
+	pdf.TextRight(pdfPageWidth-pdfMargin, y, 9, false, "Due: "+inv.DueDate.In(manila).Format("January 2, 2006"))
+	y += 14
+	pdf.TextRight(pdfPageWidth-pdfMargin, y, 9, true, "Status: "+inv.Status)
+	if member != nil {
+		pdf.Text(pdfMargin, y-28, 10, true, "Bill to")
+		pdf.Text(pdfMargin, y-14, 10, false, member.FullName)
+		pdf.Text(pdfMargin, y, 9, false, member.Email)
+	}
+	y += 30
+
+	pdf.Text(pdfMargin, y, 10, true, "Description")
+	pdf.TextRight(pdfPageWidth-pdfMargin, y, 10, true, "Amount")
+	y += 6
+	pdf.Line(pdfMargin, y, pdfPageWidth-pdfMargin, y)
+	y += 16
+	for _, line := range inv.Lines {
+		pdf.Text(pdfMargin, y, 10, false, line.Description)
+		pdf.TextRight(pdfPageWidth-pdfMargin, y, 10, false, line.Amount.String())
+		y += 16
+	}
+	pdf.Line(pdfMargin, y-8, pdfPageWidth-pdfMargin, y-8)
+	y += 6
+	for _, row := range []struct {
+		label string
+		m     models.Money
+		bold  bool
+	}{{"Total", inv.Total, true}, {"Paid", inv.AmountPaid, false}, {"Balance due", inv.Balance, true}} {
+		pdf.Text(pdfPageWidth-pdfMargin-200, y, 10, row.bold, row.label)
+		pdf.TextRight(pdfPageWidth-pdfMargin, y, 10, row.bold, row.m.Currency+" "+row.m.String())
+		y += 16
+	}
+	if inv.Status == "VOID" {
+		y += 20
+		pdf.Text(pdfMargin, y, 12, true, "VOID: "+inv.VoidReason)
+	}
+	return pdf.Bytes()
+}
+
+// invoiceHTML is the printable HTML view of an invoice.
+var invoiceHTML = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.In(manila).Format("January 2, 2006") },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Invoice {{.Invoice.Number}}</title>
+<style>body{font-family:Helvetica,Arial,sans-serif;max-width:720px;margin:40px auto;color:#222}
+table{width:100%;border-collapse:collapse}td,th{padding:6px 0;text-align:left}
+.amt{text-align:right}thead th{border-bottom:1px solid #999}.totals td{font-weight:bold}</style></head>
+<body>
+<h1>{{.Org.Name}}</h1>
+{{with .Org.Address}}<div>{{.}}</div>{{end}}{{with .Org.Contact}}<div>{{.}}</div>{{end}}{{with .Org.TIN}}<div>TIN: {{.}}</div>{{end}}
+<h2>Invoice {{.Invoice.Number}} <small>({{.Invoice.Status}})</small></h2>
+<p>Issued {{date .Invoice.IssuedAt}} &middot; Due {{date .Invoice.DueDate}}</p>
+{{with .Member}}<p><strong>Bill to</strong><br>{{.FullName}}<br>{{.Email}}</p>{{end}}
+<table><thead><tr><th>Description</th><th class="amt">Amount</th></tr></thead><tbody>
+{{range .Invoice.Lines}}<tr><td>{{.Description}}</td><td class="amt">{{.Amount.String}}</td></tr>{{end}}
+</tbody><tfoot>
+<tr class="totals"><td>Total</td><td class="amt">{{.Invoice.Total.Currency}} {{.Invoice.Total.String}}</td></tr>
+<tr><td>Paid</td><td class="amt">{{.Invoice.AmountPaid.Currency}} {{.Invoice.AmountPaid.String}}</td></tr>
+<tr class="totals"><td>Balance due</td><td class="amt">{{.Invoice.Balance.Currency}} {{.Invoice.Balance.String}}</td></tr>
+</tfoot></table>
+{{if eq .Invoice.Status "VOID"}}<p><strong>VOID:</strong> {{.Invoice.VoidReason}}</p>{{end}}
+</body></html>
+`))
+
+// RenderInvoiceHTML writes an invoice as a printable HTML page.
+func (s *InvoiceService) RenderInvoiceHTML(ctx context.Context, w io.Writer, inv *models.Invoice) error {
+	member, _ := lookupUser(ctx, s.db, inv.MemberID)
+	return invoiceHTML.Execute(w, struct {
+		Org     organization
+		Invoice *models.Invoice
+		Member  *models.User
+	}{loadOrganization(), inv, member})
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// Ledger accounts. Balances are debits minus credits, so assets (money held,
+// amounts owed to us) are positive and liabilities (amounts we owe) negative.
+const (
+	accountXenditClearing = "clearing:xendit" // Money held at Xendit
+	accountPlatformFees   = "fees:platform"   // Fees the platform keeps
+	accountGatewayFees    = "fees:gateway"    // Fees Xendit charges us
+)
+
+// memberReceivable is what a member owes on their obligations.
+func memberReceivable(memberID string) string { return "receivable:member:" + memberID }
+
+// payeeBilled is the payee's claim on obligations members have not paid yet.
+func payeeBilled(payeeID string) string { return "billed:payee:" + payeeID }
+
+// payeePayable is what the platform owes a payee from collected charges.
+func payeePayable(payeeID string) string { return "payable:payee:" + payeeID }
+
+// payeeOffline is money a payee collected directly, outside Xendit.
+func payeeOffline(payeeID string) string { return "offline:payee:" + payeeID }
+
+func debit(account string, amount models.Money) models.LedgerEntry {
+	return models.LedgerEntry{Account: account, Direction: "DEBIT", Amount: amount}
+}
+
+func credit(account string, amount models.Money) models.LedgerEntry {
+	return models.LedgerEntry{Account: account, Direction: "CREDIT", Amount: amount}
+}
+
+// LedgerService answers balance queries from the ledger.
+type LedgerService struct {
+	db *mongo.Database
+}
+
+// NewLedgerService creates a LedgerService and the ledger indexes. The unique
+// key index is what makes posting idempotent.
+func NewLedgerService(db *mongo.Database) *LedgerService {
+	_, err := db.Collection("ledger").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.M{"key": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"entries.account": 1}},
+		{Keys: bson.M{"payment_id": 1}},
+	})
+	if err != nil {
+		log.Fatalf("error creating indexes for ledger: %v", err)
+	}
+	return &LedgerService{db: db}
+}
+
+// postLedger appends a transaction to the ledger. It refuses transactions
+// whose debits and credits differ, and posting the same key twice is a no-op.
+// Zero-amount entries are dropped.
+func postLedger(ctx context.Context, db *mongo.Database, txn models.LedgerTransaction) error {
+	entries := make([]models.LedgerEntry, 0, len(txn.Entries))
+	var debits, credits int64
+	for _, e := range txn.Entries {
+		if e.Amount.Centavos == 0 {
+			continue
+		}
+		if e.Amount.Centavos < 0 {
+			return fmt.Errorf("ledger entry for %s has negative amount %s", e.Account, e.Amount.String())
+		}
+		switch e.Direction {
+		case "DEBIT":
+			debits += e.Amount.Centavos
+		case "CREDIT":
+			credits += e.Amount.Centavos
+		default:
+			return fmt.Errorf("invalid ledger entry direction %q", e.Direction)
+		}
+		entries = append(entries, e)
+	}
+	if debits != credits {
+		return fmt.Errorf("ledger transaction %s is unbalanced: debits %d, credits %d", txn.Key, debits, credits)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	txn.ID = primitive.NewObjectID()
+	txn.Entries = entries
+	txn.CreatedAt = time.Now()
+	if _, err := db.Collection("ledger").InsertOne(ctx, txn); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to post ledger transaction %s: %v", txn.Key, err)
+	}
+	return nil
+}
+
+// recordLedger posts a transaction and logs rather than fails when it cannot,
+// so a ledger outage never blocks the money movement it records.
+func recordLedger(ctx context.Context, db *mongo.Database, txn models.LedgerTransaction) {
+	if err := postLedger(ctx, db, txn); err != nil {
+		log.Printf("Failed to record ledger transaction %s: %v", txn.Key, err)
+	}
+}
+
+// obligationLedger books an issued obligation as owed by the member to the payee.
+func obligationLedger(o *models.Obligation) models.LedgerTransaction {
+	return models.LedgerTransaction{
+		Key:          "obligation:" + o.ID.Hex(),
+		Kind:         "OBLIGATION",
+		ObligationID: o.ID.Hex(),
+		Memo:         o.Title,
+		Entries: []models.LedgerEntry{
+			debit(memberReceivable(o.MemberID), o.Amount),
+			credit(payeeBilled(o.PayeeID), o.Amount),
+		},
+	}
+}
+
+// chargeLedger books a succeeded payment. Xendit charges leave the money in
+// clearing and owed to the payee; offline payments are already with the
+// payee. Payments against an obligation also settle the member's receivable,
+// after booking the late fees and discounts in their breakdown.
+func chargeLedger(p *models.Payment) models.LedgerTransaction {
+	txn := models.LedgerTransaction{
+		Key:          "charge:" + p.ID,
+		Kind:         "CHARGE",
+		PaymentID:    p.ID,
+		ObligationID: p.ObligationID,
+		Memo:         p.Title,
+	}
+	if isOfflineMethod(p.Method) {
+		txn.Entries = append(txn.Entries,
+			debit(payeeOffline(p.PayeeID), p.Amount),
+			credit(payeePayable(p.PayeeID), p.Amount),
+			debit(payeePayable(p.PayeeID), p.Amount),
+			credit(payeeOffline(p.PayeeID), p.Amount),
+		)
+	} else {
+		txn.Entries = append(txn.Entries,
+			debit(accountXenditClearing, p.Amount),
+			credit(payeePayable(p.PayeeID), p.Amount),
+		)
+	}
+	if p.ObligationID != "" {
+		if b := p.Breakdown; b != nil {
+			txn.Entries = append(txn.Entries,
+				debit(memberReceivable(p.PayerID), b.Penalty),
+				credit(payeeBilled(p.PayeeID), b.Penalty),
+				debit(payeeBilled(p.PayeeID), b.Discount),
+				credit(memberReceivable(p.PayerID), b.Discount),
+			)
+		}
+		credited := creditedAmount(p)
+		txn.Entries = append(txn.Entries,
+			debit(payeeBilled(p.PayeeID), credited),
+			credit(memberReceivable(p.PayerID), credited),
+		)
+	}
+	return txn
+}
+
+// disbursementLedger books money paid out of clearing to the payee.
+func disbursementLedger(p *models.Payment, amount models.Money) models.LedgerTransaction {
+	return models.LedgerTransaction{
+		Key:       "disbursement:" + p.ID,
+		Kind:      "DISBURSEMENT",
+		PaymentID: p.ID,
+		Memo:      p.Title,
+		Entries: []models.LedgerEntry{
+			debit(payeePayable(p.PayeeID), amount),
+			credit(accountXenditClearing, amount),
+		},
+	}
+}
+
+// refundLedger books money returned to the payer from clearing, charged
+// against what the payee is owed (or has already been paid).
+func refundLedger(p *models.Payment, r *models.Refund) models.LedgerTransaction {
+	return models.LedgerTransaction{
+		Key:       "refund:" + r.ID.Hex(),
+		Kind:      "REFUND",
+		PaymentID: p.ID,
+		Memo:      r.Reason,
+		Entries: []models.LedgerEntry{
+			debit(payeePayable(p.PayeeID), r.Amount),
+			credit(accountXenditClearing, r.Amount),
+		},
+	}
+}
+
+// adjustmentLedger books an admin waiver, discount or credit as the payee
+// giving up part of its claim on the member.
+func adjustmentLedger(o *models.Obligation, a *models.Adjustment) models.LedgerTransaction {
+	return models.LedgerTransaction{
+		Key:          "adjustment:" + a.ID.Hex(),
+		Kind:         "ADJUSTMENT",
+		ObligationID: o.ID.Hex(),
+		Memo:         a.Type + ": " + a.Reason,
+		Entries: []models.LedgerEntry{
+			debit(payeeBilled(o.PayeeID), a.Amount),
+			credit(memberReceivable(o.MemberID), a.Amount),
+		},
+	}
+}
+
+// balancesPipeline sums the entries of the accounts matched by match.
+func balancesPipeline(match bson.M) []bson.M {
+	return []bson.M{
+		{"$unwind": "$entries"},
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":      "$entries.account",
+			"currency": bson.M{"$first": "$entries.amount.currency"},
+			"debits":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$entries.direction", "DEBIT"}}, "$entries.amount.centavos", 0}}},
+			"credits":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$entries.direction", "CREDIT"}}, "$entries.amount.centavos", 0}}},
+		}},
+		{"$project": bson.M{
+			"debits":  bson.M{"centavos": "$debits", "currency": "$currency"},
+			"credits": bson.M{"centavos": "$credits", "currency": "$currency"},
+			"balance": bson.M{"centavos": bson.M{"$subtract": bson.A{"$debits", "$credits"}}, "currency": "$currency"},
+		}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+}
+
+// GetBalances returns the balance of every account whose name starts with
+// prefix, or of all accounts when prefix is empty.
+func (s *LedgerService) GetBalances(ctx context.Context, prefix string) ([]models.LedgerBalance, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	match := bson.M{}
+	if prefix != "" {
+		match["entries.account"] = bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}
+	}
+	cur, err := s.db.Collection("ledger").Aggregate(ctx, balancesPipeline(match))
+	if err != nil {
+		log.Printf("Failed to aggregate ledger balances: %v", err)
+		return nil, fmt.Errorf("failed to compute balances: %v", err)
+	}
+	balances := []models.LedgerBalance{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &balances); err != nil {
+		return nil, fmt.Errorf("failed to decode balances: %v", err)
+	}
+	return balances, nil
+}
+
+// GetBalance returns the balance of one account; accounts with no entries
+// have a zero balance.
+func (s *LedgerService) GetBalance(ctx context.Context, account string) (*models.LedgerBalance, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("ledger").Aggregate(ctx, balancesPipeline(bson.M{"entries.account": account}))
+	if err != nil {
+		log.Printf("Failed to aggregate balance of %s: %v", account, err)
+		return nil, fmt.Errorf("failed to compute balance: %v", err)
+	}
+	var balances []models.LedgerBalance
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &balances); err != nil {
+		return nil, fmt.Errorf("failed to decode balance: %v", err)
+	}
+	if len(balances) == 0 {
+		zero := models.NewMoney(0, "PHP")
+		return &models.LedgerBalance{Account: account, Debits: zero, Credits: zero, Balance: zero}, nil
+	}
+	return &balances[0], nil
+}
+
+// GetMemberBalance returns what a member owes according to the ledger.
+func (s *LedgerService) GetMemberBalance(ctx context.Context, memberID string) (*models.LedgerBalance, error) {
+	return s.GetBalance(ctx, memberReceivable(memberID))
+}
+
+// GetTransactions lists the transactions touching an account, newest first.
+func (s *LedgerService) GetTransactions(ctx context.Context, account string, limit int64) ([]models.LedgerTransaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	cur, err := s.db.Collection("ledger").Find(ctx, bson.M{"entries.account": account},
+		options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		log.Printf("Failed to fetch ledger transactions for %s: %v", account, err)
+		return nil, fmt.Errorf("failed to fetch transactions: %v", err)
+	}
+	transactions := []models.LedgerTransaction{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &transactions); err != nil {
+		return nil, fmt.Errorf("failed to decode transactions: %v", err)
+	}
+	return transactions, nil
+}
+
+// Verify checks that every transaction balances and that total debits equal
+// total credits across the ledger.
+func (s *LedgerService) Verify(ctx context.Context) (*models.LedgerCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$project": bson.M{
+			"key": 1,
+			"debits": bson.M{"$sum": bson.M{"$map": bson.M{
+				"input": "$entries",
+				"in":    bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$$this.direction", "DEBIT"}}, "$$this.amount.centavos", 0}},
+			}}},
+			"credits": bson.M{"$sum": bson.M{"$map": bson.M{
+				"input": "$entries",
+				"in":    bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$$this.direction", "CREDIT"}}, "$$this.amount.centavos", 0}},
+			}}},
+		}},
+	}
+	cur, err := s.db.Collection("ledger").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan ledger: %v", err)
+	}
+	defer cur.Close(ctx)
+
+	check := &models.LedgerCheck{Unbalanced: []string{}}
+	var debits, credits int64
+	for cur.Next(ctx) {
+		var txn struct {
+			Key     string `bson:"key"`
+			Debits  int64  `bson:"debits"`
+			Credits int64  `bson:"credits"`
+		}
+		if err := cur.Decode(&txn); err != nil {
+			return nil, fmt.Errorf("failed to decode ledger transaction: %v", err)
+		}
+		check.Transactions++
+		debits += txn.Debits
+		credits += txn.Credits
+		if txn.Debits != txn.Credits {
+			check.Unbalanced = append(check.Unbalanced, txn.Key)
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan ledger: %v", err)
+	}
+	check.Debits = models.NewMoney(debits, "PHP")
+	check.Credits = models.NewMoney(credits, "PHP")
+	check.OK = debits == credits && len(check.Unbalanced) == 0
+	return check, nil
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+func php(centavos int64) models.Money { return models.NewMoney(centavos, "PHP") }
+
+// ledgerDeltas checks that txn balances and returns each account's debits
+// minus credits, leaving out accounts that net to zero.
+func ledgerDeltas(t *testing.T, txns ...models.LedgerTransaction) map[string]int64 {
+	t.Helper()
+	deltas := map[string]int64{}
+	for _, txn := range txns {
+		var debits, credits int64
+		for _, e := range txn.Entries {
+			if e.Amount.Centavos < 0 {
+				t.Fatalf("%s: entry for %s has negative amount %s", txn.Key, e.Account, e.Amount.String())
+			}
+			switch e.Direction {
+			case "DEBIT":
+				debits += e.Amount.Centavos
+				deltas[e.Account] += e.Amount.Centavos
+			case "CREDIT":
+				credits += e.Amount.Centavos
+				deltas[e.Account] -= e.Amount.Centavos
+			default:
+				t.Fatalf("%s: invalid direction %q", txn.Key, e.Direction)
+			}
+		}
+		if debits != credits {
+			t.Fatalf("%s: debits %d != credits %d", txn.Key, debits, credits)
+		}
+	}
+	for account, delta := range deltas {
+		if delta == 0 {
+			delete(deltas, account)
+		}
+	}
+	return deltas
+}
+
+func testFees(t *testing.T, mode string) *models.PaymentFees {
+	t.Helper()
+	fees, err := computeFees(&models.FeePolicy{Mode: mode, PlatformRateBps: 100}, models.MethodEWallet, php(10000))
+	if err != nil {
+		t.Fatalf("computeFees(%s): %v", mode, err)
+	}
+	return fees
+}
+
+func TestChargeLedger(t *testing.T) {
+	payerPays := testFees(t, models.FeePayerPays)
+
+	tests := []struct {
+		name    string
+		payment models.Payment
+		want    map[string]int64
+	}{
+		{
+			name:    "ewallet",
+			payment: models.Payment{ID: "p1", PayerID: "m1", PayeeID: "t1", Amount: php(10000)},
+			want: map[string]int64{
+				accountXenditClearing: 10000,
+				payeePayable("t1"):    -10000,
+			},
+		},
+		{
+			name:    "cash stays with the payee",
+			payment: models.Payment{ID: "p2", PayerID: "m1", PayeeID: "t1", Amount: php(10000), Method: models.MethodCash},
+			want:    map[string]int64{},
+		},
+		{
+			name:    "bank transfer against an obligation",
+			payment: models.Payment{ID: "p3", PayerID: "m1", PayeeID: "t1", Amount: php(10000), Method: models.MethodBankTransfer, ObligationID: "o1"},
+			want: map[string]int64{
+				memberReceivable("m1"): -10000,
+				payeeBilled("t1"):      10000,
+			},
+		},
+		{
+			name: "obligation with penalty and discount",
+			payment: models.Payment{
+				ID: "p4", PayerID: "m1", PayeeID: "t1", Amount: php(10300), ObligationID: "o1",
+				Breakdown: &models.PaymentBreakdown{Base: php(10000), Penalty: php(500), Discount: php(200), Total: php(10300)},
+			},
+			want: map[string]int64{
+				accountXenditClearing:  10300,
+				payeePayable("t1"):     -10300,
+				memberReceivable("m1"): -10000,
+				payeeBilled("t1"):      10000,
+			},
+		},
+		{
+			name: "convenience fee is not credited to the obligation",
+			payment: models.Payment{
+				ID: "p5", PayerID: "m1", PayeeID: "t1", Amount: php(10205), ObligationID: "o1", Fees: payerPays,
+				Breakdown: &models.PaymentBreakdown{Base: php(10000), Penalty: php(0), Discount: php(0), Total: php(10000)},
+			},
+			want: map[string]int64{
+				accountXenditClearing:  10205,
+				payeePayable("t1"):     -10205,
+				memberReceivable("m1"): -10000,
+				payeeBilled("t1"):      10000,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ledgerDeltas(t, chargeLedger(&tt.payment)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deltas = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeeLedger(t *testing.T) {
+	tests := []struct {
+		mode  string
+		gross int64
+		want  map[string]int64
+	}{
+		{
+			mode:  models.FeePayerPays,
+			gross: 10205,
+			want: map[string]int64{
+				accountXenditClearing: -205,
+				accountPlatformFees:   -100,
+				payeePayable("t1"):    305,
+			},
+		},
+		{
+			mode:  models.FeePayeeAbsorbs,
+			gross: 10000,
+			want: map[string]int64{
+				accountXenditClearing: -200,
+				accountPlatformFees:   -100,
+				payeePayable("t1"):    300,
+			},
+		},
+		{
+			mode:  models.FeePlatformAbsorbs,
+			gross: 10000,
+			want: map[string]int64{
+				accountXenditClearing: -200,
+				accountGatewayFees:    200,
+				accountPlatformFees:   -100,
+				payeePayable("t1"):    100,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			p := &models.Payment{ID: "p1", PayerID: "m1", PayeeID: "t1", Amount: php(tt.gross), Fees: testFees(t, tt.mode)}
+			if got := ledgerDeltas(t, feeLedger(p)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deltas = %v, want %v", got, tt.want)
+			}
+
+			// Once the net amount is paid out the payee is owed nothing more
+			all := ledgerDeltas(t, chargeLedger(p), feeLedger(p), disbursementLedger(p, payoutAmount(p)))
+			if owed := all[payeePayable("t1")]; owed != 0 {
+				t.Errorf("payee still owed %d after disbursement", owed)
+			}
+		})
+	}
+}
+
+func TestRefundLedger(t *testing.T) {
+	p := &models.Payment{ID: "p1", PayerID: "m1", PayeeID: "t1", Amount: php(10000)}
+	r := &models.Refund{ID: primitive.NewObjectID(), PaymentID: p.ID, Amount: php(2500), Reason: "duplicate"}
+	want := map[string]int64{
+		accountXenditClearing: -2500,
+		payeePayable("t1"):    2500,
+	}
+	if got := ledgerDeltas(t, refundLedger(p, r)); !reflect.DeepEqual(got, want) {
+		t.Errorf("deltas = %v, want %v", got, want)
+	}
+}
+
+func TestAdjustmentLedger(t *testing.T) {
+	o := &models.Obligation{ID: primitive.NewObjectID(), MemberID: "m1", PayeeID: "t1", Amount: php(10000)}
+	for _, adjType := range []string{"WAIVE", "DISCOUNT", "CREDIT"} {
+		t.Run(adjType, func(t *testing.T) {
+			a := &models.Adjustment{ID: primitive.NewObjectID(), Type: adjType, Amount: php(4000), Reason: "board decision"}
+			want := map[string]int64{
+				memberReceivable("m1"): -4000,
+				payeeBilled("t1"):      4000,
+			}
+			if got := ledgerDeltas(t, adjustmentLedger(o, a)); !reflect.DeepEqual(got, want) {
+				t.Errorf("deltas = %v, want %v", got, want)
+			}
+
+			// Issuing then adjusting leaves the member owing the rest
+			all := ledgerDeltas(t, obligationLedger(o), adjustmentLedger(o, a))
+			if owed := all[memberReceivable("m1")]; owed != 6000 {
+				t.Errorf("member owes %d, want 6000", owed)
+			}
+		})
+	}
+}
+
+func TestDisbursementLedger(t *testing.T) {
+	p := &models.Payment{ID: "p1", PayerID: "m1", PayeeID: "t1", Amount: php(10000)}
+	want := map[string]int64{
+		accountXenditClearing: -9700,
+		payeePayable("t1"):    9700,
+	}
+	if got := ledgerDeltas(t, disbursementLedger(p, php(9700))); !reflect.DeepEqual(got, want) {
+		t.Errorf("deltas = %v, want %v", got, want)
+	}
+}
+
+// Rejected transactions fail before the ledger is touched, so no database
+// is needed.
+func TestPostLedgerRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []models.LedgerEntry
+	}{
+		{
+			name:    "unbalanced",
+			entries: []models.LedgerEntry{debit(accountXenditClearing, php(100)), credit(payeePayable("t1"), php(99))},
+		},
+		{
+			name:    "negative amount",
+			entries: []models.LedgerEntry{debit(accountXenditClearing, php(-100)), credit(payeePayable("t1"), php(-100))},
+		},
+		{
+			name:    "invalid direction",
+			entries: []models.LedgerEntry{{Account: accountXenditClearing, Direction: "SIDEWAYS", Amount: php(100)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := postLedger(context.Background(), nil, models.LedgerTransaction{Key: "test:" + tt.name, Entries: tt.entries})
+			if err == nil {
+				t.Fatal("postLedger accepted the transaction")
+			}
+		})
+	}
+
+	t.Run("zero amounts are dropped", func(t *testing.T) {
+		err := postLedger(context.Background(), nil, models.LedgerTransaction{Key: "test:zero", Entries: []models.LedgerEntry{
+			debit(accountXenditClearing, php(0)),
+			credit(payeePayable("t1"), php(0)),
+		}})
+		if err != nil {
+			t.Fatalf("postLedger: %v", err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+)
+
+// mailAttachment is a file attached to an outgoing email.
+type mailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// mailEnabled reports whether outgoing email is configured.
+func mailEnabled() bool {
+	return os.Getenv("SMTP_HOST") != ""
+}
+
+// sendMail sends a plain-text email with attachments through the SMTP server
+// in SMTP_HOST and SMTP_PORT (default 587), authenticating with
+// SMTP_USERNAME and SMTP_PASSWORD when set.
+func sendMail(to, subject, body string, attachments ...mailAttachment) error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return fmt.Errorf("email is not configured")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_USERNAME")
+	}
+
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		from, to, mime.QEncoding.Encode("utf-8", subject), mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return err
+	}
+	part.Write([]byte(body))
+	for _, a := range attachments {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", a.Name)},
+		})
+		if err != nil {
+			return err
+		}
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	if err := smtp.SendMail(host+":"+port, auth, from, []string{to}, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// createAlternativePayment opens a QR Ph code or virtual account at Xendit for
+// a payment that CreatePayment has already validated, then saves it. The
+// payment enters the same PENDING lifecycle as e-wallet charges.
+func (s *PaymentService) createAlternativePayment(ctx context.Context, payment *models.Payment, payer *models.User) (*models.Payment, error) {
+	var err error
+	switch payment.Method {
+	case models.MethodQRCode:
+		err = createQRCode(ctx, payment)
+	case models.MethodVirtualAccount:
+		err = createVirtualAccount(ctx, payment, payer)
+	default:
+		err = fmt.Errorf("unsupported payment method %q", payment.Method)
+	}
+	if err != nil {
+		log.Printf("Failed to create %s for payment %s: %v", payment.Method, payment.ID, err)
+		return nil, err
+	}
+
+	if _, err := s.db.Collection("payments").InsertOne(ctx, payment); err != nil {
+		log.Printf("Failed to save payment: %v", err)
+		return nil, fmt.Errorf("failed to save payment: %v", err)
+	}
+
+	log.Printf("Payment created: ID=%s, Method=%s, ChargeID=%s, Title=%s", payment.ID, payment.Method, payment.ChargeID, payment.Title)
+	return payment, nil
+}
+
+// qrCodesHeaders pins the QR Codes API to the versioned contract that takes
+// reference_id, type and channel_code; without it Xendit may apply the
+// legacy one.
+var qrCodesHeaders = map[string]string{"api-version": "2022-07-31"}
+
+// createQRCode creates a dynamic QR Ph code for the payment amount.
+func createQRCode(ctx context.Context, payment *models.Payment) error {
+	qrReq := map[string]interface{}{
+		"reference_id": payment.ReferenceID,
+		"type":         "DYNAMIC",
+		"currency":     payment.Amount.Currency,
+		"amount":       jsonAmount(payment.Amount),
+		"channel_code": payment.ChannelCode,
+		"expires_at":   payment.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+	var qrResp struct {
+		ID       string `json:"id"`
+		QRString string `json:"qr_string"`
+	}
+	if err := xenditRequestWithHeaders(ctx, "POST", "/qr_codes", qrCodesHeaders, qrReq, &qrResp); err != nil {
+		return err
+	}
+	if qrResp.QRString == "" {
+		return fmt.Errorf("no QR string provided in response")
+	}
+	payment.ChargeID = qrResp.ID
+	payment.QRString = qrResp.QRString
+	return nil
+}
+
+// createVirtualAccount opens a closed, single-use virtual account that only
+// accepts the exact payment amount and expires with the payment.
+func createVirtualAccount(ctx context.Context, payment *models.Payment, payer *models.User) error {
+	vaReq := map[string]interface{}{
+		"external_id":     payment.ReferenceID,
+		"bank_code":       payment.ChannelCode,
+		"name":            payer.FullName,
+		"expected_amount": jsonAmount(payment.Amount),
+		"currency":        payment.Amount.Currency,
+		"is_closed":       true,
+		"is_single_use":   true,
+		"expiration_date": payment.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+	var vaResp struct {
+		ID            string `json:"id"`
+		BankCode      string `json:"bank_code"`
+		AccountNumber string `json:"account_number"`
+		Name          string `json:"name"`
+	}
+	if err := xenditRequest(ctx, "POST", "/callback_virtual_accounts", vaReq, &vaResp); err != nil {
+		return err
+	}
+	if vaResp.AccountNumber == "" {
+		return fmt.Errorf("no account number provided in response")
+	}
+	payment.ChargeID = vaResp.ID
+	payment.VirtualAccount = &models.VirtualAccount{
+		BankCode:      vaResp.BankCode,
+		AccountNumber: vaResp.AccountNumber,
+		AccountName:   vaResp.Name,
+	}
+	return nil
+}
+
+// fetchChargeStatus asks Xendit for the status of whatever the payer is paying
+// through, mapped onto e-wallet charge statuses (PENDING, SUCCEEDED, FAILED, ...).
+func fetchChargeStatus(ctx context.Context, payment *models.Payment) (string, error) {
+	switch payment.Method {
+	case models.MethodQRCode:
+		var paymentsResp struct {
+			Data []struct {
+				Status string `json:"status"`
+			} `json:"data"`
+		}
+		if err := xenditRequestWithHeaders(ctx, "GET", "/qr_codes/"+url.PathEscape(payment.ChargeID)+"/payments", qrCodesHeaders, nil, &paymentsResp); err != nil {
+			return "", err
+		}
+		for _, p := range paymentsResp.Data {
+			if p.Status == "SUCCEEDED" || p.Status == "COMPLETED" {
+				return "SUCCEEDED", nil
+			}
+		}
+		return "PENDING", nil
+	case models.MethodVirtualAccount:
+		var vaResp struct {
+			Status string `json:"status"`
+		}
+		if err := xenditRequest(ctx, "GET", "/callback_virtual_accounts/"+url.PathEscape(payment.ChargeID), nil, &vaResp); err != nil {
+			return "", err
+		}
+		// Xendit only tells us whether the account still accepts transfers.
+		// A single-use account goes INACTIVE both once paid and once lapsed,
+		// so look for the transfer itself.
+		if vaResp.Status == "ACTIVE" || vaResp.Status == "PENDING" {
+			return "PENDING", nil
+		}
+		paid, err := virtualAccountPaid(ctx, payment)
+		if err != nil {
+			return "", err
+		}
+		if paid {
+			return "SUCCEEDED", nil
+		}
+		// Nothing was paid in, so there is no charge status to move to
+		return "PENDING", nil
+	default:
+		var chargeResp struct {
+			Status string `json:"status"`
+		}
+		if err := xenditRequest(ctx, "GET", "/ewallets/charges/"+url.PathEscape(payment.ChargeID), nil, &chargeResp); err != nil {
+			return "", err
+		}
+		return chargeResp.Status, nil
+	}
+}
+
+// virtualAccountPaid reports whether Xendit received a transfer into the
+// payment's virtual account, looked up by the external ID it was opened with.
+func virtualAccountPaid(ctx context.Context, payment *models.Payment) (bool, error) {
+	var vaPayments []struct {
+		CallbackVirtualAccountID string `json:"callback_virtual_account_id"`
+	}
+	err := xenditRequest(ctx, "GET", "/callback_virtual_account_payments?external_id="+url.QueryEscape(payment.ReferenceID), nil, &vaPayments)
+	if isXenditNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, p := range vaPayments {
+		if p.CallbackVirtualAccountID == payment.ChargeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// voidCharge stops Xendit from accepting money for a payment.
+func voidCharge(ctx context.Context, payment *models.Payment) error {
+	switch payment.Method {
+	case models.MethodQRCode:
+		// Dynamic QR codes lapse at expires_at; nothing to call
+		return nil
+	case models.MethodVirtualAccount:
+		return xenditRequest(ctx, "PATCH", "/callback_virtual_accounts/"+url.PathEscape(payment.ChargeID), map[string]interface{}{
+			"expiration_date": time.Now().UTC().Format(time.RFC3339),
+		}, nil)
+	default:
+		return xenditRequest(ctx, "POST", "/ewallets/charges/"+url.PathEscape(payment.ChargeID)+"/void", map[string]interface{}{}, nil)
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// jsonAmount renders m as an exact JSON number in major units for Xendit.
+func jsonAmount(m models.Money) json.Number {
+	return json.Number(m.String())
+}
+
+// MigrateMoney rewrites payments whose amount is still a legacy number into
+// the {centavos, currency} document used by models.Money. It is idempotent and
+// returns the number of documents converted.
+func (s *PaymentService) MigrateMoney(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{"amount": bson.M{"$type": bson.A{"double", "int", "long", "decimal"}}}
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"amount": bson.M{
+				"centavos": bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{"$amount", 100}}, 0}}},
+				"currency": "PHP",
+			},
+		}},
+	}
+	result, err := s.db.Collection("payments").UpdateMany(ctx, filter, update)
+	if err != nil {
+		log.Printf("Failed to migrate payment amounts: %v", err)
+		return 0, fmt.Errorf("failed to migrate payment amounts: %v", err)
+	}
+	log.Printf("Migrated %d payment amounts to centavos", result.ModifiedCount)
+	return result.ModifiedCount, nil
+}
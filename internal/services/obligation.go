@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// getObligation retrieves an obligation by its ID.
+func (s *PaymentService) getObligation(ctx context.Context, obligationID string) (*models.Obligation, error) {
+	objID, err := primitive.ObjectIDFromHex(obligationID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid obligation_id format: %v", err)
+	}
+	var obligation models.Obligation
+	if err := s.db.Collection("obligations").FindOne(ctx, bson.M{"_id": objID}).Decode(&obligation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("obligation not found")
+		}
+		return nil, fmt.Errorf("failed to fetch obligation: %v", err)
+	}
+	return &obligation, nil
+}
+
+// obligationBalance returns what is still owed on an obligation. Obligations
+// issued before balances were tracked fall back to amount minus amount paid.
+func obligationBalance(o *models.Obligation) models.Money {
+	if o.Balance.Currency == "" {
+		return models.NewMoney(o.Amount.Centavos+o.Penalties.Centavos-o.Discounts.Centavos-o.Adjustments.Centavos-o.AmountPaid.Centavos, o.Amount.Currency)
+	}
+	return o.Balance
+}
+
+// pendingCharges are the amounts of an obligation still in flight.
+type pendingCharges struct {
+	Base    int64 `bson:"base"`    // Portion of the balance being paid
+	Penalty int64 `bson:"penalty"` // Late fees included on top
+}
+
+// obligationLockTTL is how long a payment lock holds if its holder never
+// releases it, e.g. because the process died mid-charge.
+const obligationLockTTL = time.Minute
+
+// lockObligation takes an obligation's payment lock, so only one installment
+// at a time reads the pending total and starts a charge against it. It
+// returns the token to unlock with.
+func (s *PaymentService) lockObligation(ctx context.Context, objID primitive.ObjectID) (string, error) {
+	token := primitive.NewObjectID().Hex()
+	now := time.Now()
+	result, err := s.db.Collection("obligations").UpdateOne(ctx, bson.M{
+		"_id": objID,
+		"$or": []bson.M{
+			{"payment_lock": bson.M{"$exists": false}},
+			{"payment_lock.expires_at": bson.M{"$lt": now}},
+		},
+	}, bson.M{"$set": bson.M{"payment_lock": models.ObligationLock{Token: token, ExpiresAt: now.Add(obligationLockTTL)}}})
+	if err != nil {
+		return "", fmt.Errorf("failed to lock obligation: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return "", fmt.Errorf("another payment on this obligation is being started, try again shortly")
+	}
+	return token, nil
+}
+
+// unlockObligation releases a payment lock taken by lockObligation.
+func (s *PaymentService) unlockObligation(objID primitive.ObjectID, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := s.db.Collection("obligations").UpdateOne(ctx, bson.M{"_id": objID, "payment_lock.token": token}, bson.M{
+		"$unset": bson.M{"payment_lock": ""},
+	})
+	if err != nil {
+		log.Printf("Failed to unlock obligation %s: %v", objID.Hex(), err)
+	}
+}
+
+// pendingObligationTotal sums the charges still in flight for an obligation:
+// those pending, and those that succeeded but are not credited to it yet.
+// Callers hold the obligation's payment lock, so concurrent installments
+// cannot add up to more than the balance or charge the same late fee twice.
+func (s *PaymentService) pendingObligationTotal(ctx context.Context, obligation *models.Obligation) (pendingCharges, error) {
+	credited := obligation.PaymentIDs
+	if credited == nil {
+		credited = []string{}
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{
+			"obligation_id": obligation.ID.Hex(),
+			"$or": []bson.M{
+				{"status": "PENDING", "disbursement_id": "", "$or": notLapsedFilter(time.Now())},
+				{"$and": []bson.M{chargeSucceededFilter(), {"_id": bson.M{"$nin": credited}}}},
+			},
+		}},
+		{"$group": bson.M{
+			"_id":     nil,
+			"base":    bson.M{"$sum": bson.M{"$ifNull": bson.A{"$breakdown.base.centavos", "$amount.centavos"}}},
+			"penalty": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$breakdown.penalty.centavos", 0}}},
+		}},
+	}
+	cur, err := s.db.Collection("payments").Aggregate(ctx, pipeline)
+	if err != nil {
+		return pendingCharges{}, fmt.Errorf("failed to compute pending payments: %v", err)
+	}
+	defer cur.Close(ctx)
+	var result []pendingCharges
+	if err := cur.All(ctx, &result); err != nil {
+		return pendingCharges{}, fmt.Errorf("failed to decode pending payments: %v", err)
+	}
+	if len(result) == 0 {
+		return pendingCharges{}, nil
+	}
+	return result[0], nil
+}
+
+// PayObligation starts a charge against what a member owes on an obligation.
+// The title and payee come from the obligation. preq.Amount may be less than
+// the balance to pay in installments; zero pays everything still owed. Late
+// fees accrued under the collection's penalty rule are added on top, and the
+// early-payment discount is taken off when the whole amount is paid in one go.
+func (s *PaymentService) PayObligation(ctx context.Context, obligationID, payerID string, preq PaymentRequest) (*models.Payment, error) {
+	obligation, err := s.getObligation(ctx, obligationID)
+	if err != nil {
+		return nil, err
+	}
+	if obligation.MemberID != payerID {
+		return nil, fmt.Errorf("obligation does not belong to this member")
+	}
+
+	// Hold the lock until the payment is saved, then re-read the obligation
+	// as it stands under the lock
+	token, err := s.lockObligation(ctx, obligation.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.unlockObligation(obligation.ID, token)
+	if obligation, err = s.getObligation(ctx, obligationID); err != nil {
+		return nil, err
+	}
+	if obligation.Status == "PAID" || obligation.Status == "WAIVED" {
+		return nil, fmt.Errorf("obligation is already settled")
+	}
+	penaltyRule, discountRule, err := s.obligationRules(ctx, obligation)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := s.pendingObligationTotal(ctx, obligation)
+	if err != nil {
+		return nil, err
+	}
+	balance := obligationBalance(obligation)
+	available := balance.Centavos - pending.Base
+	if available <= 0 {
+		return nil, fmt.Errorf("the remaining balance of %s is covered by payments still pending", balance.String())
+	}
+	if preq.Amount.Centavos == 0 {
+		preq.Amount = models.NewMoney(available, balance.Currency)
+	}
+	if preq.Amount.Currency != balance.Currency {
+		return nil, fmt.Errorf("amount currency %s does not match obligation currency %s", preq.Amount.Currency, balance.Currency)
+	}
+	if preq.Amount.Centavos > available {
+		return nil, fmt.Errorf("amount %s exceeds the remaining balance of %s", preq.Amount.String(), models.NewMoney(available, balance.Currency).String())
+	}
+
+	now := time.Now()
+	breakdown := &models.PaymentBreakdown{
+		Base:     preq.Amount,
+		Penalty:  models.NewMoney(0, balance.Currency),
+		Discount: models.NewMoney(0, balance.Currency),
+	}
+	if due := accruedPenalty(penaltyRule, obligation, now).Centavos - obligation.Penalties.Centavos - pending.Penalty; due > 0 {
+		breakdown.Penalty = models.NewMoney(due, balance.Currency)
+	}
+	// The discount is only for settling in full, before anything else was paid
+	if obligation.AmountPaid.Centavos == 0 && pending.Base == 0 && preq.Amount.Centavos == balance.Centavos {
+		breakdown.Discount = earlyDiscount(discountRule, obligation, now)
+	}
+	breakdown.Total = models.NewMoney(breakdown.Base.Centavos+breakdown.Penalty.Centavos-breakdown.Discount.Centavos, balance.Currency)
+
+	preq.Amount = breakdown.Total
+	preq.Breakdown = breakdown
+	preq.PayerID = payerID
+	preq.PayeeID = obligation.PayeeID
+	preq.Title = obligation.Title
+	preq.Description = obligation.Description
+	if preq.Description == "" {
+		preq.Description = obligation.Title
+	}
+	preq.ObligationID = obligationID
+	if invoice, err := issueInvoice(ctx, s.db, obligation); err != nil {
+		log.Printf("Failed to invoice obligation %s: %v", obligationID, err)
+	} else {
+		preq.InvoiceID = invoice.ID.Hex()
+	}
+	return s.CreatePayment(ctx, preq)
+}
+
+// derivedBalanceExpr is an aggregation expression computing an obligation's
+// balance in centavos from its amount, late fees, discounts, admin
+// adjustments and amount paid.
+func derivedBalanceExpr() bson.M {
+	return bson.M{"$subtract": bson.A{
+		bson.M{"$add": bson.A{"$amount.centavos", bson.M{"$ifNull": bson.A{"$penalties.centavos", 0}}}},
+		bson.M{"$add": bson.A{
+			bson.M{"$ifNull": bson.A{"$discounts.centavos", 0}},
+			bson.M{"$ifNull": bson.A{"$adjustments.centavos", 0}},
+			bson.M{"$ifNull": bson.A{"$amount_paid.centavos", 0}},
+		}},
+	}}
+}
+
+// balanceExpr is the aggregation counterpart of obligationBalance: the
+// stored balance in centavos, or the derived one for obligations issued
+// before balances were tracked.
+func balanceExpr() bson.M {
+	return bson.M{"$ifNull": bson.A{"$balance.centavos", derivedBalanceExpr()}}
+}
+
+// recomputeObligationStages are update pipeline stages that derive an
+// obligation's balance and status from its amount, late fees, discounts,
+// admin adjustments and amount paid. An obligation cleared by adjustments
+// alone is WAIVED rather than PAID.
+func recomputeObligationStages(now time.Time) []bson.M {
+	return []bson.M{
+		{"$set": bson.M{
+			"balance": bson.M{
+				"centavos": derivedBalanceExpr(),
+				"currency": "$amount.currency",
+			},
+		}},
+		{"$set": bson.M{
+			"status": bson.M{"$switch": bson.M{
+				"branches": bson.A{
+					bson.M{"case": bson.M{"$and": bson.A{
+						bson.M{"$lte": bson.A{"$balance.centavos", 0}},
+						bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$amount_paid.centavos", 0}}, 0}},
+					}}, "then": "WAIVED"},
+					bson.M{"case": bson.M{"$lte": bson.A{"$balance.centavos", 0}}, "then": "PAID"},
+					bson.M{"case": bson.M{"$gt": bson.A{bson.M{"$ifNull": bson.A{"$amount_paid.centavos", 0}}, 0}}, "then": "PARTIALLY_PAID"},
+				},
+				"default": "UNPAID",
+			}},
+			"updated_at": now,
+		}},
+		{"$set": bson.M{
+			"paid_at": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", "PAID"}}, bson.M{"$ifNull": bson.A{"$paid_at", now}}, "$$REMOVE"}},
+		}},
+	}
+}
+
+// settleObligation credits a succeeded payment to the obligation it was made
+// against, closing the obligation once nothing is left to pay.
+func (s *PaymentService) settleObligation(ctx context.Context, payment *models.Payment) error {
+	objID, err := primitive.ObjectIDFromHex(payment.ObligationID)
+	if err != nil {
+		return fmt.Errorf("invalid obligation_id format: %v", err)
+	}
+	now := time.Now()
+	credited := creditedAmount(payment)
+	penalty, discount := int64(0), int64(0)
+	if payment.Breakdown != nil {
+		penalty, discount = payment.Breakdown.Penalty.Centavos, payment.Breakdown.Discount.Centavos
+	}
+	update := []bson.M{
+		{"$set": bson.M{
+			"penalties": bson.M{
+				"centavos": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$penalties.centavos", 0}}, penalty}},
+				"currency": payment.Amount.Currency,
+			},
+			"discounts": bson.M{
+				"centavos": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$discounts.centavos", 0}}, discount}},
+				"currency": payment.Amount.Currency,
+			},
+			"amount_paid": bson.M{
+				"centavos": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$amount_paid.centavos", 0}}, credited.Centavos}},
+				"currency": payment.Amount.Currency,
+			},
+			"payment_ids": bson.M{"$concatArrays": bson.A{bson.M{"$ifNull": bson.A{"$payment_ids", bson.A{}}}, bson.A{payment.ID}}},
+		}},
+	}
+	update = append(update, recomputeObligationStages(now)...)
+
+	// payment_ids guards against crediting the same payment twice
+	result, err := s.db.Collection("obligations").UpdateOne(ctx, bson.M{"_id": objID, "payment_ids": bson.M{"$ne": payment.ID}}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update obligation: %v", err)
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Obligation %s credited %s by payment %s", payment.ObligationID, credited.String(), payment.ID)
+		syncInvoice(ctx, s.db, payment.ObligationID)
+	}
+	return nil
+}
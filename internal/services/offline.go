@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// offlineMethods are paid outside Xendit and confirmed by an admin.
+var offlineMethods = []string{models.MethodCash, models.MethodBankTransfer}
+
+// isOfflineMethod reports whether method is paid outside Xendit.
+func isOfflineMethod(method string) bool {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	return method == models.MethodCash || method == models.MethodBankTransfer
+}
+
+// proofBucket is the GridFS bucket holding proof-of-payment files.
+func (s *PaymentService) proofBucket() (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(s.db, options.GridFSBucket().SetName("proofs"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open proof storage: %v", err)
+	}
+	return bucket, nil
+}
+
+// createOfflinePayment records a PENDING cash or bank transfer payment. It
+// waits in the review queue until an admin approves or rejects it, so it has
+// no charge and never expires.
+func (s *PaymentService) createOfflinePayment(ctx context.Context, preq PaymentRequest) (*models.Payment, error) {
+	method := strings.ToUpper(strings.TrimSpace(preq.Method))
+	title := strings.TrimSpace(preq.Title)
+	description := strings.TrimSpace(preq.Description)
+	if preq.Amount.Currency != "PHP" || !preq.Amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be a positive PHP amount")
+	}
+	if title == "" {
+		return nil, fmt.Errorf("title cannot be empty")
+	}
+	if description == "" {
+		description = title
+	}
+
+	payerObjID, err := primitive.ObjectIDFromHex(strings.TrimSpace(preq.PayerID))
+	if err != nil {
+		return nil, fmt.Errorf("invalid payer_id format: %v", err)
+	}
+	payeeID := strings.TrimSpace(preq.PayeeID)
+	if payeeID == "" {
+		payeeID = defaultPayeeID
+	}
+	payeeObjID, err := primitive.ObjectIDFromHex(payeeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payee_id format: %v", err)
+	}
+	var users [2]models.User
+	for i, id := range []primitive.ObjectID{payerObjID, payeeObjID} {
+		if err := s.db.Collection("user").FindOne(ctx, bson.M{"_id": id}).Decode(&users[i]); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, fmt.Errorf("user %s not found", id.Hex())
+			}
+			return nil, fmt.Errorf("failed to fetch user %s: %v", id.Hex(), err)
+		}
+	}
+	if preq.ObligationID == "" {
+		if err := checkPayee(&users[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	payment := &models.Payment{
+		ID:             primitive.NewObjectID().Hex(),
+		ReferenceID:    primitive.NewObjectID().Hex(),
+		PayerID:        payerObjID.Hex(),
+		PayeeID:        payeeID,
+		ObligationID:   preq.ObligationID,
+		InvoiceID:      preq.InvoiceID,
+		Breakdown:      preq.Breakdown,
+		Amount:         preq.Amount,
+		Title:          title,
+		Description:    description,
+		Status:         "PENDING",
+		Method:         method,
+		RefundedAmount: models.NewMoney(0, preq.Amount.Currency),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if _, err := s.db.Collection("payments").InsertOne(ctx, payment); err != nil {
+		log.Printf("Failed to save offline payment: %v", err)
+		return nil, fmt.Errorf("failed to save payment: %v", err)
+	}
+	log.Printf("Offline payment recorded: ID=%s, Method=%s, Payer=%s, Amount=%s", payment.ID, method, payment.PayerID, payment.Amount.String())
+	return payment, nil
+}
+
+// AttachProof stores a receipt for a PENDING offline payment, replacing any
+// earlier upload. Only the payer or an admin may attach one.
+func (s *PaymentService) AttachProof(ctx context.Context, paymentID, userID string, isAdmin bool, fileName, contentType string, file io.Reader) (*models.Payment, error) {
+	payment, err := s.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.PayerID != userID && !isAdmin {
+		return nil, fmt.Errorf("payment does not belong to this user")
+	}
+	if !isOfflineMethod(payment.Method) {
+		return nil, fmt.Errorf("proof of payment only applies to cash and bank transfer payments")
+	}
+	if payment.Status != "PENDING" {
+		return nil, fmt.Errorf("payment has already been reviewed")
+	}
+
+	bucket, err := s.proofBucket()
+	if err != nil {
+		return nil, err
+	}
+	uploadOpts := options.GridFSUpload().SetMetadata(bson.M{"payment_id": paymentID, "content_type": contentType})
+	fileID, err := bucket.UploadFromStream(fileName, file, uploadOpts)
+	if err != nil {
+		log.Printf("Failed to store proof for payment %s: %v", paymentID, err)
+		return nil, fmt.Errorf("failed to store proof: %v", err)
+	}
+	var stored struct {
+		Length int64 `bson:"length"`
+	}
+	if err := s.db.Collection("proofs.files").FindOne(ctx, bson.M{"_id": fileID}).Decode(&stored); err != nil {
+		log.Printf("Failed to read stored proof %s: %v", fileID.Hex(), err)
+	}
+
+	proof := &models.ProofOfPayment{
+		FileID:      fileID,
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        stored.Length,
+		UploadedBy:  userID,
+		UploadedAt:  time.Now(),
+	}
+	result, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": paymentID, "status": "PENDING"}, bson.M{
+		"$set": bson.M{"proof": proof, "updated_at": proof.UploadedAt},
+	})
+	if err != nil || result.ModifiedCount == 0 {
+		if delErr := bucket.Delete(fileID); delErr != nil {
+			log.Printf("Failed to remove orphaned proof %s: %v", fileID.Hex(), delErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach proof: %v", err)
+		}
+		return nil, fmt.Errorf("payment has already been reviewed")
+	}
+	if payment.Proof != nil {
+		if err := bucket.Delete(payment.Proof.FileID); err != nil {
+			log.Printf("Failed to remove replaced proof %s: %v", payment.Proof.FileID.Hex(), err)
+		}
+	}
+
+	payment.Proof = proof
+	log.Printf("Proof attached to payment %s: File=%s, Size=%d", paymentID, fileName, proof.Size)
+	return payment, nil
+}
+
+// OpenProof returns the proof of a payment and a reader over the stored file,
+// which the caller must close. Only the payer or an admin may read it.
+func (s *PaymentService) OpenProof(ctx context.Context, paymentID, userID string, isAdmin bool) (*models.ProofOfPayment, io.ReadCloser, error) {
+	payment, err := s.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payment.PayerID != userID && !isAdmin {
+		return nil, nil, fmt.Errorf("payment does not belong to this user")
+	}
+	if payment.Proof == nil {
+		return nil, nil, fmt.Errorf("proof not found")
+	}
+	bucket, err := s.proofBucket()
+	if err != nil {
+		return nil, nil, err
+	}
+	stream, err := bucket.OpenDownloadStream(payment.Proof.FileID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open proof: %v", err)
+	}
+	return payment.Proof, stream, nil
+}
+
+// GetOfflineQueue lists the offline payments waiting for review, oldest first.
+func (s *PaymentService) GetOfflineQueue(ctx context.Context) ([]models.Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("payments").Find(ctx, bson.M{
+		"method": bson.M{"$in": offlineMethods},
+		"status": "PENDING",
+	}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		log.Printf("Failed to fetch offline review queue: %v", err)
+		return nil, fmt.Errorf("failed to fetch review queue: %v", err)
+	}
+	payments := []models.Payment{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &payments); err != nil {
+		return nil, fmt.Errorf("failed to decode review queue: %v", err)
+	}
+	return payments, nil
+}
+
+// ReviewOfflinePayment approves or rejects a PENDING offline payment. Approval
+// moves it to SUCCEEDED and credits its obligation like any settled charge;
+// rejection moves it to FAILED. Bank transfers need a proof to be approved.
+func (s *PaymentService) ReviewOfflinePayment(ctx context.Context, paymentID, adminID string, approve bool, note string) (*models.Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	payment, err := s.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOfflineMethod(payment.Method) {
+		return nil, fmt.Errorf("only cash and bank transfer payments are reviewed")
+	}
+	if payment.Status != "PENDING" || payment.Review != nil {
+		return nil, fmt.Errorf("payment has already been reviewed")
+	}
+	if approve && payment.Method == models.MethodBankTransfer && payment.Proof == nil {
+		return nil, fmt.Errorf("bank transfers need a proof of payment before approval")
+	}
+
+	review := &models.PaymentReview{
+		Decision:   "REJECTED",
+		Note:       strings.TrimSpace(note),
+		ReviewedBy: adminID,
+		ReviewedAt: time.Now(),
+	}
+	status := "FAILED"
+	if approve {
+		review.Decision = "APPROVED"
+		status = "SUCCEEDED"
+	}
+
+	// Claim the review so two admins cannot decide the same payment
+	result, err := s.db.Collection("payments").UpdateOne(ctx,
+		bson.M{"_id": paymentID, "status": "PENDING", "review": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"review": review}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record review: %v", err)
+	}
+	if result.ModifiedCount == 0 {
+		return nil, fmt.Errorf("payment has already been reviewed")
+	}
+	payment.Review = review
+
+	if _, err := s.applyChargeStatus(ctx, payment, status); err != nil {
+		return nil, err
+	}
+	log.Printf("Offline payment %s %s by %s", paymentID, strings.ToLower(review.Decision), adminID)
+	return payment, nil
+}
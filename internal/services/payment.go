@@ -21,19 +21,28 @@ import (
 	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
 )
 
+// defaultPayeeID is the treasurer account that receives payments made without a payee.
+const defaultPayeeID = "68d6aadf4ee098645ac87d5d"
+
 type PaymentService struct {
-	db *mongo.Database
+	db     *mongo.Database
+	events *paymentEvents
 }
 
 func NewPaymentService(db *mongo.Database) *PaymentService {
-	return &PaymentService{db: db}
+	_, err := db.Collection("payments").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.M{"receipt_seq": 1}, Options: options.Index().SetUnique(true).SetSparse(true)},
+	})
+	if err != nil {
+		log.Fatalf("error creating index for payments: %v", err)
+	}
+	return &PaymentService{db: db, events: newPaymentEvents()}
 }
 
 // GetPaymentByID retrieves a single payment by its ID.
 func (s *PaymentService) GetPaymentByID(ctx context.Context, paymentID string) (*models.Payment, error) {
-	// Validate paymentID format
-	paymentObjID, err := primitive.ObjectIDFromHex(paymentID)
-	if err != nil {
+	// Validate paymentID format (payments are stored with the hex string as _id)
+	if _, err := primitive.ObjectIDFromHex(paymentID); err != nil {
 		log.Printf("Invalid paymentID format: %s, error: %v", paymentID, err)
 		return nil, fmt.Errorf("invalid payment_id format: %v", err)
 	}
@@ -44,7 +53,7 @@ func (s *PaymentService) GetPaymentByID(ctx context.Context, paymentID string) (
 
 	// Find the payment
 	var payment models.Payment
-	if err := s.db.Collection("payments").FindOne(ctx, bson.M{"_id": paymentObjID}).Decode(&payment); err != nil {
+	if err := s.db.Collection("payments").FindOne(ctx, bson.M{"_id": paymentID}).Decode(&payment); err != nil {
 		if err == mongo.ErrNoDocuments {
 			log.Printf("Payment not found for ID %s", paymentID)
 			return nil, fmt.Errorf("payment not found")
@@ -58,6 +67,30 @@ func (s *PaymentService) GetPaymentByID(ctx context.Context, paymentID string) (
 
 // GetPayments retrieves all payments with optional filtering by status and date range.
 func (s *PaymentService) GetPayments(ctx context.Context, statusFilter, startDate, endDate *string) ([]models.Payment, error) {
+	return s.findPayments(ctx, bson.M{}, statusFilter, startDate, endDate)
+}
+
+// GetPaymentsByUserID retrieves the payments a user made (role "payer", the
+// default) or received (role "payee"), with the same filters as GetPayments.
+func (s *PaymentService) GetPaymentsByUserID(ctx context.Context, userID, role string, statusFilter, startDate, endDate *string) ([]models.Payment, error) {
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		log.Printf("Invalid userID format: %s, error: %v", userID, err)
+		return nil, fmt.Errorf("invalid user_id format: %v", err)
+	}
+
+	switch role {
+	case "", "payer":
+		return s.findPayments(ctx, bson.M{"payer_id": userID}, statusFilter, startDate, endDate)
+	case "payee":
+		return s.findPayments(ctx, bson.M{"payee_id": userID}, statusFilter, startDate, endDate)
+	default:
+		return nil, fmt.Errorf("invalid role, must be payer or payee")
+	}
+}
+
+// findPayments retrieves the payments matching scope, optionally filtered by
+// status and date range.
+func (s *PaymentService) findPayments(ctx context.Context, scope bson.M, statusFilter, startDate, endDate *string) ([]models.Payment, error) {
 	// Set query timeout
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
@@ -65,6 +98,10 @@ func (s *PaymentService) GetPayments(ctx context.Context, statusFilter, startDat
 	// Build query
 	query := bson.M{
 		"status": bson.M{"$in": []string{"PENDING", "SUCCEEDED"}}, // Only include PENDING and SUCCEEDED
+		"$or":    notLapsedFilter(time.Now()),                     // Skip PENDING payments past expiry the sweeper has not reached yet
+	}
+	for field, value := range scope {
+		query[field] = value
 	}
 
 	// Add status filter if provided
@@ -117,8 +154,8 @@ func (s *PaymentService) GetPayments(ctx context.Context, statusFilter, startDat
 }
 
 func (s *PaymentService) UpdatePayment(ctx context.Context, paymentID, userID string) (*models.Payment, error) {
-	// Set query timeout
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	// Set query timeout, long enough to ask Xendit and disburse
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
 	// Validate paymentID format
@@ -140,18 +177,25 @@ func (s *PaymentService) UpdatePayment(ctx context.Context, paymentID, userID st
 		log.Printf("Cannot update payment %s with status %s", paymentID, payment.Status)
 		return nil, fmt.Errorf("can only update payment with status PENDING, current status is %s", payment.Status)
 	}
-
-	// Update payment status to SUCCEEDED
-	updateFields := bson.M{
-		"status":     "SUCCEEDED",
-		"updated_at": time.Now(),
+	// Cash and bank transfers are confirmed by an admin reviewing the proof
+	if isOfflineMethod(payment.Method) {
+		return nil, fmt.Errorf("offline payments are confirmed by an admin, not through this endpoint")
 	}
 
-	// Update payment in database
-	_, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": paymentObjID}, bson.M{"$set": updateFields})
+	// The caller is not authenticated, so take the status from Xendit rather
+	// than trusting the redirect, and apply it like the webhook would
+	status, err := fetchChargeStatus(ctx, &payment)
 	if err != nil {
-		log.Printf("Failed to update payment %s: %v", paymentID, err)
-		return nil, fmt.Errorf("failed to update payment: %v", err)
+		log.Printf("Failed to fetch charge status for payment %s: %v", paymentID, err)
+		return nil, fmt.Errorf("failed to fetch charge status: %v", err)
+	}
+	if status != "SUCCEEDED" {
+		log.Printf("Payment %s not confirmed, charge status is %s", paymentID, status)
+		return nil, fmt.Errorf("payment is not completed yet, charge status is %s", status)
+	}
+	// A failed disbursement does not undo the charge; the reconciler retries it
+	if _, err := s.applyChargeStatus(ctx, &payment, status); err != nil {
+		log.Printf("Failed to apply charge status to payment %s: %v", paymentID, err)
 	}
 
 	// Fetch updated payment
@@ -160,31 +204,82 @@ func (s *PaymentService) UpdatePayment(ctx context.Context, paymentID, userID st
 		log.Printf("Failed to fetch updated payment %s: %v", paymentID, err)
 		return nil, fmt.Errorf("failed to fetch updated payment: %v", err)
 	}
+	if !chargeSucceeded(&updatedPayment) {
+		return nil, fmt.Errorf("failed to update payment, current status is %s", updatedPayment.Status)
+	}
 
 	log.Printf("Payment status updated to SUCCEEDED: ID=%s", paymentID)
 	return &updatedPayment, nil
 }
 
-func (s *PaymentService) CreatePayment(ctx context.Context, payerID, payeeID string, amount float64, title, description string) (*models.Payment, error) {
+// checkPayee rejects payees other than the organization treasurer and
+// admins, so a payer cannot direct funds, and their payout, to any member.
+// Obligation payments are exempt: they go to the payee an admin set on the
+// collection.
+func checkPayee(payee *models.User) error {
+	if payee.ID.Hex() != defaultPayeeID && payee.Role != "admin" {
+		return fmt.Errorf("invalid payee_id, payments can only be made to the treasurer or an admin")
+	}
+	return nil
+}
+
+// PaymentRequest holds what a payer submits to start a charge.
+type PaymentRequest struct {
+	PayerID     string
+	PayeeID     string
+	Amount      models.Money
+	Title       string
+	Description string
+	// Method is models.MethodEWallet, models.MethodQRCode or models.MethodVirtualAccount.
+	// Empty means models.MethodEWallet.
+	Method string
+	// ChannelCode is the Xendit channel to charge, e.g. "PH_PAYMAYA" or a bank
+	// code for virtual accounts. Empty uses the method's default (PH_GCASH, QRPH).
+	ChannelCode string
+	// MobileNumber is the e-wallet account to charge. Empty uses the payer's GCash number.
+	MobileNumber string
+	// ExpiresIn is how long the charge stays PENDING. Zero uses the PAYMENT_EXPIRY default.
+	ExpiresIn time.Duration
+	// ObligationID links the payment to the collection obligation it settles.
+	ObligationID string
+	// InvoiceID is the obligation's invoice the payment is made against.
+	InvoiceID string
+	// Breakdown records the late fees and discounts included in Amount.
+	Breakdown *models.PaymentBreakdown
+}
+
+// CreatePayment charges the payer through Xendit and records a PENDING payment.
+func (s *PaymentService) CreatePayment(ctx context.Context, preq PaymentRequest) (*models.Payment, error) {
 	// Set query timeout
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	// Log input
-	payerID = strings.TrimSpace(payerID)
-	payeeID = strings.TrimSpace(payeeID)
-	title = strings.TrimSpace(title)
-	description = strings.TrimSpace(description)
-	log.Printf("Creating payment: payerID=%s, payeeID=%s, amount=%f, title=%s, description=%s", payerID, payeeID, amount, title, description)
+	payerID := strings.TrimSpace(preq.PayerID)
+	payeeID := strings.TrimSpace(preq.PayeeID)
+	amount := preq.Amount
+	title := strings.TrimSpace(preq.Title)
+	description := strings.TrimSpace(preq.Description)
+	expiresIn := preq.ExpiresIn
+	log.Printf("Creating payment: payerID=%s, payeeID=%s, amount=%s %s, method=%s, channel=%s, title=%s, description=%s", payerID, payeeID, amount.String(), amount.Currency, preq.Method, preq.ChannelCode, title, description)
 
 	// Validate input
 	if payerID == "" {
 		log.Printf("Invalid input: payerID or payeeID is empty")
 		return nil, fmt.Errorf("payer_id and payee_id cannot be empty")
 	}
-	if amount <= 0 {
-		log.Printf("Invalid input: amount=%f is not positive", amount)
-		return nil, fmt.Errorf("amount must be positive")
+	// Cash and bank transfers never touch Xendit; an admin confirms them instead
+	if isOfflineMethod(preq.Method) {
+		return s.createOfflinePayment(ctx, preq)
+	}
+	channelCode, channel, err := lookupChannel(preq.Method, preq.ChannelCode)
+	if err != nil {
+		log.Printf("Invalid input: %v", err)
+		return nil, err
+	}
+	if err := validateChannelAmount(channelCode, amount); err != nil {
+		log.Printf("Invalid input: amount=%s %s: %v", amount.String(), amount.Currency, err)
+		return nil, err
 	}
 	if title == "" {
 		log.Printf("Invalid input: title is empty")
@@ -194,6 +289,13 @@ func (s *PaymentService) CreatePayment(ctx context.Context, payerID, payeeID str
 		log.Printf("Invalid input: description is empty")
 		return nil, fmt.Errorf("description cannot be empty")
 	}
+	if expiresIn < 0 {
+		log.Printf("Invalid input: expiresIn=%s is negative", expiresIn)
+		return nil, fmt.Errorf("expiry cannot be negative")
+	}
+	if expiresIn == 0 {
+		expiresIn = defaultPaymentExpiry()
+	}
 
 	// Convert string IDs to ObjectID
 	payerObjID, err := primitive.ObjectIDFromHex(payerID)
@@ -201,7 +303,11 @@ func (s *PaymentService) CreatePayment(ctx context.Context, payerID, payeeID str
 		log.Printf("Invalid payerID format: %s, error: %v", payerID, err)
 		return nil, fmt.Errorf("invalid payer_id format: %v", err)
 	}
-	payeeObjID, err := primitive.ObjectIDFromHex("68d6aadf4ee098645ac87d5d")
+	// Payments without an explicit payee go to the organization treasurer
+	if payeeID == "" {
+		payeeID = defaultPayeeID
+	}
+	payeeObjID, err := primitive.ObjectIDFromHex(payeeID)
 	if err != nil {
 		log.Printf("Invalid payeeID format: %s, error: %v", payeeID, err)
 		return nil, fmt.Errorf("invalid payee_id format: %v", err)
@@ -228,21 +334,80 @@ func (s *PaymentService) CreatePayment(ctx context.Context, payerID, payeeID str
 		return nil, fmt.Errorf("failed to fetch payee: %v", err)
 	}
 	log.Printf("Payee found: ID=%s, FullName=%s, GCashNumber=%s", payee.ID.Hex(), payee.FullName, payee.GCashNumber)
+	if preq.ObligationID == "" {
+		if err := checkPayee(&payee); err != nil {
+			log.Printf("Rejected payee %s for payer %s: %v", payeeID, payerID, err)
+			return nil, err
+		}
+	}
 
-	if payer.GCashNumber == "" || payee.GCashNumber == "" {
-		log.Printf("GCash number missing: payer=%s, payee=%s", payer.GCashNumber, payee.GCashNumber)
-		return nil, fmt.Errorf("payer or payee GCash number missing")
+	if _, _, err := payoutDestination(&payee); err != nil {
+		log.Printf("Payee %s cannot receive payouts: %v", payee.ID.Hex(), err)
+		return nil, fmt.Errorf("payee cannot receive payouts: %v", err)
+	}
+
+	// Fees are fixed at charge time; a convenience fee is added to the charge
+	policy, err := s.feePolicy(ctx, payeeID)
+	if err != nil {
+		log.Printf("Failed to load fee policy for payee %s: %v", payeeID, err)
+		return nil, err
+	}
+	fees, err := computeFees(policy, channel.Method, amount)
+	if err != nil {
+		log.Printf("Invalid input: %v", err)
+		return nil, err
+	}
+	if fees != nil && fees.ConvenienceFee.IsPositive() {
+		amount = models.NewMoney(amount.Centavos+fees.ConvenienceFee.Centavos, amount.Currency)
+		if err := validateChannelAmount(channelCode, amount); err != nil {
+			log.Printf("Invalid input: amount with fees=%s: %v", amount.String(), err)
+			return nil, err
+		}
 	}
 
-	// Validate GCash number format (must start with 0 and have 11 digits)
-	if !strings.HasPrefix(payer.GCashNumber, "0") || len(payer.GCashNumber) != 11 {
-		log.Printf("Invalid payer GCash number format: %s", payer.GCashNumber)
-		return nil, fmt.Errorf("payer GCash number must start with 0 and be 11 digits")
+	// QR Ph and virtual accounts are created through their own Xendit APIs
+	if channel.Method != models.MethodEWallet {
+		now := time.Now()
+		payment := &models.Payment{
+			ID:           primitive.NewObjectID().Hex(),
+			ReferenceID:  primitive.NewObjectID().Hex(),
+			PayerID:      payerID,
+			PayeeID:      payeeID,
+			ObligationID: preq.ObligationID,
+			InvoiceID:    preq.InvoiceID,
+			Breakdown:    preq.Breakdown,
+			Fees:         fees,
+			Amount:       amount,
+			Title:        title,
+			Description:  description,
+			Status:       "PENDING",
+			Method:       channel.Method,
+			ChannelCode:  channelCode,
+			ExpiresAt:    now.Add(expiresIn),
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		return s.createAlternativePayment(ctx, payment, &payer)
 	}
 
 	// Format mobile number for Xendit (use +63 for eWallet charge)
-	mobileNumber := "+63" + payer.GCashNumber[1:]
-	log.Printf("Formatted mobile number for Xendit: %s", mobileNumber)
+	var mobileNumber string
+	if channel.RequiresMobile || strings.TrimSpace(preq.MobileNumber) != "" {
+		rawNumber := strings.TrimSpace(preq.MobileNumber)
+		if rawNumber == "" {
+			rawNumber = payer.GCashNumber
+		}
+		if rawNumber == "" {
+			log.Printf("Mobile number missing for payer %s on %s", payer.ID.Hex(), channelCode)
+			return nil, fmt.Errorf("%s requires a mobile number", channel.Name)
+		}
+		mobileNumber, err = normalizeMobileNumber(rawNumber)
+		if err != nil {
+			log.Printf("Invalid payer mobile number format: %s", rawNumber)
+			return nil, fmt.Errorf("invalid %s mobile number: %v", channel.Name, err)
+		}
+		log.Printf("Formatted mobile number for Xendit: %s", mobileNumber)
+	}
 
 	// Get ngrok URL from environment variable
 	ngrokURL := os.Getenv("RENDER_EXTERNAL_URL")
@@ -260,19 +425,25 @@ func (s *PaymentService) CreatePayment(ctx context.Context, payerID, payeeID str
 
 	// Prepare Xendit charge request
 	referenceID := primitive.NewObjectID().Hex()
+	channelProperties := map[string]interface{}{
+		"success_redirect_url": ngrokURL + "/api/updatepayment/" + copyid,
+		"failure_redirect_url": ngrokURL + "/api/updatepayment/",
+	}
+	if mobileNumber != "" {
+		channelProperties["mobile_number"] = mobileNumber
+	}
+	if channel.RequiresCancelURL {
+		channelProperties["cancel_redirect_url"] = ngrokURL + "/api/updatepayment/"
+	}
 	chargeReq := map[string]interface{}{
-		"reference_id":    referenceID,
-		"channel_code":    "PH_GCASH",
-		"amount":          amount,
-		"currency":        "PHP",
-		"checkout_method": "ONE_TIME_PAYMENT",
-		"title":           title,
-		"description":     description,
-		"channel_properties": map[string]interface{}{
-			"mobile_number":        mobileNumber,
-			"success_redirect_url": ngrokURL + "/api/updatepayment/" + copyid,
-			"failure_redirect_url": ngrokURL + "/api/updatepayment/",
-		},
+		"reference_id":       referenceID,
+		"channel_code":       channelCode,
+		"amount":             jsonAmount(amount),
+		"currency":           amount.Currency,
+		"checkout_method":    "ONE_TIME_PAYMENT",
+		"title":              title,
+		"description":        description,
+		"channel_properties": channelProperties,
 	}
 	reqBody, err := json.Marshal(chargeReq)
 	if err != nil {
@@ -329,12 +500,18 @@ func (s *PaymentService) CreatePayment(ctx context.Context, payerID, payeeID str
 		return nil, fmt.Errorf("failed to decode charge response: %v", err)
 	}
 
-	// Use mobile_deeplink_checkout_url if available, otherwise fall back to mobile_web_checkout_url
+	// Use mobile_deeplink_checkout_url if available, otherwise fall back to
+	// mobile_web_checkout_url, then desktop_web_checkout_url (GrabPay and
+	// ShopeePay do not always return mobile URLs)
 	checkoutURL := chargeResp.Actions.MobileDeeplinkCheckoutURL
 	if checkoutURL == "" {
 		checkoutURL = chargeResp.Actions.MobileWebCheckoutURL
 		log.Printf("Mobile deeplink URL is null, using mobile web checkout URL: %s", checkoutURL)
 	}
+	if checkoutURL == "" {
+		checkoutURL = chargeResp.Actions.DesktopWebCheckoutURL
+		log.Printf("Mobile web URL is null, using desktop web checkout URL: %s", checkoutURL)
+	}
 	if checkoutURL == "" {
 		log.Printf("No valid checkout URL found in response")
 		return nil, fmt.Errorf("no valid checkout URL provided in response")
@@ -349,19 +526,27 @@ func (s *PaymentService) CreatePayment(ctx context.Context, payerID, payeeID str
 	}
 
 	// Save payment
+	now := time.Now()
 	payment := &models.Payment{
-		ID:          copyid,
-		ReferenceID: referenceID,
-		PayerID:     payerID,
-		PayeeID:     payeeID,
-		Amount:      amount,
-		Title:       title,
-		Description: description,
-		Status:      chargeResp.Status,
-		ChargeID:    chargeResp.ID,
-		CheckoutURL: checkoutURL,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		ID:           copyid,
+		ReferenceID:  referenceID,
+		PayerID:      payerID,
+		PayeeID:      payeeID,
+		ObligationID: preq.ObligationID,
+		InvoiceID:    preq.InvoiceID,
+		Breakdown:    preq.Breakdown,
+		Fees:         fees,
+		Amount:       amount,
+		Title:        title,
+		Description:  description,
+		Status:       chargeResp.Status,
+		Method:       models.MethodEWallet,
+		ChannelCode:  channelCode,
+		ChargeID:     chargeResp.ID,
+		CheckoutURL:  checkoutURL,
+		ExpiresAt:    now.Add(expiresIn),
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	}
 	_, err = s.db.Collection("payments").InsertOne(ctx, payment)
 	if err != nil {
@@ -380,15 +565,14 @@ func (s *PaymentService) CreateDisbursement(ctx context.Context, paymentID strin
 
 	// Validate paymentID
 	paymentID = strings.TrimSpace(paymentID)
-	paymentObjID, err := primitive.ObjectIDFromHex(paymentID)
-	if err != nil {
+	if _, err := primitive.ObjectIDFromHex(paymentID); err != nil {
 		log.Printf("Invalid paymentID format: %s, error: %v", paymentID, err)
 		return fmt.Errorf("invalid payment_id format: %v", err)
 	}
 
 	// Find the payment
 	var payment models.Payment
-	if err := s.db.Collection("payments").FindOne(ctx, bson.M{"_id": paymentObjID}).Decode(&payment); err != nil {
+	if err := s.db.Collection("payments").FindOne(ctx, bson.M{"_id": paymentID}).Decode(&payment); err != nil {
 		if err == mongo.ErrNoDocuments {
 			log.Printf("Payment not found for ID %s", paymentID)
 			return fmt.Errorf("payment not found")
@@ -402,6 +586,16 @@ func (s *PaymentService) CreateDisbursement(ctx context.Context, paymentID strin
 		log.Printf("Cannot disburse payment %s with status %s", paymentID, payment.Status)
 		return fmt.Errorf("can only disburse payment with status SUCCEEDED, current status is %s", payment.Status)
 	}
+	if isOfflineMethod(payment.Method) {
+		return fmt.Errorf("offline payments are already with the payee and are not disbursed")
+	}
+	if payment.PayoutMode == "BATCH" {
+		return fmt.Errorf("payment is paid out in the payee's next settlement")
+	}
+	if payment.DisbursementID != "" {
+		log.Printf("Payment %s was already disbursed as %s", paymentID, payment.DisbursementID)
+		return fmt.Errorf("payment was already disbursed")
+	}
 
 	// Find payee
 	var payee models.User
@@ -419,89 +613,56 @@ func (s *PaymentService) CreateDisbursement(ctx context.Context, paymentID strin
 		return fmt.Errorf("failed to fetch payee: %v", err)
 	}
 
-	// Use local account number for Xendit disbursement
-	accountNumber := payee.GCashNumber
-	log.Printf("Using account number for disbursement: %s", accountNumber)
-
-	// Prepare disbursement request
-	disReq := map[string]interface{}{
-		"reference_id":        payment.ReferenceID + "-disb",
-		"channel_code":        "PH_GCASH",
-		"account_number":      accountNumber,
-		"account_holder_name": payee.FullName,
-		"amount":              payment.Amount,
-		"currency":            "PHP",
-		"description":         payment.Title,
-	}
-	reqBody, err := json.Marshal(disReq)
+	// Large payouts and payouts to a newly changed number wait for admins
+	approval, err := s.holdPayout(ctx, &payee, payoutAmount(&payment), payment.ApprovalID, paymentID, "")
 	if err != nil {
-		log.Printf("Failed to marshal disbursement request: %v", err)
-		return fmt.Errorf("failed to marshal disbursement request: %v", err)
+		return err
 	}
-
-	// Log the request body for debugging
-	log.Printf("Xendit disbursement request body: %s", string(reqBody))
-
-	// Make HTTP request to Xendit with retry logic
-	client := &http.Client{Timeout: 10 * time.Second}
-	var resp *http.Response
-	for retries := 3; retries > 0; retries-- {
-		req, err := http.NewRequestWithContext(ctx, "POST", "https://api.xendit.co/disbursements", bytes.NewBuffer(reqBody))
-		if err != nil {
-			log.Printf("Failed to create disbursement request: %v", err)
-			return fmt.Errorf("failed to create disbursement request: %v", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(os.Getenv("XENDIT_SECRET_KEY")+":")))
-
-		resp, err = client.Do(req)
-		if err == nil && resp.StatusCode == http.StatusCreated {
-			break
+	if approval != nil {
+		if approval.ID.Hex() != payment.ApprovalID || approval.Status != payment.ApprovalStatus {
+			_, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": paymentID}, bson.M{
+				"$set": bson.M{"approval_id": approval.ID.Hex(), "approval_status": approval.Status, "updated_at": time.Now()},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to hold payment for approval: %v", err)
+			}
 		}
-		if err != nil {
-			log.Printf("Disbursement request failed (attempt %d): %v", 4-retries, err)
-		} else {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			log.Printf("Disbursement failed with status %d (attempt %d): %s", resp.StatusCode, 4-retries, string(body))
+		if approval.Status == "REJECTED" {
+			return fmt.Errorf("payout was rejected by an approver")
 		}
-		time.Sleep(time.Second * time.Duration(3-retries))
-	}
-	if err != nil || resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Disbursement failed after retries: %v, status %d: %s", err, resp.StatusCode, string(body))
-		return fmt.Errorf("disbursement failed after retries: %v, status %d: %s", err, resp.StatusCode, string(body))
-	}
-	defer resp.Body.Close()
-
-	var disResp struct {
-		ID     string `json:"id"`
-		Status string `json:"status"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&disResp); err != nil {
-		log.Printf("Failed to decode disbursement response: %v", err)
-		return fmt.Errorf("failed to decode disbursement response: %v", err)
+		log.Printf("Disbursement for payment %s is awaiting approval %s", paymentID, approval.ID.Hex())
+		return nil
 	}
 
-	// Ensure status is either PENDING or SUCCEEDED
-	if disResp.Status != "PENDING" && disResp.Status != "SUCCEEDED" {
-		log.Printf("Invalid disbursement status from Xendit: %s, defaulting to SUCCEEDED", disResp.Status)
-		disResp.Status = "SUCCEEDED"
+	disResp, err := sendDisbursement(ctx, &payee, payment.ReferenceID+"-disb", payment.Title, payoutAmount(&payment))
+	if err != nil {
+		return err
 	}
 
-	// Update payment
+	// Update payment; only one caller records the payout, the idempotency key
+	// having handed any concurrent caller the same disbursement
 	update := bson.M{
 		"$set": bson.M{
 			"disbursement_id": disResp.ID,
+			"payout_channel":  disResp.Channel,
 			"status":          disResp.Status,
 			"updated_at":      time.Now(),
 		},
 	}
-	_, err = s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": paymentObjID}, update)
+	result, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": paymentID, "disbursement_id": ""}, update)
 	if err != nil {
 		log.Printf("Failed to update payment with disbursement ID: %v", err)
 		return fmt.Errorf("failed to update payment: %v", err)
 	}
+	if result.MatchedCount == 0 {
+		log.Printf("Disbursement %s for payment %s was already recorded", disResp.ID, paymentID)
+		return nil
+	}
+	recordLedger(ctx, s.db, disbursementLedger(&payment, payoutAmount(&payment)))
+	s.publishPayments(ctx, bson.M{"_id": paymentID})
+	if disResp.Status == "SUCCEEDED" {
+		emitDisbursedWebhooks(ctx, s.db, bson.M{"_id": paymentID})
+	}
 
 	log.Printf("Disbursement created: ID=%s, PaymentID=%s, Status=%s", disResp.ID, paymentID, disResp.Status)
 	return nil
@@ -512,6 +673,12 @@ func (s *PaymentService) HandleWebhook(ctx context.Context, payload map[string]i
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	// Virtual account payment callbacks carry no event field
+	if vaID, ok := payload["callback_virtual_account_id"].(string); ok {
+		log.Printf("Received virtual account payment webhook: account=%s", vaID)
+		return s.handleChargeWebhook(ctx, vaID, "SUCCEEDED")
+	}
+
 	eventType, ok := payload["event"].(string)
 	if !ok {
 		log.Printf("Invalid webhook event type")
@@ -528,42 +695,28 @@ func (s *PaymentService) HandleWebhook(ctx context.Context, payload map[string]i
 		}
 		chargeID, _ := data["id"].(string)
 		status, _ := data["status"].(string)
-
-		log.Printf("Processing webhook for charge %s with status %s", chargeID, status)
-
-		var payment models.Payment
-		err := s.db.Collection("payments").FindOne(ctx, bson.M{"charge_id": chargeID}).Decode(&payment)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				log.Printf("Payment not found for charge %s", chargeID)
-				return fmt.Errorf("payment not found for charge %s", chargeID)
-			}
-			log.Printf("Failed to fetch payment for charge %s: %v", chargeID, err)
-			return fmt.Errorf("failed to fetch payment for charge %s: %v", chargeID, err)
-		}
-		log.Printf("Payment found for charge %s: ID=%s", chargeID, payment.ID)
-
-		if status == "SUCCEEDED" {
-			// Update payment status to SUCCEEDED
-			_, err = s.db.Collection("payments").UpdateOne(ctx, bson.M{"charge_id": chargeID}, bson.M{
-				"$set": bson.M{
-					"status":     "SUCCEEDED",
-					"updated_at": time.Now(),
-				},
-			})
-			if err != nil {
-				log.Printf("Failed to update payment status to SUCCEEDED for charge %s: %v", chargeID, err)
-				return fmt.Errorf("failed to update payment status: %v", err)
-			}
-			log.Printf("Updated payment status to SUCCEEDED for charge %s", chargeID)
-
-			// Initiate disbursement
-			return s.CreateDisbursement(ctx, payment.ID)
-		} else {
-			// Log unexpected status but do not update to FAILED
-			log.Printf("Received unexpected status %s for charge %s, no action taken", status, chargeID)
-			return nil
+		return s.handleChargeWebhook(ctx, chargeID, status)
+	} else if eventType == "qr.payment" {
+		data, ok := payload["data"].(map[string]interface{})
+		if !ok {
+			log.Printf("Invalid webhook data for QR payment")
+			return fmt.Errorf("invalid webhook data")
 		}
+		qrID, _ := data["qr_id"].(string)
+		status, _ := data["status"].(string)
+		return s.handleChargeWebhook(ctx, qrID, status)
+	} else if eventType == "refund.succeeded" || eventType == "refund.failed" {
+		data, ok := payload["data"].(map[string]interface{})
+		if !ok {
+			log.Printf("Invalid webhook data for refund")
+			return fmt.Errorf("invalid webhook data")
+		}
+		refundID, _ := data["id"].(string)
+		referenceID, _ := data["reference_id"].(string)
+		status, _ := data["status"].(string)
+
+		log.Printf("Processing refund webhook: ID=%s, Reference=%s, Status=%s", refundID, referenceID, status)
+		return s.handleRefundWebhook(ctx, refundID, referenceID, status)
 	} else if eventType == "ph_disbursement.completed" {
 		data, ok := payload["data"].(map[string]interface{})
 		if !ok {
@@ -572,28 +725,199 @@ func (s *PaymentService) HandleWebhook(ctx context.Context, payload map[string]i
 		}
 		disID, _ := data["id"].(string)
 		status, _ := data["status"].(string)
-
-		// Ensure status is either PENDING or SUCCEEDED
-		if status != "PENDING" && status != "SUCCEEDED" {
-			log.Printf("Invalid disbursement status from Xendit: %s, defaulting to SUCCEEDED", status)
-			status = "SUCCEEDED"
+		// The event is named for completion; FAILED payouts carry their status
+		if status == "" {
+			status = "COMPLETED"
 		}
 
 		log.Printf("Processing disbursement webhook: ID=%s, Status=%s", disID, status)
+		_, err := s.applyDisbursementStatus(ctx, disID, status)
+		return err
+	}
+	log.Printf("Unhandled webhook event type: %s", eventType)
+	return nil
+}
+
+// handleChargeWebhook applies a charge status reported by a webhook to the
+// payment holding that charge, QR code or virtual account ID.
+func (s *PaymentService) handleChargeWebhook(ctx context.Context, chargeID, status string) error {
+	log.Printf("Processing webhook for charge %s with status %s", chargeID, status)
 
-		_, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"disbursement_id": disID}, bson.M{
+	var payment models.Payment
+	err := s.db.Collection("payments").FindOne(ctx, bson.M{"charge_id": chargeID}).Decode(&payment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Printf("Payment not found for charge %s", chargeID)
+			return fmt.Errorf("payment not found for charge %s", chargeID)
+		}
+		log.Printf("Failed to fetch payment for charge %s: %v", chargeID, err)
+		return fmt.Errorf("failed to fetch payment for charge %s: %v", chargeID, err)
+	}
+	log.Printf("Payment found for charge %s: ID=%s", chargeID, payment.ID)
+
+	_, err = s.applyChargeStatus(ctx, &payment, status)
+	return err
+}
+
+// applyChargeStatus moves a payment along its charge lifecycle given the status
+// Xendit reports for its charge. It is shared by HandleWebhook and Reconcile so
+// both paths make the same transitions. It reports whether the payment changed.
+func (s *PaymentService) applyChargeStatus(ctx context.Context, payment *models.Payment, status string) (bool, error) {
+	switch status {
+	case "SUCCEEDED":
+		// A payer can still complete a charge after we expired it; the money
+		// has moved, so the payment succeeds regardless.
+		if payment.Status != "PENDING" && payment.Status != "EXPIRED" {
+			log.Printf("Payment %s already has status %s, ignoring charge status %s", payment.ID, payment.Status, status)
+			return false, nil
+		}
+		now := time.Now()
+		result, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": payment.ID, "status": payment.Status}, bson.M{
 			"$set": bson.M{
-				"status":     status,
+				"status":     "SUCCEEDED",
+				"paid_at":    now,
+				"updated_at": now,
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to update payment status to SUCCEEDED for charge %s: %v", payment.ChargeID, err)
+			return false, fmt.Errorf("failed to update payment status: %v", err)
+		}
+		// A webhook retry, the reconciler or the expiry sweeper got here first
+		// and owns the side effects
+		if result.MatchedCount == 0 {
+			log.Printf("Payment %s changed status concurrently, ignoring charge status %s", payment.ID, status)
+			return false, nil
+		}
+		payment.Status = "SUCCEEDED"
+		payment.PaidAt = now
+		payment.UpdatedAt = now
+		log.Printf("Updated payment status to SUCCEEDED for charge %s", payment.ChargeID)
+		s.publishPayment(payment)
+		emitPaymentWebhook(ctx, s.db, models.EventPaymentSucceeded, payment)
+		recordLedger(ctx, s.db, chargeLedger(payment))
+		if payment.Fees != nil {
+			recordLedger(ctx, s.db, feeLedger(payment))
+		}
+
+		if payment.ObligationID != "" {
+			if err := s.settleObligation(ctx, payment); err != nil {
+				log.Printf("Failed to settle obligation %s for payment %s: %v", payment.ObligationID, payment.ID, err)
+			}
+		}
+		if mailEnabled() {
+			go s.EmailReceipt(context.Background(), payment.ID)
+		}
+
+		// Offline payments are already with the payee
+		if isOfflineMethod(payment.Method) {
+			return true, nil
+		}
+
+		// Payees on a daily or weekly schedule are paid in settlements
+		queued, err := s.queueForSettlement(ctx, payment)
+		if err != nil {
+			log.Printf("Failed to check payout schedule for payment %s: %v", payment.ID, err)
+		}
+		if queued {
+			return true, nil
+		}
+
+		// Initiate disbursement
+		return true, s.CreateDisbursement(ctx, payment.ID)
+	case "FAILED", "VOIDED":
+		if payment.Status != "PENDING" {
+			log.Printf("Payment %s already has status %s, ignoring charge status %s", payment.ID, payment.Status, status)
+			return false, nil
+		}
+		result, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": payment.ID, "status": "PENDING"}, bson.M{
+			"$set": bson.M{
+				"status":     "FAILED",
 				"updated_at": time.Now(),
 			},
 		})
 		if err != nil {
-			log.Printf("Failed to update payment status for disbursement %s: %v", disID, err)
-			return fmt.Errorf("failed to update payment status: %v", err)
+			log.Printf("Failed to update payment status to FAILED for charge %s: %v", payment.ChargeID, err)
+			return false, fmt.Errorf("failed to update payment status: %v", err)
 		}
-		log.Printf("Updated payment status for disbursement %s to %s", disID, status)
-		return nil
+		if result.MatchedCount == 0 {
+			log.Printf("Payment %s changed status concurrently, ignoring charge status %s", payment.ID, status)
+			return false, nil
+		}
+		payment.Status = "FAILED"
+		payment.UpdatedAt = time.Now()
+		log.Printf("Updated payment status to FAILED for charge %s", payment.ChargeID)
+		s.publishPayment(payment)
+		emitPaymentWebhook(ctx, s.db, models.EventPaymentFailed, payment)
+		return true, nil
+	default:
+		// Log unexpected status but leave the payment untouched
+		log.Printf("Received status %s for charge %s, no action taken", status, payment.ChargeID)
+		return false, nil
 	}
-	log.Printf("Unhandled webhook event type: %s", eventType)
-	return nil
+}
+
+// applyDisbursementStatus records the status Xendit reports for a disbursement
+// on the payments it paid out, and on their settlement when it was batched.
+// A failed settlement goes back to the queue for the next run; a failed
+// single payout is left for the reconciler to flag for manual review. It
+// reports whether a payment was updated.
+func (s *PaymentService) applyDisbursementStatus(ctx context.Context, disbursementID, status string) (bool, error) {
+	// Xendit reports finished disbursements as COMPLETED
+	if status == "COMPLETED" {
+		status = "SUCCEEDED"
+	}
+	switch status {
+	case "PENDING", "SUCCEEDED":
+	case "FAILED":
+		var settlement models.Settlement
+		err := s.db.Collection("settlements").FindOne(ctx, bson.M{"disbursement_id": disbursementID}).Decode(&settlement)
+		if err == nil {
+			return s.failSettlement(ctx, &settlement)
+		}
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Failed to fetch settlement for disbursement %s: %v", disbursementID, err)
+			return false, fmt.Errorf("failed to fetch settlement: %v", err)
+		}
+		log.Printf("Disbursement %s failed, leaving its payment for manual review", disbursementID)
+		return false, nil
+	default:
+		log.Printf("Unknown disbursement status from Xendit: %s, ignoring", status)
+		return false, fmt.Errorf("unknown disbursement status %q", status)
+	}
+
+	// Refunded payments keep their refund status, and repeats of the same
+	// status change nothing
+	result, err := s.db.Collection("payments").UpdateMany(ctx, bson.M{
+		"disbursement_id": disbursementID,
+		"status":          bson.M{"$in": []string{"PENDING", "SUCCEEDED"}, "$ne": status},
+	}, bson.M{
+		"$set": bson.M{
+			"status":     status,
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to update payment status for disbursement %s: %v", disbursementID, err)
+		return false, fmt.Errorf("failed to update payment status: %v", err)
+	}
+	_, err = s.db.Collection("settlements").UpdateOne(ctx, bson.M{"disbursement_id": disbursementID, "status": bson.M{"$ne": status}}, bson.M{
+		"$set": bson.M{
+			"status":     status,
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to update settlement status for disbursement %s: %v", disbursementID, err)
+	}
+	if result.ModifiedCount == 0 {
+		log.Printf("Payments of disbursement %s already have status %s", disbursementID, status)
+		return false, nil
+	}
+	log.Printf("Updated payment status for disbursement %s to %s", disbursementID, status)
+	s.publishPayments(ctx, bson.M{"disbursement_id": disbursementID})
+	if status == "SUCCEEDED" {
+		emitDisbursedWebhooks(ctx, s.db, bson.M{"disbursement_id": disbursementID})
+	}
+	return true, nil
 }
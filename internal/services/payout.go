@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// disbursementResult is a disbursement Xendit accepted.
+type disbursementResult struct {
+	ID      string
+	Status  string // "PENDING" or "SUCCEEDED"
+	Channel string // Payout channel the payee was paid on
+}
+
+// errPayoutDestination marks payees whose payout channel or account number
+// is missing or unsupported.
+var errPayoutDestination = errors.New("payee cannot receive payouts")
+
+// isPayoutRejection reports whether a sendDisbursement error means no payout
+// was made, as opposed to one whose outcome is unknown.
+func isPayoutRejection(err error) bool {
+	return errors.Is(err, errPayoutDestination) || isXenditRejection(err)
+}
+
+// sendDisbursement pays amount out to the payee's chosen channel (GCash by
+// default), retrying up to three times unless Xendit rejects it. referenceID must be unique per payout;
+// it doubles as the idempotency key, so retries and concurrent callers for the
+// same payout get back the one disbursement Xendit created.
+func sendDisbursement(ctx context.Context, payee *models.User, referenceID, description string, amount models.Money) (*disbursementResult, error) {
+	payoutChannel, accountNumber, err := payoutDestination(payee)
+	if err != nil {
+		log.Printf("Payee %s cannot receive payouts: %v", payee.ID.Hex(), err)
+		return nil, fmt.Errorf("%w: %v", errPayoutDestination, err)
+	}
+	log.Printf("Using %s account number for disbursement: %s", payoutChannel, accountNumber)
+
+	disReq := map[string]interface{}{
+		"reference_id":        referenceID,
+		"channel_code":        payoutChannel,
+		"account_number":      accountNumber,
+		"account_holder_name": payee.FullName,
+		"amount":              jsonAmount(amount),
+		"currency":            amount.Currency,
+		"description":         description,
+	}
+
+	var disResp struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	for attempt := 1; ; attempt++ {
+		err = xenditRequestWithHeaders(ctx, "POST", "/disbursements", map[string]string{"X-IDEMPOTENCY-KEY": referenceID}, disReq, &disResp)
+		if err == nil {
+			break
+		}
+		log.Printf("Disbursement %s failed (attempt %d): %v", referenceID, attempt, err)
+		if isXenditRejection(err) {
+			return nil, fmt.Errorf("disbursement rejected: %w", err)
+		}
+		if attempt == 3 {
+			return nil, fmt.Errorf("disbursement failed after retries: %w", err)
+		}
+		time.Sleep(time.Duration(attempt-1) * time.Second)
+	}
+
+	// Ensure status is either PENDING or SUCCEEDED
+	if disResp.Status != "PENDING" && disResp.Status != "SUCCEEDED" {
+		log.Printf("Invalid disbursement status from Xendit: %s, defaulting to SUCCEEDED", disResp.Status)
+		disResp.Status = "SUCCEEDED"
+	}
+	return &disbursementResult{ID: disResp.ID, Status: disResp.Status, Channel: payoutChannel}, nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PDF page size: US Letter in points.
+const (
+	pdfPageWidth  = 612.0
+	pdfPageHeight = 792.0
+	pdfMargin     = 54.0
+)
+
+// pdfDocument builds a simple text-and-rules PDF with the standard Helvetica
+// fonts, enough for receipts, invoices and statements without a PDF library.
+// Coordinates are in points from the top-left corner of the page.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+}
+
+func newPDF() *pdfDocument {
+	d := &pdfDocument{}
+	d.AddPage()
+	return d
+}
+
+// AddPage starts a new page; later drawing goes onto it.
+func (d *pdfDocument) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws s with its baseline at (x, y).
+func (d *pdfDocument) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, pdfPageHeight-y, pdfEscape(s))
+}
+
+// TextRight draws s ending at x, for right-aligned amounts.
+func (d *pdfDocument) TextRight(x, y, size float64, bold bool, s string) {
+	d.Text(x-pdfTextWidth(s, size), y, size, bold, s)
+}
+
+// Line draws a thin rule from (x1, y1) to (x2, y2).
+func (d *pdfDocument) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+// Bytes renders the document.
+func (d *pdfDocument) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-4 are the catalog, page tree and fonts; each page then takes
+	// two objects, the page and its content stream.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape encodes s for a PDF string in WinAnsi. Latin-1 letters such as
+// ñ are kept; characters the standard fonts cannot show become "?".
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfTextWidth approximates the width of s in Helvetica. Digits and common
+// punctuation are exact, which is what right-aligned amounts need.
+func pdfTextWidth(s string, size float64) float64 {
+	units := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			units += 556
+		case r == '.' || r == ',' || r == ' ':
+			units += 278
+		case r == '-':
+			units += 333
+		case r >= 'A' && r <= 'Z':
+			units += 667
+		default:
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// pdfWrap splits s into lines of at most width characters.
+func pdfWrap(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
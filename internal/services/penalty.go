@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// validatePenaltyRule checks a late fee rule against the amount each member owes.
+func validatePenaltyRule(rule *models.PenaltyRule, amount models.Money) error {
+	if rule == nil {
+		return nil
+	}
+	switch rule.Type {
+	case "FLAT":
+		if !rule.Amount.IsPositive() || rule.Amount.Currency != amount.Currency {
+			return fmt.Errorf("flat penalty must be a positive %s amount", amount.Currency)
+		}
+		rule.RateBps = 0
+	case "PERCENT":
+		if rule.RateBps <= 0 || rule.RateBps > 10000 {
+			return fmt.Errorf("penalty rate_bps must be between 1 and 10000")
+		}
+		rule.Amount = models.Money{}
+	default:
+		return fmt.Errorf("invalid penalty type %q, must be FLAT or PERCENT", rule.Type)
+	}
+	switch rule.Recurrence {
+	case "":
+		rule.Recurrence = "ONCE"
+	case "ONCE", "DAILY", "WEEKLY", "MONTHLY":
+	default:
+		return fmt.Errorf("invalid penalty recurrence %q", rule.Recurrence)
+	}
+	if rule.GraceDays < 0 || rule.MaxPeriods < 0 {
+		return fmt.Errorf("grace_days and max_periods cannot be negative")
+	}
+	return nil
+}
+
+// validateEarlyDiscount checks an early-payment discount, which must leave
+// something to charge.
+func validateEarlyDiscount(discount *models.EarlyDiscount, amount models.Money) error {
+	if discount == nil {
+		return nil
+	}
+	switch discount.Type {
+	case "FLAT":
+		if !discount.Amount.IsPositive() || discount.Amount.Currency != amount.Currency {
+			return fmt.Errorf("flat discount must be a positive %s amount", amount.Currency)
+		}
+		if discount.Amount.Centavos >= amount.Centavos {
+			return fmt.Errorf("discount must be less than the amount owed")
+		}
+		discount.RateBps = 0
+	case "PERCENT":
+		if discount.RateBps <= 0 || discount.RateBps >= 10000 {
+			return fmt.Errorf("discount rate_bps must be between 1 and 9999")
+		}
+		discount.Amount = models.Money{}
+	default:
+		return fmt.Errorf("invalid discount type %q, must be FLAT or PERCENT", discount.Type)
+	}
+	if discount.BeforeDays < 0 {
+		return fmt.Errorf("before_days cannot be negative")
+	}
+	return nil
+}
+
+// applyRate returns bps basis points of amount, rounded to the nearest centavo.
+func applyRate(amount models.Money, bps int64) models.Money {
+	return models.NewMoney((amount.Centavos*bps+5000)/10000, amount.Currency)
+}
+
+// penaltyPeriods counts the penalty periods that have started by now.
+// Monthly periods follow the Manila calendar and end on the last day of
+// shorter months rather than spilling into the next.
+func penaltyPeriods(rule *models.PenaltyRule, dueDate, now time.Time) int {
+	start := dueDate.In(manila).AddDate(0, 0, rule.GraceDays)
+	if !now.After(start) {
+		return 0
+	}
+	var periods int
+	switch rule.Recurrence {
+	case "DAILY":
+		periods = 1 + int(now.Sub(start)/(24*time.Hour))
+	case "WEEKLY":
+		periods = 1 + int(now.Sub(start)/(7*24*time.Hour))
+	case "MONTHLY":
+		periods = 1
+		for !addMonths(start, periods).After(now) {
+			periods++
+		}
+	default:
+		periods = 1
+	}
+	if rule.MaxPeriods > 0 && periods > rule.MaxPeriods {
+		periods = rule.MaxPeriods
+	}
+	return periods
+}
+
+// accruedPenalty is the total late fee an obligation has earned by now.
+func accruedPenalty(rule *models.PenaltyRule, o *models.Obligation, now time.Time) models.Money {
+	if rule == nil || o.DueDate.IsZero() {
+		return models.NewMoney(0, o.Amount.Currency)
+	}
+	perPeriod := rule.Amount
+	if rule.Type == "PERCENT" {
+		perPeriod = applyRate(o.Amount, rule.RateBps)
+	}
+	periods := penaltyPeriods(rule, o.DueDate, now)
+	return models.NewMoney(perPeriod.Centavos*int64(periods), o.Amount.Currency)
+}
+
+// earlyDiscount is the discount for settling an obligation in full by now, or
+// zero once the discount window has closed. A flat discount that would wipe out
+// a prorated obligation is not applied.
+func earlyDiscount(discount *models.EarlyDiscount, o *models.Obligation, now time.Time) models.Money {
+	none := models.NewMoney(0, o.Amount.Currency)
+	if discount == nil || o.DueDate.IsZero() || now.After(o.DueDate.AddDate(0, 0, -discount.BeforeDays)) {
+		return none
+	}
+	amount := discount.Amount
+	if discount.Type == "PERCENT" {
+		amount = applyRate(o.Amount, discount.RateBps)
+	}
+	if amount.Centavos >= o.Amount.Centavos {
+		return none
+	}
+	return amount
+}
+
+// obligationRules loads the penalty and discount rules of the collection an
+// obligation belongs to.
+func (s *PaymentService) obligationRules(ctx context.Context, o *models.Obligation) (*models.PenaltyRule, *models.EarlyDiscount, error) {
+	objID, err := primitive.ObjectIDFromHex(o.CollectionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid collection_id format: %v", err)
+	}
+	var collection models.Collection
+	if err := s.db.Collection("collections").FindOne(ctx, bson.M{"_id": objID}).Decode(&collection); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, fmt.Errorf("collection not found")
+		}
+		return nil, nil, fmt.Errorf("failed to fetch collection: %v", err)
+	}
+	return collection.Penalty, collection.Discount, nil
+}
+
+// SetFeeRules replaces the late fee and early-payment discount rules of a
+// collection. Nil clears a rule. Fees already charged are not affected.
+func (s *CollectionService) SetFeeRules(ctx context.Context, id string, penalty *models.PenaltyRule, discount *models.EarlyDiscount) (*models.Collection, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	collection, err := s.GetCollection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePenaltyRule(penalty, collection.Amount); err != nil {
+		return nil, err
+	}
+	if err := validateEarlyDiscount(discount, collection.Amount); err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	if penalty != nil {
+		set["penalty"] = penalty
+	} else {
+		unset["penalty"] = ""
+	}
+	if discount != nil {
+		set["discount"] = discount
+	} else {
+		unset["discount"] = ""
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if _, err := s.db.Collection("collections").UpdateOne(ctx, bson.M{"_id": collection.ID}, update); err != nil {
+		return nil, fmt.Errorf("failed to update collection: %v", err)
+	}
+	collection.Penalty = penalty
+	collection.Discount = discount
+	return collection, nil
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// PlanService manages recurring billing plans and generates a collection for
+// each of their cycles.
+type PlanService struct {
+	db          *mongo.Database
+	collections *CollectionService
+}
+
+// NewPlanService initializes a new PlanService
+func NewPlanService(db *mongo.Database, collections *CollectionService) *PlanService {
+	_, err := db.Collection("billing_plans").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_run_at", Value: 1}},
+	})
+	if err != nil {
+		log.Fatalf("error creating index for billing plans: %v", err)
+	}
+	return &PlanService{db: db, collections: collections}
+}
+
+// planFrequencies maps fixed frequencies to their cycle length in months.
+var planFrequencies = map[string]int{
+	"MONTHLY":   1,
+	"QUARTERLY": 3,
+	"SEMESTRAL": 6,
+}
+
+// nextCycleStart returns the start of the first cycle strictly after t.
+// Fixed frequencies are anchored on the plan's start date. Cycles follow the
+// Manila calendar whatever zone the times come in, since Mongo returns UTC.
+func nextCycleStart(plan *models.BillingPlan, t time.Time) (time.Time, error) {
+	t = t.In(manila)
+	if plan.Frequency == "CUSTOM" {
+		schedule, err := parseCron(plan.Schedule)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next := schedule.Next(t)
+		if next.IsZero() {
+			return time.Time{}, fmt.Errorf("schedule %q never runs", plan.Schedule)
+		}
+		return next, nil
+	}
+	months, ok := planFrequencies[plan.Frequency]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unsupported frequency %q, must be MONTHLY, QUARTERLY, SEMESTRAL or CUSTOM", plan.Frequency)
+	}
+	start := plan.StartDate.In(manila)
+	if t.Before(start) {
+		return start, nil
+	}
+	next := start
+	for i := 1; !next.After(t); i++ {
+		next = addMonths(start, i*months)
+	}
+	return next, nil
+}
+
+// addMonths adds n months to t, clamping to the last day of the target month
+// rather than overflowing into the next, so a plan starting Jan 31 bills on
+// Feb 28 (or 29), Mar 31, Apr 30 and so on.
+func addMonths(t time.Time, n int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// CreatePlan validates and saves a billing plan. Its first cycle starts at
+// StartDate (or the first scheduled time after it, for CUSTOM schedules).
+func (s *PlanService) CreatePlan(ctx context.Context, plan *models.BillingPlan, memberIDs []string) (*models.BillingPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	plan.Title = strings.TrimSpace(plan.Title)
+	plan.Frequency = strings.ToUpper(strings.TrimSpace(plan.Frequency))
+	if plan.Title == "" {
+		return nil, fmt.Errorf("title cannot be empty")
+	}
+	if plan.Amount.Currency != "PHP" || !plan.Amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be a positive PHP amount")
+	}
+	if plan.DueAfterDays < 0 {
+		return nil, fmt.Errorf("due_after_days cannot be negative")
+	}
+	if err := validatePenaltyRule(plan.Penalty, plan.Amount); err != nil {
+		return nil, err
+	}
+	if err := validateEarlyDiscount(plan.Discount, plan.Amount); err != nil {
+		return nil, err
+	}
+	if plan.StartDate.IsZero() {
+		plan.StartDate = time.Now()
+	}
+	if !plan.EndDate.IsZero() && !plan.EndDate.After(plan.StartDate) {
+		return nil, fmt.Errorf("end_date must be after start_date")
+	}
+	if plan.PayeeID == "" {
+		plan.PayeeID = plan.CreatedBy
+	}
+	if _, err := primitive.ObjectIDFromHex(plan.PayeeID); err != nil {
+		return nil, fmt.Errorf("invalid payee_id format: %v", err)
+	}
+
+	first := plan.StartDate
+	if plan.Frequency == "CUSTOM" {
+		var err error
+		if first, err = nextCycleStart(plan, plan.StartDate.Add(-time.Minute)); err != nil {
+			return nil, err
+		}
+	} else if _, err := nextCycleStart(plan, plan.StartDate); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	plan.Enrollments = []models.PlanEnrollment{}
+	if !plan.AllMembers {
+		ids, err := s.collections.resolveMembers(ctx, false, memberIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			plan.Enrollments = append(plan.Enrollments, models.PlanEnrollment{MemberID: id, EnrolledAt: now})
+		}
+	}
+
+	plan.ID = primitive.NewObjectID()
+	plan.Status = "ACTIVE"
+	plan.NextRunAt = first
+	plan.CreatedAt = now
+	plan.UpdatedAt = now
+	if _, err := s.db.Collection("billing_plans").InsertOne(ctx, plan); err != nil {
+		log.Printf("Failed to save billing plan: %v", err)
+		return nil, fmt.Errorf("failed to save billing plan: %v", err)
+	}
+	log.Printf("Billing plan created: ID=%s, Title=%s, Frequency=%s, FirstRun=%s", plan.ID.Hex(), plan.Title, plan.Frequency, first.Format(time.RFC3339))
+	return plan, nil
+}
+
+// GetPlans retrieves all billing plans, newest first.
+func (s *PlanService) GetPlans(ctx context.Context) ([]models.BillingPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("billing_plans").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch billing plans: %v", err)
+	}
+	plans := []models.BillingPlan{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &plans); err != nil {
+		return nil, fmt.Errorf("failed to decode billing plans: %v", err)
+	}
+	return plans, nil
+}
+
+// GetPlan retrieves a billing plan by its ID.
+func (s *PlanService) GetPlan(ctx context.Context, id string) (*models.BillingPlan, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plan_id format: %v", err)
+	}
+	var plan models.BillingPlan
+	if err := s.db.Collection("billing_plans").FindOne(ctx, bson.M{"_id": objID}).Decode(&plan); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("billing plan not found")
+		}
+		return nil, fmt.Errorf("failed to fetch billing plan: %v", err)
+	}
+	return &plan, nil
+}
+
+// SetPlanStatus pauses ("PAUSED"), resumes ("ACTIVE") or ends ("ENDED") a plan.
+// Cycles missed while paused are skipped, not back-billed. Ended plans stay ended.
+func (s *PlanService) SetPlanStatus(ctx context.Context, id, status string) (*models.BillingPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	plan, err := s.GetPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status == "ENDED" {
+		return nil, fmt.Errorf("billing plan has ended")
+	}
+
+	set := bson.M{"status": status, "updated_at": time.Now()}
+	switch status {
+	case "PAUSED", "ENDED":
+	case "ACTIVE":
+		if plan.Status == "PAUSED" && plan.NextRunAt.Before(time.Now()) {
+			next, err := nextCycleStart(plan, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			set["next_run_at"] = next
+		}
+	default:
+		return nil, fmt.Errorf("invalid status %q, must be ACTIVE, PAUSED or ENDED", status)
+	}
+
+	if _, err := s.db.Collection("billing_plans").UpdateOne(ctx, bson.M{"_id": plan.ID}, bson.M{"$set": set}); err != nil {
+		return nil, fmt.Errorf("failed to update billing plan: %v", err)
+	}
+	log.Printf("Billing plan %s is now %s", id, status)
+	return s.GetPlan(ctx, id)
+}
+
+// EnrollMembers adds members to a plan. If a cycle is under way they are
+// issued an obligation for it straight away, prorated when the plan prorates.
+func (s *PlanService) EnrollMembers(ctx context.Context, id string, memberIDs []string) (*models.BillingPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	plan, err := s.GetPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status == "ENDED" {
+		return nil, fmt.Errorf("billing plan has ended")
+	}
+	if plan.AllMembers {
+		return nil, fmt.Errorf("billing plan already covers all members")
+	}
+	ids, err := s.collections.resolveMembers(ctx, false, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	enrolled := make(map[string]bool, len(plan.Enrollments))
+	for _, e := range plan.Enrollments {
+		enrolled[e.MemberID] = true
+	}
+	now := time.Now()
+	var added []models.PlanEnrollment
+	for _, memberID := range ids {
+		if !enrolled[memberID] {
+			added = append(added, models.PlanEnrollment{MemberID: memberID, EnrolledAt: now})
+		}
+	}
+	if len(added) == 0 {
+		return plan, nil
+	}
+
+	_, err = s.db.Collection("billing_plans").UpdateOne(ctx, bson.M{"_id": plan.ID}, bson.M{
+		"$push": bson.M{"enrollments": bson.M{"$each": added}},
+		"$set":  bson.M{"updated_at": now},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to enroll members: %v", err)
+	}
+
+	// Bill the new members for the cycle in progress
+	if plan.Status == "ACTIVE" && !plan.LastRunAt.IsZero() {
+		var current models.Collection
+		err := s.db.Collection("collections").FindOne(ctx, bson.M{"plan_id": id, "period_start": plan.LastRunAt}).Decode(&current)
+		if err == nil && now.Before(current.PeriodEnd) {
+			for _, e := range added {
+				amount := cycleAmount(plan, &current, e.EnrolledAt)
+				if err := s.collections.issueObligation(ctx, &current, e.MemberID, amount); err != nil {
+					log.Printf("Failed to bill member %s for current cycle of plan %s: %v", e.MemberID, id, err)
+				}
+			}
+		} else if err != nil && err != mongo.ErrNoDocuments {
+			log.Printf("Failed to fetch current cycle of plan %s: %v", id, err)
+		}
+	}
+
+	log.Printf("Enrolled %d members in billing plan %s", len(added), id)
+	return s.GetPlan(ctx, id)
+}
+
+// cycleAmount is what a member who enrolled at enrolledAt owes for a cycle:
+// the full amount, or the share of days left in the cycle when prorating.
+func cycleAmount(plan *models.BillingPlan, cycle *models.Collection, enrolledAt time.Time) models.Money {
+	if !plan.Prorate || !enrolledAt.After(cycle.PeriodStart) {
+		return plan.Amount
+	}
+	total := cycle.PeriodEnd.Sub(cycle.PeriodStart).Hours() / 24
+	remaining := cycle.PeriodEnd.Sub(enrolledAt).Hours() / 24
+	if total <= 0 || remaining <= 0 {
+		return models.NewMoney(0, plan.Amount.Currency)
+	}
+	// Whole days only, rounding in the member's favour
+	totalDays := int64(math.Round(total))
+	days := min(int64(remaining), totalDays)
+	if totalDays <= 0 {
+		return plan.Amount
+	}
+	return models.NewMoney(plan.Amount.Centavos*days/totalDays, plan.Amount.Currency)
+}
+
+// RunDuePlans generates the collection for every active plan whose next cycle
+// has started. It returns the number of cycles generated.
+func (s *PlanService) RunDuePlans(ctx context.Context) (int, error) {
+	findCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	cur, err := s.db.Collection("billing_plans").Find(findCtx, bson.M{"status": "ACTIVE", "next_run_at": bson.M{"$lte": now}})
+	if err != nil {
+		log.Printf("Failed to fetch due billing plans: %v", err)
+		return 0, fmt.Errorf("failed to fetch due billing plans: %v", err)
+	}
+	var plans []models.BillingPlan
+	if err := cur.All(findCtx, &plans); err != nil {
+		return 0, fmt.Errorf("failed to decode due billing plans: %v", err)
+	}
+
+	generated := 0
+	for i := range plans {
+		n, err := s.runPlan(ctx, &plans[i], now)
+		generated += n
+		if err != nil {
+			log.Printf("Failed to run billing plan %s: %v", plans[i].ID.Hex(), err)
+		}
+	}
+
+	if err := s.billNewMembers(ctx); err != nil {
+		log.Printf("Failed to bill new members for current cycles: %v", err)
+	}
+	return generated, nil
+}
+
+// billNewMembers issues current-cycle obligations to members who signed up
+// after an all-members plan's cycle had already been generated.
+func (s *PlanService) billNewMembers(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("billing_plans").Find(ctx, bson.M{"status": "ACTIVE", "all_members": true, "last_run_at": bson.M{"$exists": true}})
+	if err != nil {
+		return fmt.Errorf("failed to fetch billing plans: %v", err)
+	}
+	var plans []models.BillingPlan
+	if err := cur.All(ctx, &plans); err != nil {
+		return fmt.Errorf("failed to decode billing plans: %v", err)
+	}
+
+	now := time.Now()
+	for i := range plans {
+		plan := &plans[i]
+		var current models.Collection
+		if err := s.db.Collection("collections").FindOne(ctx, bson.M{"plan_id": plan.ID.Hex(), "period_start": plan.LastRunAt}).Decode(&current); err != nil {
+			continue
+		}
+		if !now.Before(current.PeriodEnd) {
+			continue
+		}
+		billed := make(map[string]bool, len(current.TargetMemberIDs))
+		for _, id := range current.TargetMemberIDs {
+			billed[id] = true
+		}
+		enrollments, err := s.memberEnrollments(ctx, current.PeriodStart)
+		if err != nil {
+			return err
+		}
+		for _, e := range enrollments {
+			if billed[e.MemberID] || !e.EnrolledAt.After(current.PeriodStart) {
+				continue
+			}
+			if amount := cycleAmount(plan, &current, e.EnrolledAt); amount.IsPositive() {
+				if err := s.collections.issueObligation(ctx, &current, e.MemberID, amount); err != nil {
+					log.Printf("Failed to bill member %s for current cycle of plan %s: %v", e.MemberID, plan.ID.Hex(), err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// runPlan generates every cycle of a plan that has started by now.
+func (s *PlanService) runPlan(ctx context.Context, plan *models.BillingPlan, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	generated := 0
+	for !plan.NextRunAt.After(now) {
+		start := plan.NextRunAt
+		if !plan.EndDate.IsZero() && !start.Before(plan.EndDate) {
+			_, err := s.db.Collection("billing_plans").UpdateOne(ctx, bson.M{"_id": plan.ID}, bson.M{
+				"$set": bson.M{"status": "ENDED", "updated_at": now},
+			})
+			log.Printf("Billing plan %s reached its end date", plan.ID.Hex())
+			return generated, err
+		}
+		end, err := nextCycleStart(plan, start)
+		if err != nil {
+			return generated, err
+		}
+
+		// Claim the cycle first so two runners never bill it twice
+		result, err := s.db.Collection("billing_plans").UpdateOne(ctx, bson.M{"_id": plan.ID, "next_run_at": start}, bson.M{
+			"$set": bson.M{"next_run_at": end, "last_run_at": start, "updated_at": now},
+		})
+		if err != nil {
+			return generated, fmt.Errorf("failed to advance billing plan: %v", err)
+		}
+		if result.ModifiedCount == 0 {
+			return generated, nil
+		}
+		plan.NextRunAt = end
+		plan.LastRunAt = start
+
+		if err := s.generateCycle(ctx, plan, start, end); err != nil {
+			return generated, err
+		}
+		generated++
+	}
+	return generated, nil
+}
+
+// generateCycle issues the collection for one cycle of a plan.
+func (s *PlanService) generateCycle(ctx context.Context, plan *models.BillingPlan, start, end time.Time) error {
+	collection := &models.Collection{
+		Title:       fmt.Sprintf("%s (%s)", plan.Title, start.Format("Jan 2, 2006")),
+		Description: plan.Description,
+		Amount:      plan.Amount,
+		DueDate:     start.AddDate(0, 0, plan.DueAfterDays),
+		PayeeID:     plan.PayeeID,
+		AllMembers:  plan.AllMembers,
+		Penalty:     plan.Penalty,
+		Discount:    plan.Discount,
+		PlanID:      plan.ID.Hex(),
+		PeriodStart: start,
+		PeriodEnd:   end,
+		CreatedBy:   plan.CreatedBy,
+	}
+
+	enrollments := plan.Enrollments
+	if plan.AllMembers {
+		var err error
+		if enrollments, err = s.memberEnrollments(ctx, plan.StartDate); err != nil {
+			return err
+		}
+	}
+
+	amounts := make(map[string]models.Money, len(enrollments))
+	for _, e := range enrollments {
+		if !e.EnrolledAt.Before(end) {
+			continue // joined after this cycle
+		}
+		if amount := cycleAmount(plan, collection, e.EnrolledAt); amount.IsPositive() {
+			amounts[e.MemberID] = amount
+		}
+	}
+	if len(amounts) == 0 {
+		log.Printf("Billing plan %s has no members to bill for cycle starting %s", plan.ID.Hex(), start.Format(time.RFC3339))
+		return nil
+	}
+
+	if _, err := s.collections.issueCollection(ctx, collection, amounts); err != nil {
+		return err
+	}
+	log.Printf("Billing plan %s generated collection %s for %d members", plan.ID.Hex(), collection.ID.Hex(), len(amounts))
+	return nil
+}
+
+// memberEnrollments treats every member with role "user" as enrolled since
+// they joined, or since the plan started if they joined earlier.
+func (s *PlanService) memberEnrollments(ctx context.Context, since time.Time) ([]models.PlanEnrollment, error) {
+	cur, err := s.db.Collection("user").Find(ctx, bson.M{"role": "user"}, options.Find().SetProjection(bson.M{"_id": 1, "created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch members: %v", err)
+	}
+	var users []models.User
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("failed to decode members: %v", err)
+	}
+
+	enrollments := make([]models.PlanEnrollment, 0, len(users))
+	for _, u := range users {
+		enrolledAt := u.CreatedAt
+		if enrolledAt.Before(since) {
+			enrolledAt = since
+		}
+		enrollments = append(enrollments, models.PlanEnrollment{MemberID: u.ID.Hex(), EnrolledAt: enrolledAt})
+	}
+	return enrollments, nil
+}
+
+// StartPlanScheduler runs RunDuePlans every interval until ctx is cancelled.
+func (s *PlanService) StartPlanScheduler(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting billing plan scheduler: interval=%s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Billing plan scheduler stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.RunDuePlans(ctx); err != nil {
+				log.Printf("Scheduled billing plan run failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// organization is the issuer printed on receipts and invoices, read from
+// ORG_NAME, ORG_ADDRESS, ORG_CONTACT and ORG_TIN.
+type organization struct {
+	Name, Address, Contact, TIN string
+}
+
+func loadOrganization() organization {
+	org := organization{
+		Name:    os.Getenv("ORG_NAME"),
+		Address: os.Getenv("ORG_ADDRESS"),
+		Contact: os.Getenv("ORG_CONTACT"),
+		TIN:     os.Getenv("ORG_TIN"),
+	}
+	if org.Name == "" {
+		org.Name = "NotiPay"
+	}
+	return org
+}
+
+// drawOrganization prints the issuer block at the top of a page and returns
+// the y position below it.
+func drawOrganization(pdf *pdfDocument, org organization) float64 {
+	y := pdfMargin + 10
+	pdf.Text(pdfMargin, y, 18, true, org.Name)
+	for _, line := range []string{org.Address, org.Contact} {
+		if line != "" {
+			y += 14
+			pdf.Text(pdfMargin, y, 9, false, line)
+		}
+	}
+	if org.TIN != "" {
+		y += 14
+		pdf.Text(pdfMargin, y, 9, false, "TIN: "+org.TIN)
+	}
+	y += 12
+	pdf.Line(pdfMargin, y, pdfPageWidth-pdfMargin, y)
+	return y + 28
+}
+
+// chargeSucceeded reports whether the payer's money was received. After a
+// disbursement the status follows the payout, so that counts as paid too.
+// REFUNDED and PARTIALLY_REFUNDED statuses were written by earlier versions,
+// before refund state moved to refund_status.
+func chargeSucceeded(p *models.Payment) bool {
+	switch p.Status {
+	case "SUCCEEDED", "REFUNDED", "PARTIALLY_REFUNDED":
+		return true
+	}
+	return p.DisbursementID != ""
+}
+
+// chargeSucceededFilter matches the payments chargeSucceeded accepts.
+func chargeSucceededFilter() bson.M {
+	return bson.M{"$or": []bson.M{
+		{"status": bson.M{"$in": []string{"SUCCEEDED", "REFUNDED", "PARTIALLY_REFUNDED"}}},
+		{"disbursement_id": bson.M{"$nin": bson.A{nil, ""}}},
+	}}
+}
+
+// paymentPaidAt is when a paid payment's money was received: the review time
+// for offline payments, otherwise paid_at, falling back to the last update
+// for payments that predate it.
+func paymentPaidAt(p *models.Payment) time.Time {
+	if p.Review != nil {
+		return p.Review.ReviewedAt
+	}
+	if !p.PaidAt.IsZero() {
+		return p.PaidAt
+	}
+	return p.UpdatedAt
+}
+
+// ensureReceiptNumber gives a paid payment its official receipt number the
+// first time a receipt is issued, so numbers follow issue order. Numbers are
+// taken as the last one plus one and the unique index on receipt_seq turns
+// away a concurrent taker, so a lost race leaves no gap in the series.
+func (s *PaymentService) ensureReceiptNumber(ctx context.Context, payment *models.Payment) error {
+	if payment.ReceiptNumber != "" {
+		return nil
+	}
+	for attempt := 0; attempt < 5; attempt++ {
+		last, err := s.lastReceiptSeq(ctx)
+		if err != nil {
+			return err
+		}
+		seq := last + 1
+		number := fmt.Sprintf("OR-%06d", seq)
+		result, err := s.db.Collection("payments").UpdateOne(ctx,
+			bson.M{"_id": payment.ID, "receipt_number": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"receipt_seq": seq, "receipt_number": number}},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			continue // another receipt took this number
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save receipt number: %v", err)
+		}
+		if result.ModifiedCount == 0 {
+			// Issued concurrently; use the number that won
+			latest, err := s.GetPaymentByID(ctx, payment.ID)
+			if err != nil {
+				return err
+			}
+			payment.ReceiptSeq = latest.ReceiptSeq
+			payment.ReceiptNumber = latest.ReceiptNumber
+			return nil
+		}
+		payment.ReceiptSeq = seq
+		payment.ReceiptNumber = number
+		return nil
+	}
+	return fmt.Errorf("failed to take receipt number: too many concurrent issues, please retry")
+}
+
+// lastReceiptSeq returns the highest receipt number issued so far. Receipts
+// issued before receipt_seq was stored took theirs from the "receipt"
+// counter, so that counter still sets the floor.
+func (s *PaymentService) lastReceiptSeq(ctx context.Context) (int64, error) {
+	var last models.Payment
+	err := s.db.Collection("payments").FindOne(ctx,
+		bson.M{"receipt_seq": bson.M{"$exists": true}},
+		options.FindOne().SetSort(bson.M{"receipt_seq": -1}).SetProjection(bson.M{"receipt_seq": 1}),
+	).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, fmt.Errorf("failed to read last receipt number: %v", err)
+	}
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err = s.db.Collection("counters").FindOne(ctx, bson.M{"_id": "receipt"}).Decode(&counter)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, fmt.Errorf("failed to read receipt counter: %v", err)
+	}
+	return max(last.ReceiptSeq, counter.Seq), nil
+}
+
+// GetReceipt renders the official receipt of a paid payment as a PDF. Only
+// the payer, the payee or an admin may fetch it.
+func (s *PaymentService) GetReceipt(ctx context.Context, paymentID, userID string, isAdmin bool) (*models.Payment, []byte, error) {
+	payment, err := s.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payment.PayerID != userID && payment.PayeeID != userID && !isAdmin {
+		return nil, nil, fmt.Errorf("payment does not belong to this user")
+	}
+	pdf, err := s.renderReceipt(ctx, payment)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payment, pdf, nil
+}
+
+// renderReceipt numbers the receipt if needed and draws it.
+func (s *PaymentService) renderReceipt(ctx context.Context, payment *models.Payment) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if !chargeSucceeded(payment) {
+		return nil, fmt.Errorf("receipts are only issued for completed payments")
+	}
+	if err := s.ensureReceiptNumber(ctx, payment); err != nil {
+		return nil, err
+	}
+	payer, _ := lookupUser(ctx, s.db, payment.PayerID)
+	payee, _ := lookupUser(ctx, s.db, payment.PayeeID)
+
+	paidAt := paymentPaidAt(payment)
+
+	pdf := newPDF()
+	y := drawOrganization(pdf, loadOrganization())
+	pdf.Text(pdfMargin, y, 14, true, "OFFICIAL RECEIPT")
+	pdf.TextRight(pdfPageWidth-pdfMargin, y, 11, true, "No. "+payment.ReceiptNumber)
+	y += 16
+	pdf.TextRight(pdfPageWidth-pdfMargin, y, 9, false, "Date: "+paidAt.In(manila).Format("January 2, 2006 3:04 PM"))
+	y += 28
+
+	field := func(label, value string) {
+		if value == "" {
+			return
+		}
+		pdf.Text(pdfMargin, y, 10, true, label)
+		pdf.Text(pdfMargin+120, y, 10, false, value)
+		y += 16
+	}
+	if payer != nil {
+		field("Received from", payer.FullName)
+		field("Email", payer.Email)
+	}
+	if payee != nil {
+		field("Received by", payee.FullName)
+	}
+	field("For", payment.Title)
+	if payment.Description != payment.Title {
+		for i, line := range pdfWrap(payment.Description, 70) {
+			label := ""
+			if i == 0 {
+				label = "Details"
+			}
+			field(label, line)
+		}
+	}
+	method := payment.Method
+	if method == "" {
+		method = models.MethodEWallet
+	}
+	field("Method", method)
+	field("Channel", payment.ChannelCode)
+	field("Charge reference", payment.ChargeID)
+	field("Reference", payment.ReferenceID)
+
+	y += 12
+	pdf.Line(pdfMargin, y, pdfPageWidth-pdfMargin, y)
+	y += 20
+	amountRow := func(label string, m models.Money, bold bool) {
+		pdf.Text(pdfMargin, y, 10, bold, label)
+		pdf.TextRight(pdfPageWidth-pdfMargin, y, 10, bold, m.Currency+" "+m.String())
+		y += 16
+	}
+	if b := payment.Breakdown; b != nil {
+		amountRow("Amount due", b.Base, false)
+		if b.Penalty.IsPositive() {
+			amountRow("Late fees", b.Penalty, false)
+		}
+		if b.Discount.IsPositive() {
+			amountRow("Early payment discount", models.NewMoney(-b.Discount.Centavos, b.Discount.Currency), false)
+		}
+	}
+	if payment.Fees != nil && payment.Fees.ConvenienceFee.IsPositive() {
+		amountRow("Convenience fee", payment.Fees.ConvenienceFee, false)
+	}
+	amountRow("Total paid", payment.Amount, true)
+	if payment.RefundedAmount.IsPositive() {
+		amountRow("Refunded", models.NewMoney(-payment.RefundedAmount.Centavos, payment.RefundedAmount.Currency), false)
+	}
+
+	y += 40
+	pdf.Text(pdfMargin, y, 8, false, "This receipt was generated electronically and is valid without a signature.")
+	return pdf.Bytes(), nil
+}
+
+// lookupUser fetches a user by hex ID.
+func lookupUser(ctx context.Context, db *mongo.Database, id string) (*models.User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user_id format: %v", err)
+	}
+	var user models.User
+	if err := db.Collection("user").FindOne(ctx, bson.M{"_id": objID}).Decode(&user); err != nil {
+		return nil, fmt.Errorf("failed to fetch user %s: %v", id, err)
+	}
+	return &user, nil
+}
+
+// EmailReceipt sends the payer their receipt once. It is started in the
+// background when a payment succeeds, so failures are only logged.
+func (s *PaymentService) EmailReceipt(ctx context.Context, paymentID string) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	// Claim the send so webhook retries do not email twice
+	now := time.Now()
+	result, err := s.db.Collection("payments").UpdateOne(ctx,
+		bson.M{"_id": paymentID, "receipt_sent_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"receipt_sent_at": now}},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		if err != nil {
+			log.Printf("Failed to claim receipt email for payment %s: %v", paymentID, err)
+		}
+		return
+	}
+	unclaim := func() {
+		if _, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": paymentID}, bson.M{"$unset": bson.M{"receipt_sent_at": ""}}); err != nil {
+			log.Printf("Failed to release receipt email claim for payment %s: %v", paymentID, err)
+		}
+	}
+
+	payment, err := s.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		unclaim()
+		return
+	}
+	payer, err := lookupUser(ctx, s.db, payment.PayerID)
+	if err != nil || payer.Email == "" {
+		log.Printf("No email address for payer %s of payment %s", payment.PayerID, paymentID)
+		unclaim()
+		return
+	}
+	pdf, err := s.renderReceipt(ctx, payment)
+	if err != nil {
+		log.Printf("Failed to render receipt for payment %s: %v", paymentID, err)
+		unclaim()
+		return
+	}
+
+	org := loadOrganization()
+	body := fmt.Sprintf("Hi %s,\n\nThank you for your payment of %s %s for %s.\nYour official receipt %s is attached.\n\n%s\n",
+		payer.FullName, payment.Amount.Currency, payment.Amount.String(), payment.Title, payment.ReceiptNumber, org.Name)
+	err = sendMail(payer.Email, fmt.Sprintf("%s receipt %s", org.Name, payment.ReceiptNumber), body, mailAttachment{
+		Name:        payment.ReceiptNumber + ".pdf",
+		ContentType: "application/pdf",
+		Data:        pdf,
+	})
+	if err != nil {
+		log.Printf("Failed to email receipt for payment %s: %v", paymentID, err)
+		unclaim()
+		return
+	}
+	log.Printf("Receipt %s emailed to %s for payment %s", payment.ReceiptNumber, payer.Email, paymentID)
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// Reconcile compares every non-terminal payment created more than minAge ago
+// with its charge or disbursement at Xendit, fixes status drift through the
+// same transitions the webhook uses, resubmits refunds Xendit never
+// acknowledged and stores a report of the mismatches.
+func (s *PaymentService) Reconcile(ctx context.Context, minAge time.Duration) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		StartedAt:  time.Now(),
+		Mismatches: []models.ReconciliationMismatch{},
+	}
+
+	// Non-terminal: charge or disbursement still pending, or a succeeded
+	// charge that never got a disbursement. Batched payments wait for their
+	// settlement instead, and held payouts for their approvers.
+	query := bson.M{
+		"created_at": bson.M{"$lte": report.StartedAt.Add(-minAge)},
+		"method":     bson.M{"$nin": offlineMethods}, // settled by admin review, not the provider
+		"$or": []bson.M{
+			{"status": "PENDING"},
+			{"status": "SUCCEEDED", "disbursement_id": "", "payout_mode": bson.M{"$ne": "BATCH"}, "approval_status": bson.M{"$nin": heldApprovalStatuses}},
+		},
+	}
+
+	findCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cur, err := s.db.Collection("payments").Find(findCtx, query, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		log.Printf("Failed to fetch payments for reconciliation: %v", err)
+		return nil, fmt.Errorf("failed to fetch payments: %v", err)
+	}
+	var payments []models.Payment
+	if err := cur.All(findCtx, &payments); err != nil {
+		log.Printf("Failed to decode payments for reconciliation: %v", err)
+		return nil, fmt.Errorf("failed to decode payments: %v", err)
+	}
+
+	for i := range payments {
+		report.Checked++
+		mismatch := s.reconcilePayment(ctx, &payments[i])
+		if mismatch == nil {
+			continue
+		}
+		if mismatch.Error == "" && mismatch.Action != "manual_review" {
+			report.Fixed++
+		}
+		report.Mismatches = append(report.Mismatches, *mismatch)
+	}
+	refunds, err := s.unsubmittedRefunds(ctx, report.StartedAt.Add(-minAge))
+	if err != nil {
+		log.Printf("Failed to fetch refunds for reconciliation: %v", err)
+	}
+	for i := range refunds {
+		report.Checked++
+		mismatch := s.reconcileRefund(ctx, &refunds[i])
+		if mismatch.Error == "" {
+			report.Fixed++
+		}
+		report.Mismatches = append(report.Mismatches, *mismatch)
+	}
+	report.FinishedAt = time.Now()
+
+	saveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := s.db.Collection("reconciliation_reports").InsertOne(saveCtx, report); err != nil {
+		log.Printf("Failed to save reconciliation report: %v", err)
+		return report, fmt.Errorf("failed to save reconciliation report: %v", err)
+	}
+
+	log.Printf("Reconciliation finished: checked=%d, mismatches=%d, fixed=%d", report.Checked, len(report.Mismatches), report.Fixed)
+	return report, nil
+}
+
+// reconcilePayment checks one payment against Xendit and returns the mismatch
+// found, or nil when local and provider state agree.
+func (s *PaymentService) reconcilePayment(ctx context.Context, payment *models.Payment) *models.ReconciliationMismatch {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	// Charge succeeded but the disbursement was never created
+	if payment.Status == "SUCCEEDED" && payment.DisbursementID == "" {
+		mismatch := &models.ReconciliationMismatch{
+			PaymentID:      payment.ID,
+			Kind:           "disbursement",
+			LocalStatus:    payment.Status,
+			ProviderStatus: "MISSING",
+			Action:         "disbursed",
+		}
+		if err := s.CreateDisbursement(ctx, payment.ID); err != nil {
+			mismatch.Error = err.Error()
+		}
+		return mismatch
+	}
+
+	if payment.DisbursementID != "" {
+		var disResp struct {
+			Status string `json:"status"`
+		}
+		mismatch := &models.ReconciliationMismatch{
+			PaymentID:   payment.ID,
+			Kind:        "disbursement",
+			ProviderID:  payment.DisbursementID,
+			LocalStatus: payment.Status,
+		}
+		if err := xenditRequest(ctx, "GET", "/disbursements/"+url.PathEscape(payment.DisbursementID), nil, &disResp); err != nil {
+			log.Printf("Failed to fetch disbursement %s: %v", payment.DisbursementID, err)
+			mismatch.Action = "none"
+			mismatch.Error = err.Error()
+			return mismatch
+		}
+		mismatch.ProviderStatus = disResp.Status
+		switch disResp.Status {
+		case "PENDING":
+			return nil
+		case "COMPLETED", "SUCCEEDED":
+			mismatch.Action = "updated"
+			if _, err := s.applyDisbursementStatus(ctx, payment.DisbursementID, disResp.Status); err != nil {
+				mismatch.Error = err.Error()
+			}
+		default:
+			// Failed payouts need a person to fix the payee account and retry;
+			// settlements are returned to the queue meanwhile
+			mismatch.Action = "manual_review"
+			if disResp.Status == "FAILED" {
+				if _, err := s.applyDisbursementStatus(ctx, payment.DisbursementID, disResp.Status); err != nil {
+					mismatch.Error = err.Error()
+				}
+			}
+		}
+		return mismatch
+	}
+
+	mismatch := &models.ReconciliationMismatch{
+		PaymentID:   payment.ID,
+		Kind:        "charge",
+		ProviderID:  payment.ChargeID,
+		LocalStatus: payment.Status,
+	}
+	providerStatus, err := fetchChargeStatus(ctx, payment)
+	if err != nil {
+		log.Printf("Failed to fetch charge %s: %v", payment.ChargeID, err)
+		mismatch.Action = "none"
+		mismatch.Error = err.Error()
+		return mismatch
+	}
+	if providerStatus == payment.Status {
+		return nil
+	}
+	mismatch.ProviderStatus = providerStatus
+	changed, err := s.applyChargeStatus(ctx, payment, providerStatus)
+	switch {
+	case err != nil:
+		mismatch.Action = "updated"
+		mismatch.Error = err.Error()
+	case changed:
+		mismatch.Action = "updated"
+	default:
+		mismatch.Action = "manual_review"
+	}
+	return mismatch
+}
+
+// unsubmittedRefunds lists PENDING refunds created before cutoff that Xendit
+// never acknowledged, because the request to it failed without an answer.
+func (s *PaymentService) unsubmittedRefunds(ctx context.Context, cutoff time.Time) ([]models.Refund, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var refunds []models.Refund
+	err := findAll(ctx, s.db.Collection("refunds"), bson.M{
+		"status":     "PENDING",
+		"refund_id":  "",
+		"created_at": bson.M{"$lte": cutoff},
+	}, &refunds)
+	return refunds, err
+}
+
+// reconcileRefund submits an unacknowledged refund to Xendit again. The
+// idempotency key makes this safe when the first request did go through.
+func (s *PaymentService) reconcileRefund(ctx context.Context, refund *models.Refund) *models.ReconciliationMismatch {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	mismatch := &models.ReconciliationMismatch{
+		PaymentID:      refund.PaymentID,
+		Kind:           "refund",
+		ProviderID:     refund.ReferenceID,
+		LocalStatus:    refund.Status,
+		ProviderStatus: "MISSING",
+		Action:         "resubmitted",
+	}
+	payment, err := s.GetPaymentByID(ctx, refund.PaymentID)
+	if err != nil {
+		mismatch.Error = err.Error()
+		return mismatch
+	}
+	if err := s.submitRefund(ctx, payment, refund); err != nil {
+		log.Printf("Failed to resubmit refund %s: %v", refund.ID.Hex(), err)
+		mismatch.Error = err.Error()
+		if isXenditRejection(err) {
+			if stErr := s.applyRefundStatus(ctx, refund, "FAILED"); stErr != nil {
+				log.Printf("Failed to fail refund %s: %v", refund.ID.Hex(), stErr)
+			}
+		}
+	}
+	return mismatch
+}
+
+// StartReconciler runs Reconcile every interval until ctx is cancelled.
+func (s *PaymentService) StartReconciler(ctx context.Context, interval, minAge time.Duration) {
+	log.Printf("Starting payment reconciler: interval=%s, minAge=%s", interval, minAge)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Payment reconciler stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.Reconcile(ctx, minAge); err != nil {
+				log.Printf("Scheduled reconciliation failed: %v", err)
+			}
+		}
+	}
+}
+
+// GetReconciliationReports returns the most recent reconciliation reports.
+func (s *PaymentService) GetReconciliationReports(ctx context.Context, limit int64) ([]models.ReconciliationReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("reconciliation_reports").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"started_at": -1}).SetLimit(limit))
+	if err != nil {
+		log.Printf("Failed to fetch reconciliation reports: %v", err)
+		return nil, fmt.Errorf("failed to fetch reconciliation reports: %v", err)
+	}
+	var reports []models.ReconciliationReport
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &reports); err != nil {
+		log.Printf("Failed to decode reconciliation reports: %v", err)
+		return nil, fmt.Errorf("failed to decode reconciliation reports: %v", err)
+	}
+	return reports, nil
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// RefundPayment refunds part or all of a completed payment through Xendit.
+// A zero amount refunds whatever has not been refunded yet. The requested
+// amount is reserved on the payment first so concurrent refunds can never
+// exceed what was paid, and the refund is saved as PENDING before Xendit is
+// called so it is tracked whatever happens next. When Xendit cannot be
+// reached the refund is returned still PENDING, with the error on it.
+// Refundability depends on the charge only: a payment can be refunded while
+// its payout is in flight.
+func (s *PaymentService) RefundPayment(ctx context.Context, paymentID string, amount models.Money, reason, adminID string) (*models.Refund, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		log.Printf("Invalid input: refund reason is empty")
+		return nil, fmt.Errorf("reason cannot be empty")
+	}
+
+	payment, err := s.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !chargeSucceeded(payment) {
+		log.Printf("Cannot refund payment %s with status %s", paymentID, payment.Status)
+		return nil, fmt.Errorf("can only refund payment whose charge succeeded, current status is %s", payment.Status)
+	}
+	if payment.Method == models.MethodVirtualAccount {
+		return nil, fmt.Errorf("virtual account payments cannot be refunded through the provider")
+	}
+	if isOfflineMethod(payment.Method) {
+		return nil, fmt.Errorf("offline payments must be refunded by the treasurer directly")
+	}
+
+	refundable := payment.Amount.Centavos - payment.RefundReserved
+	if amount.Centavos == 0 {
+		amount = models.NewMoney(refundable, payment.Amount.Currency)
+	}
+	if amount.Currency != payment.Amount.Currency {
+		return nil, fmt.Errorf("refund currency %s does not match payment currency %s", amount.Currency, payment.Amount.Currency)
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("nothing left to refund on payment %s", paymentID)
+	}
+
+	// Reserve the amount; fails if another refund got there first
+	result, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{
+		"_id": paymentID,
+		"$and": []bson.M{
+			chargeSucceededFilter(),
+			{"$or": []bson.M{
+				{"refund_reserved": bson.M{"$exists": false}},
+				{"refund_reserved": bson.M{"$lte": payment.Amount.Centavos - amount.Centavos}},
+			}},
+		},
+	}, bson.M{"$inc": bson.M{"refund_reserved": amount.Centavos}})
+	if err != nil {
+		log.Printf("Failed to reserve refund on payment %s: %v", paymentID, err)
+		return nil, fmt.Errorf("failed to reserve refund: %v", err)
+	}
+	if result.ModifiedCount == 0 {
+		return nil, fmt.Errorf("refund of %s exceeds the refundable amount of %s", amount.String(), models.NewMoney(refundable, amount.Currency).String())
+	}
+
+	now := time.Now()
+	refund := &models.Refund{
+		ID:          primitive.NewObjectID(),
+		PaymentID:   paymentID,
+		ReferenceID: payment.ReferenceID + "-refund-" + primitive.NewObjectID().Hex(),
+		Amount:      amount,
+		Reason:      reason,
+		Status:      "PENDING",
+		RequestedBy: adminID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := s.db.Collection("refunds").InsertOne(ctx, refund); err != nil {
+		log.Printf("Failed to save refund for payment %s: %v", paymentID, err)
+		s.releaseRefundReservation(ctx, paymentID, amount)
+		return nil, fmt.Errorf("failed to save refund: %v", err)
+	}
+
+	if err := s.submitRefund(ctx, payment, refund); err != nil {
+		log.Printf("Refund request for payment %s failed: %v", paymentID, err)
+		refund.Error = err.Error()
+		if _, updErr := s.db.Collection("refunds").UpdateOne(ctx, bson.M{"_id": refund.ID}, bson.M{"$set": bson.M{"error": refund.Error}}); updErr != nil {
+			log.Printf("Failed to record error on refund %s: %v", refund.ID.Hex(), updErr)
+		}
+		// Xendit may have taken the refund if it never answered; it stays
+		// PENDING for the webhook, or for the reconciler to resubmit
+		if !isXenditRejection(err) {
+			return refund, nil
+		}
+		if stErr := s.applyRefundStatus(ctx, refund, "FAILED"); stErr != nil {
+			log.Printf("Failed to fail refund %s: %v", refund.ID.Hex(), stErr)
+		}
+		return nil, fmt.Errorf("refund failed: %v", err)
+	}
+	return refund, nil
+}
+
+// submitRefund sends a saved refund to Xendit and applies the status it
+// comes back with. The refund's ID is the idempotency key, so submitting the
+// same refund again never refunds twice.
+func (s *PaymentService) submitRefund(ctx context.Context, payment *models.Payment, refund *models.Refund) error {
+	refundReq := map[string]interface{}{
+		"reference_id":       refund.ReferenceID,
+		"payment_request_id": payment.ChargeID,
+		"amount":             jsonAmount(refund.Amount),
+		"currency":           refund.Amount.Currency,
+		"reason":             "OTHERS",
+		"metadata":           map[string]interface{}{"payment_id": payment.ID, "reason": refund.Reason},
+	}
+	var refundResp struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	headers := map[string]string{"X-IDEMPOTENCY-KEY": refund.ID.Hex()}
+	if err := xenditRequestWithHeaders(ctx, "POST", "/refunds", headers, refundReq, &refundResp); err != nil {
+		return err
+	}
+	refund.RefundID = refundResp.ID
+
+	// Webhooks also find the refund by reference_id, so it is tracked even if this fails
+	if _, err := s.db.Collection("refunds").UpdateOne(ctx, bson.M{"_id": refund.ID}, bson.M{
+		"$set":   bson.M{"refund_id": refund.RefundID},
+		"$unset": bson.M{"error": ""},
+	}); err != nil {
+		log.Printf("Failed to save Xendit ID %s on refund %s: %v", refund.RefundID, refund.ID.Hex(), err)
+	}
+	refund.Error = ""
+	log.Printf("Refund created: ID=%s, PaymentID=%s, Amount=%s, Status=%s", refund.RefundID, payment.ID, refund.Amount.String(), refundResp.Status)
+
+	// Xendit has the refund now; if this fails the webhook applies it again
+	if refundResp.Status == "SUCCEEDED" || refundResp.Status == "FAILED" {
+		if err := s.applyRefundStatus(ctx, refund, refundResp.Status); err != nil {
+			log.Printf("Failed to apply status %s to refund %s: %v", refundResp.Status, refund.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// releaseRefundReservation returns a refund's reserved amount to the payment.
+func (s *PaymentService) releaseRefundReservation(ctx context.Context, paymentID string, amount models.Money) error {
+	_, err := s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": paymentID}, bson.M{
+		"$inc": bson.M{"refund_reserved": -amount.Centavos},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		log.Printf("Failed to release refund reservation on payment %s: %v", paymentID, err)
+	}
+	return err
+}
+
+// applyRefundStatus settles a PENDING refund. Succeeded refunds are added to
+// the payment's refunded amount and set its refund_status to REFUNDED or
+// PARTIALLY_REFUNDED, leaving status to the charge and payout; failed refunds
+// release their reservation.
+func (s *PaymentService) applyRefundStatus(ctx context.Context, refund *models.Refund, status string) error {
+	if status != "SUCCEEDED" && status != "FAILED" {
+		log.Printf("Received status %s for refund %s, no action taken", status, refund.RefundID)
+		return nil
+	}
+
+	now := time.Now()
+	result, err := s.db.Collection("refunds").UpdateOne(ctx, bson.M{"_id": refund.ID, "status": "PENDING"}, bson.M{
+		"$set": bson.M{"status": status, "updated_at": now},
+	})
+	if err != nil {
+		log.Printf("Failed to update refund %s: %v", refund.RefundID, err)
+		return fmt.Errorf("failed to update refund: %v", err)
+	}
+	if result.ModifiedCount == 0 {
+		log.Printf("Refund %s already settled, ignoring status %s", refund.RefundID, status)
+		return nil
+	}
+	refund.Status = status
+	refund.UpdatedAt = now
+
+	if status == "FAILED" {
+		if err := s.releaseRefundReservation(ctx, refund.PaymentID, refund.Amount); err != nil {
+			return fmt.Errorf("failed to update payment: %v", err)
+		}
+		log.Printf("Refund %s failed, released %s on payment %s", refund.RefundID, refund.Amount.String(), refund.PaymentID)
+		return nil
+	}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"refunded_amount": bson.M{
+				"centavos": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$refunded_amount.centavos", 0}}, refund.Amount.Centavos}},
+				"currency": refund.Amount.Currency,
+			},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"refund_status": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$refunded_amount.centavos", "$amount.centavos"}},
+				"REFUNDED",
+				"PARTIALLY_REFUNDED",
+			}},
+			"updated_at": now,
+		}}},
+	}
+	var payment models.Payment
+	err = s.db.Collection("payments").FindOneAndUpdate(ctx, bson.M{"_id": refund.PaymentID}, update).Decode(&payment)
+	if err != nil {
+		log.Printf("Failed to record refund %s on payment %s: %v", refund.RefundID, refund.PaymentID, err)
+		return fmt.Errorf("failed to update payment: %v", err)
+	}
+	recordLedger(ctx, s.db, refundLedger(&payment, refund))
+	log.Printf("Refund %s succeeded for payment %s: %s", refund.RefundID, refund.PaymentID, refund.Amount.String())
+	s.publishPayments(ctx, bson.M{"_id": refund.PaymentID})
+	if refunded, err := s.GetPaymentByID(ctx, refund.PaymentID); err == nil {
+		emitWebhook(ctx, s.db, models.EventPaymentRefunded, bson.M{"payment": refunded, "refund": refund})
+	}
+	return nil
+}
+
+// handleRefundWebhook applies a refund status reported by Xendit. The refund
+// is matched on its Xendit ID or, if that was never saved, its reference ID.
+func (s *PaymentService) handleRefundWebhook(ctx context.Context, refundID, referenceID, status string) error {
+	filter := bson.M{"refund_id": refundID}
+	if referenceID != "" {
+		filter = bson.M{"$or": []bson.M{{"refund_id": refundID}, {"reference_id": referenceID}}}
+	}
+	var refund models.Refund
+	if err := s.db.Collection("refunds").FindOne(ctx, filter).Decode(&refund); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Printf("Refund not found for ID %s", refundID)
+			return fmt.Errorf("refund not found for ID %s", refundID)
+		}
+		log.Printf("Failed to fetch refund %s: %v", refundID, err)
+		return fmt.Errorf("failed to fetch refund %s: %v", refundID, err)
+	}
+	return s.applyRefundStatus(ctx, &refund, status)
+}
+
+// GetRefunds lists the refunds made against a payment, newest first.
+func (s *PaymentService) GetRefunds(ctx context.Context, paymentID string) ([]models.Refund, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cur, err := s.db.Collection("refunds").Find(ctx, bson.M{"payment_id": paymentID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		log.Printf("Failed to fetch refunds for payment %s: %v", paymentID, err)
+		return nil, fmt.Errorf("failed to fetch refunds: %v", err)
+	}
+	refunds := []models.Refund{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &refunds); err != nil {
+		log.Printf("Failed to decode refunds for payment %s: %v", paymentID, err)
+		return nil, fmt.Errorf("failed to decode refunds: %v", err)
+	}
+	return refunds, nil
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// reportTimeZone is the zone days, weeks and months are counted in.
+const reportTimeZone = "Asia/Manila"
+
+// ReportFilter narrows a report. Zero fields match everything.
+type ReportFilter struct {
+	CollectionID string
+	PayeeID      string
+	From         *time.Time // Received at or after
+	To           *time.Time // Received before
+}
+
+// reportPeriods maps report periods to $dateTrunc units.
+var reportPeriods = map[string]string{
+	"daily":   "day",
+	"weekly":  "week",
+	"monthly": "month",
+}
+
+// receivedPayments are pipeline stages matching payments whose money came in
+// within the filter's range. A payment counts from when it was paid, or
+// when it was created if it predates paid_at.
+func (s *PaymentService) receivedPayments(ctx context.Context, f ReportFilter) ([]bson.M, error) {
+	query, err := s.paymentFilterQuery(ctx, PaymentFilter{CollectionID: f.CollectionID, PayeeID: f.PayeeID})
+	if err != nil {
+		return nil, err
+	}
+	// After a disbursement the status follows the payout, as in chargeSucceeded
+	query["$or"] = bson.A{
+		bson.M{"status": bson.M{"$in": bson.A{"SUCCEEDED", "REFUNDED", "PARTIALLY_REFUNDED"}}},
+		bson.M{"disbursement_id": bson.M{"$nin": bson.A{nil, ""}}},
+	}
+	stages := []bson.M{
+		{"$match": query},
+		{"$addFields": bson.M{"received_at": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$paid_at", time.Unix(0, 0)}}, "$paid_at", "$created_at",
+		}}}},
+	}
+	if f.From != nil || f.To != nil {
+		received := bson.M{}
+		if f.From != nil {
+			received["$gte"] = *f.From
+		}
+		if f.To != nil {
+			received["$lt"] = *f.To
+		}
+		stages = append(stages, bson.M{"$match": bson.M{"received_at": received}})
+	}
+	return stages, nil
+}
+
+// openObligations matches the unpaid obligations a report covers.
+func openObligations(f ReportFilter) bson.M {
+	query := bson.M{"status": bson.M{"$in": bson.A{"UNPAID", "PARTIALLY_PAID"}}}
+	if f.CollectionID != "" {
+		query["collection_id"] = f.CollectionID
+	}
+	if f.PayeeID != "" {
+		query["payee_id"] = f.PayeeID
+	}
+	return query
+}
+
+// aggregateAll runs a pipeline and decodes every result into out.
+func aggregateAll(ctx context.Context, coll *mongo.Collection, pipeline []bson.M, out interface{}) error {
+	cur, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	return cur.All(ctx, out)
+}
+
+// share returns part/whole, or 0 when whole is zero.
+func share(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
+
+// GetReportSummary totals what came in, went back and was paid out over the
+// filter's range, plus what is still owed.
+func (s *PaymentService) GetReportSummary(ctx context.Context, f ReportFilter) (*models.ReportSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	pipeline, err := s.receivedPayments(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pipeline = append(pipeline, bson.M{"$group": bson.M{
+		"_id":           nil,
+		"payments":      bson.M{"$sum": 1},
+		"collected":     bson.M{"$sum": "$amount.centavos"},
+		"refunded":      bson.M{"$sum": bson.M{"$ifNull": bson.A{"$refunded_amount.centavos", 0}}},
+		"gateway_fees":  bson.M{"$sum": bson.M{"$ifNull": bson.A{"$fees.gateway_fee.centavos", 0}}},
+		"platform_fees": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$fees.platform_fee.centavos", 0}}},
+		"disbursed": bson.M{"$sum": bson.M{"$cond": bson.A{
+			bson.M{"$and": bson.A{
+				bson.M{"$eq": bson.A{"$status", "SUCCEEDED"}},
+				bson.M{"$gt": bson.A{bson.M{"$ifNull": bson.A{"$disbursement_id", ""}}, ""}},
+			}},
+			bson.M{"$ifNull": bson.A{"$fees.net_amount.centavos", "$amount.centavos"}},
+			0,
+		}}},
+	}})
+	var totals []struct {
+		Payments     int   `bson:"payments"`
+		Collected    int64 `bson:"collected"`
+		Refunded     int64 `bson:"refunded"`
+		GatewayFees  int64 `bson:"gateway_fees"`
+		PlatformFees int64 `bson:"platform_fees"`
+		Disbursed    int64 `bson:"disbursed"`
+	}
+	if err := aggregateAll(ctx, s.db.Collection("payments"), pipeline, &totals); err != nil {
+		log.Printf("Failed to total payments for report: %v", err)
+		return nil, fmt.Errorf("failed to total payments: %v", err)
+	}
+
+	var owed []struct {
+		Outstanding int64 `bson:"outstanding"`
+		Overdue     int64 `bson:"overdue"`
+	}
+	err = aggregateAll(ctx, s.db.Collection("obligations"), []bson.M{
+		{"$match": openObligations(f)},
+		{"$group": bson.M{
+			"_id":         nil,
+			"outstanding": bson.M{"$sum": balanceExpr()},
+			"overdue": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$lt": bson.A{"$due_date", time.Now()}}, balanceExpr(), 0,
+			}}},
+		}},
+	}, &owed)
+	if err != nil {
+		log.Printf("Failed to total obligations for report: %v", err)
+		return nil, fmt.Errorf("failed to total obligations: %v", err)
+	}
+
+	summary := &models.ReportSummary{From: f.From, To: f.To}
+	if len(totals) > 0 {
+		t := totals[0]
+		summary.Payments = t.Payments
+		summary.Collected = models.NewMoney(t.Collected, "PHP")
+		summary.Refunded = models.NewMoney(t.Refunded, "PHP")
+		summary.Net = models.NewMoney(t.Collected-t.Refunded, "PHP")
+		summary.GatewayFees = models.NewMoney(t.GatewayFees, "PHP")
+		summary.PlatformFees = models.NewMoney(t.PlatformFees, "PHP")
+		summary.Disbursed = models.NewMoney(t.Disbursed, "PHP")
+	} else {
+		zero := models.NewMoney(0, "PHP")
+		summary.Collected, summary.Refunded, summary.Net = zero, zero, zero
+		summary.GatewayFees, summary.PlatformFees, summary.Disbursed = zero, zero, zero
+	}
+	summary.Outstanding = models.NewMoney(0, "PHP")
+	summary.Overdue = models.NewMoney(0, "PHP")
+	if len(owed) > 0 {
+		summary.Outstanding.Centavos = owed[0].Outstanding
+		summary.Overdue.Centavos = owed[0].Overdue
+	}
+	return summary, nil
+}
+
+// GetPeriodTotals totals what came in per day, week or month, counted in
+// Asia/Manila time. Weeks start on Monday. Periods with nothing are left out.
+func (s *PaymentService) GetPeriodTotals(ctx context.Context, f ReportFilter, period string) ([]models.PeriodTotal, error) {
+	unit, ok := reportPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("invalid period %q, must be daily, weekly or monthly", period)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	pipeline, err := s.receivedPayments(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{
+			"_id": bson.M{"$dateTrunc": bson.M{
+				"date":        "$received_at",
+				"unit":        unit,
+				"timezone":    reportTimeZone,
+				"startOfWeek": "monday",
+			}},
+			"payments":  bson.M{"$sum": 1},
+			"collected": bson.M{"$sum": "$amount.centavos"},
+			"refunded":  bson.M{"$sum": bson.M{"$ifNull": bson.A{"$refunded_amount.centavos", 0}}},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	)
+	var rows []struct {
+		Period    time.Time `bson:"_id"`
+		Payments  int       `bson:"payments"`
+		Collected int64     `bson:"collected"`
+		Refunded  int64     `bson:"refunded"`
+	}
+	if err := aggregateAll(ctx, s.db.Collection("payments"), pipeline, &rows); err != nil {
+		log.Printf("Failed to total payments by %s: %v", unit, err)
+		return nil, fmt.Errorf("failed to total payments: %v", err)
+	}
+
+	totals := make([]models.PeriodTotal, 0, len(rows))
+	for _, row := range rows {
+		totals = append(totals, models.PeriodTotal{
+			Period:    row.Period.In(manila),
+			Payments:  row.Payments,
+			Collected: models.NewMoney(row.Collected, "PHP"),
+			Refunded:  models.NewMoney(row.Refunded, "PHP"),
+			Net:       models.NewMoney(row.Collected-row.Refunded, "PHP"),
+		})
+	}
+	return totals, nil
+}
+
+// GetCollectionCompletion reports how many members have settled each
+// collection and how much is still owed, newest due date first.
+func (s *PaymentService) GetCollectionCompletion(ctx context.Context, f ReportFilter) ([]models.CollectionCompletion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	match := bson.M{}
+	if f.CollectionID != "" {
+		match["collection_id"] = f.CollectionID
+	}
+	if f.PayeeID != "" {
+		match["payee_id"] = f.PayeeID
+	}
+	count := func(status string) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", status}}, 1, 0}}}
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":       "$collection_id",
+			"members":   bson.M{"$sum": 1},
+			"paid":      count("PAID"),
+			"partial":   count("PARTIALLY_PAID"),
+			"unpaid":    count("UNPAID"),
+			"waived":    count("WAIVED"),
+			"billed":    bson.M{"$sum": bson.M{"$add": bson.A{"$amount.centavos", bson.M{"$ifNull": bson.A{"$penalties.centavos", 0}}}}},
+			"collected": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$amount_paid.centavos", 0}}},
+			"outstanding": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$status", bson.A{"UNPAID", "PARTIALLY_PAID"}}}, balanceExpr(), 0,
+			}}},
+		}},
+		{"$lookup": bson.M{
+			"from":     "collections",
+			"let":      bson.M{"collection": bson.M{"$convert": bson.M{"input": "$_id", "to": "objectId", "onError": nil}}},
+			"pipeline": []bson.M{{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$collection"}}}}},
+			"as":       "collection",
+		}},
+		{"$unwind": bson.M{"path": "$collection", "preserveNullAndEmptyArrays": true}},
+		{"$addFields": bson.M{"title": "$collection.title", "due_date": "$collection.due_date"}},
+		{"$project": bson.M{"collection": 0}},
+		{"$sort": bson.D{{Key: "due_date", Value: -1}, {Key: "_id", Value: 1}}},
+	}
+	var rows []struct {
+		CollectionID string    `bson:"_id"`
+		Title        string    `bson:"title"`
+		DueDate      time.Time `bson:"due_date"`
+		Members      int       `bson:"members"`
+		Paid         int       `bson:"paid"`
+		Partial      int       `bson:"partial"`
+		Unpaid       int       `bson:"unpaid"`
+		Waived       int       `bson:"waived"`
+		Billed       int64     `bson:"billed"`
+		Collected    int64     `bson:"collected"`
+		Outstanding  int64     `bson:"outstanding"`
+	}
+	if err := aggregateAll(ctx, s.db.Collection("obligations"), pipeline, &rows); err != nil {
+		log.Printf("Failed to report collection completion: %v", err)
+		return nil, fmt.Errorf("failed to report collection completion: %v", err)
+	}
+
+	report := make([]models.CollectionCompletion, 0, len(rows))
+	for _, row := range rows {
+		report = append(report, models.CollectionCompletion{
+			CollectionID:   row.CollectionID,
+			Title:          row.Title,
+			DueDate:        row.DueDate,
+			Members:        row.Members,
+			Paid:           row.Paid,
+			Partial:        row.Partial,
+			Unpaid:         row.Unpaid,
+			Waived:         row.Waived,
+			Billed:         models.NewMoney(row.Billed, "PHP"),
+			Collected:      models.NewMoney(row.Collected, "PHP"),
+			Outstanding:    models.NewMoney(row.Outstanding, "PHP"),
+			CompletionRate: share(int64(row.Paid+row.Waived), int64(row.Members)),
+		})
+	}
+	return report, nil
+}
+
+// GetTopDelinquents lists the members owing the most on overdue obligations.
+func (s *PaymentService) GetTopDelinquents(ctx context.Context, f ReportFilter, limit int64) ([]models.DelinquentMember, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	match := openObligations(f)
+	match["due_date"] = bson.M{"$lt": now}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":         "$member_id",
+			"obligations": bson.M{"$sum": 1},
+			"outstanding": bson.M{"$sum": balanceExpr()},
+			"oldest_due":  bson.M{"$min": "$due_date"},
+		}},
+		{"$sort": bson.D{{Key: "outstanding", Value: -1}, {Key: "oldest_due", Value: 1}}},
+		{"$limit": limit},
+		{"$lookup": bson.M{
+			"from":     "user",
+			"let":      bson.M{"member": bson.M{"$convert": bson.M{"input": "$_id", "to": "objectId", "onError": nil}}},
+			"pipeline": []bson.M{{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$member"}}}}},
+			"as":       "member",
+		}},
+		{"$unwind": bson.M{"path": "$member", "preserveNullAndEmptyArrays": true}},
+		{"$addFields": bson.M{"fullname": "$member.fullname", "email": "$member.email"}},
+		{"$project": bson.M{"member": 0}},
+	}
+	var rows []struct {
+		MemberID    string    `bson:"_id"`
+		FullName    string    `bson:"fullname"`
+		Email       string    `bson:"email"`
+		Obligations int       `bson:"obligations"`
+		Outstanding int64     `bson:"outstanding"`
+		OldestDue   time.Time `bson:"oldest_due"`
+	}
+	if err := aggregateAll(ctx, s.db.Collection("obligations"), pipeline, &rows); err != nil {
+		log.Printf("Failed to report delinquent members: %v", err)
+		return nil, fmt.Errorf("failed to report delinquent members: %v", err)
+	}
+
+	members := make([]models.DelinquentMember, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, models.DelinquentMember{
+			MemberID:    row.MemberID,
+			FullName:    row.FullName,
+			Email:       row.Email,
+			Obligations: row.Obligations,
+			Outstanding: models.NewMoney(row.Outstanding, "PHP"),
+			OldestDue:   row.OldestDue,
+			DaysOverdue: int(now.Sub(row.OldestDue).Hours() / 24),
+		})
+	}
+	return members, nil
+}
+
+// GetChannelBreakdown totals what came in through each payment method and
+// channel, largest first.
+func (s *PaymentService) GetChannelBreakdown(ctx context.Context, f ReportFilter) ([]models.ChannelTotal, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	pipeline, err := s.receivedPayments(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{
+			// Payments from before methods were added have none and are e-wallet
+			"_id": bson.M{
+				"method": bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$method", ""}}, ""}}, models.MethodEWallet, "$method",
+				}},
+				"channel_code": bson.M{"$ifNull": bson.A{"$channel_code", ""}},
+			},
+			"payments":  bson.M{"$sum": 1},
+			"collected": bson.M{"$sum": "$amount.centavos"},
+			"fees": bson.M{"$sum": bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$fees.gateway_fee.centavos", 0}},
+				bson.M{"$ifNull": bson.A{"$fees.platform_fee.centavos", 0}},
+			}}},
+		}},
+		bson.M{"$sort": bson.D{{Key: "collected", Value: -1}, {Key: "_id.method", Value: 1}, {Key: "_id.channel_code", Value: 1}}},
+	)
+	var rows []struct {
+		ID struct {
+			Method      string `bson:"method"`
+			ChannelCode string `bson:"channel_code"`
+		} `bson:"_id"`
+		Payments  int   `bson:"payments"`
+		Collected int64 `bson:"collected"`
+		Fees      int64 `bson:"fees"`
+	}
+	if err := aggregateAll(ctx, s.db.Collection("payments"), pipeline, &rows); err != nil {
+		log.Printf("Failed to report channel breakdown: %v", err)
+		return nil, fmt.Errorf("failed to report channel breakdown: %v", err)
+	}
+
+	total := int64(0)
+	for _, row := range rows {
+		total += row.Collected
+	}
+	channels := make([]models.ChannelTotal, 0, len(rows))
+	for _, row := range rows {
+		channels = append(channels, models.ChannelTotal{
+			Method:      row.ID.Method,
+			ChannelCode: row.ID.ChannelCode,
+			Payments:    row.Payments,
+			Collected:   models.NewMoney(row.Collected, "PHP"),
+			Fees:        models.NewMoney(row.Fees, "PHP"),
+			Share:       share(row.Collected, total),
+		})
+	}
+	return channels, nil
+}
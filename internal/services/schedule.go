@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression
+// (minute hour day-of-month month day-of-week). Fields accept *, numbers,
+// ranges (1-5), lists (1,15) and steps (*/6, 1-12/3).
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	domAny, dowAny                bool
+}
+
+// parseCron parses a five-field cron expression.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	sets := make([]map[int]bool, 5)
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %v", expr, err)
+		}
+		sets[i] = set
+	}
+	return &cronSchedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if base, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+			part = base
+		}
+		lo, hi := min, max
+		if part != "*" {
+			from, to, isRange := strings.Cut(part, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid range %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// dayMatches applies cron's rule that when both day fields are restricted a
+// day matching either one is enough.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domOK := c.dom[t.Day()]
+	dowOK := c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowOK
+	case c.dowAny:
+		return domOK
+	default:
+		return domOK || dowOK
+	}
+}
+
+// Next returns the first time strictly after t that matches the schedule, or
+// the zero time if none occurs within five years.
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// manila is the Philippine time zone that settlement windows follow.
+var manila = loadManila()
+
+// loadManila loads Asia/Manila, falling back to a fixed UTC+8 zone on hosts
+// without the tz database. The Philippines does not observe DST.
+func loadManila() *time.Location {
+	loc, err := time.LoadLocation("Asia/Manila")
+	if err != nil {
+		return time.FixedZone("PHT", 8*60*60)
+	}
+	return loc
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// PaymentFilter narrows a payment search. Zero fields match everything.
+type PaymentFilter struct {
+	Statuses     []string
+	PayerID      string
+	PayeeID      string
+	MinAmount    *models.Money
+	MaxAmount    *models.Money
+	Method       string
+	ChannelCode  string
+	CollectionID string
+	Title        string     // Case-insensitive substring of the title
+	From         *time.Time // Created at or after
+	To           *time.Time // Created before
+}
+
+// PaymentSearch is a filtered, sorted page request.
+type PaymentSearch struct {
+	PaymentFilter
+	Sort   string // "created_at" (default), "amount", "status" or "title"
+	Asc    bool
+	Cursor string // NextCursor of the previous page
+	Limit  int64
+}
+
+// paymentSortFields maps sort keys to the stored field they order by.
+var paymentSortFields = map[string]string{
+	"created_at": "created_at",
+	"amount":     "amount.centavos",
+	"status":     "status",
+	"title":      "title",
+}
+
+// paymentCursor is the sort value and ID of the last payment on a page.
+type paymentCursor struct {
+	Value interface{} `bson:"v"`
+	ID    string      `bson:"id"`
+}
+
+// paymentFilterQuery builds the Mongo filter for f.
+func (s *PaymentService) paymentFilterQuery(ctx context.Context, f PaymentFilter) (bson.M, error) {
+	query := bson.M{}
+	if len(f.Statuses) > 0 {
+		query["status"] = bson.M{"$in": f.Statuses}
+	}
+	if f.PayerID != "" {
+		query["payer_id"] = f.PayerID
+	}
+	if f.PayeeID != "" {
+		query["payee_id"] = f.PayeeID
+	}
+	if f.MinAmount != nil || f.MaxAmount != nil {
+		amount := bson.M{}
+		if f.MinAmount != nil {
+			amount["$gte"] = f.MinAmount.Centavos
+		}
+		if f.MaxAmount != nil {
+			amount["$lte"] = f.MaxAmount.Centavos
+		}
+		query["amount.centavos"] = amount
+	}
+	if f.Method != "" {
+		// Payments from before methods were added have none and are e-wallet
+		if f.Method == models.MethodEWallet {
+			query["method"] = bson.M{"$in": []interface{}{nil, "", models.MethodEWallet}}
+		} else {
+			query["method"] = f.Method
+		}
+	}
+	if f.ChannelCode != "" {
+		query["channel_code"] = f.ChannelCode
+	}
+	if f.Title != "" {
+		query["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(f.Title), Options: "i"}
+	}
+	if f.From != nil || f.To != nil {
+		created := bson.M{}
+		if f.From != nil {
+			created["$gte"] = *f.From
+		}
+		if f.To != nil {
+			created["$lt"] = *f.To
+		}
+		query["created_at"] = created
+	}
+	if f.CollectionID != "" {
+		ids, err := s.db.Collection("obligations").Distinct(ctx, "_id", bson.M{"collection_id": f.CollectionID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch collection obligations: %v", err)
+		}
+		obligationIDs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if oid, ok := id.(primitive.ObjectID); ok {
+				obligationIDs = append(obligationIDs, oid.Hex())
+			}
+		}
+		query["obligation_id"] = bson.M{"$in": obligationIDs}
+	}
+	return query, nil
+}
+
+// SearchPayments returns one page of payments matching the search, with the
+// total count and amount across all pages. Pages are keyed on the sort value
+// and ID of the last payment, so new payments do not shift later pages.
+func (s *PaymentService) SearchPayments(ctx context.Context, search PaymentSearch) (*models.PaymentPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	sortKey := search.Sort
+	if sortKey == "" {
+		sortKey = "created_at"
+	}
+	sortField, ok := paymentSortFields[sortKey]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort, must be created_at, amount, status or title")
+	}
+	if search.Limit <= 0 || search.Limit > 200 {
+		search.Limit = 50
+	}
+
+	query, err := s.paymentFilterQuery(ctx, search.PaymentFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.PaymentPage{Payments: []models.Payment{}, TotalAmount: models.NewMoney(0, "PHP")}
+	total, err := s.db.Collection("payments").CountDocuments(ctx, query)
+	if err != nil {
+		log.Printf("Failed to count payments: %v", err)
+		return nil, fmt.Errorf("failed to count payments: %v", err)
+	}
+	page.Total = total
+	sumCur, err := s.db.Collection("payments").Aggregate(ctx, []bson.M{
+		{"$match": query},
+		{"$group": bson.M{"_id": nil, "centavos": bson.M{"$sum": "$amount.centavos"}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum payments: %v", err)
+	}
+	var sums []struct {
+		Centavos int64 `bson:"centavos"`
+	}
+	if err := sumCur.All(ctx, &sums); err != nil {
+		return nil, fmt.Errorf("failed to sum payments: %v", err)
+	}
+	if len(sums) > 0 {
+		page.TotalAmount = models.NewMoney(sums[0].Centavos, "PHP")
+	}
+
+	order, cmp := -1, "$lt"
+	if search.Asc {
+		order, cmp = 1, "$gt"
+	}
+	if search.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(search.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		var cursor paymentCursor
+		if err := bson.Unmarshal(raw, &cursor); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		query = bson.M{"$and": []bson.M{query, {"$or": []bson.M{
+			{sortField: bson.M{cmp: cursor.Value}},
+			{sortField: cursor.Value, "_id": bson.M{cmp: cursor.ID}},
+		}}}}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: order}, {Key: "_id", Value: order}}).
+		SetLimit(search.Limit + 1)
+	cur, err := s.db.Collection("payments").Find(ctx, query, opts)
+	if err != nil {
+		log.Printf("Failed to search payments: %v", err)
+		return nil, fmt.Errorf("failed to search payments: %v", err)
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &page.Payments); err != nil {
+		return nil, fmt.Errorf("failed to decode payments: %v", err)
+	}
+
+	if int64(len(page.Payments)) > search.Limit {
+		page.Payments = page.Payments[:search.Limit]
+		last := page.Payments[len(page.Payments)-1]
+		var value interface{}
+		switch sortKey {
+		case "amount":
+			value = last.Amount.Centavos
+		case "status":
+			value = last.Status
+		case "title":
+			value = last.Title
+		default:
+			value = last.CreatedAt
+		}
+		raw, err := bson.Marshal(paymentCursor{Value: value, ID: last.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode cursor: %v", err)
+		}
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return page, nil
+}
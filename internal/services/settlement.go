@@ -0,0 +1,456 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// settlementWindowEnd returns the start of the current settlement window in
+// Manila time: midnight today for daily payouts, midnight last Monday for
+// weekly ones. Payments created before it are due to be settled.
+func settlementWindowEnd(schedule string, now time.Time) time.Time {
+	local := now.In(manila)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, manila)
+	if schedule == models.PayoutWeekly {
+		daysSinceMonday := (int(midnight.Weekday()) + 6) % 7
+		return midnight.AddDate(0, 0, -daysSinceMonday)
+	}
+	return midnight
+}
+
+// queueForSettlement holds a succeeded payment for its payee's next batched
+// payout when the payee settles daily or weekly. It reports whether the
+// payment was queued instead of being disbursed right away.
+func (s *PaymentService) queueForSettlement(ctx context.Context, payment *models.Payment) (bool, error) {
+	payeeObjID, err := primitive.ObjectIDFromHex(payment.PayeeID)
+	if err != nil {
+		return false, fmt.Errorf("invalid payee_id format: %v", err)
+	}
+	var payee models.User
+	if err := s.db.Collection("user").FindOne(ctx, bson.M{"_id": payeeObjID}).Decode(&payee); err != nil {
+		return false, fmt.Errorf("failed to fetch payee: %v", err)
+	}
+	if payee.PayoutSchedule != models.PayoutDaily && payee.PayoutSchedule != models.PayoutWeekly {
+		return false, nil
+	}
+	_, err = s.db.Collection("payments").UpdateOne(ctx, bson.M{"_id": payment.ID}, bson.M{
+		"$set": bson.M{"payout_mode": "BATCH", "updated_at": time.Now()},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to queue payment for settlement: %v", err)
+	}
+	payment.PayoutMode = "BATCH"
+	log.Printf("Payment %s queued for %s settlement to payee %s", payment.ID, payee.PayoutSchedule, payment.PayeeID)
+	return true, nil
+}
+
+// unsettledFilter matches queued payments that no settlement has claimed yet.
+func unsettledFilter() bson.M {
+	return bson.M{
+		"status":          bson.M{"$in": []string{"SUCCEEDED", "PARTIALLY_REFUNDED"}},
+		"payout_mode":     "BATCH",
+		"disbursement_id": "",
+		"settlement_id":   bson.M{"$exists": false},
+	}
+}
+
+// RunSettlements pays out every payee whose settlement window has closed on
+// queued payments, after retrying settlements whose payout was left
+// unconfirmed. Payees who went back to instant payouts are settled in full.
+// It returns the number of settlements disbursed.
+func (s *PaymentService) RunSettlements(ctx context.Context) (int, error) {
+	findCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	payeeIDs, err := s.db.Collection("payments").Distinct(findCtx, "payee_id", unsettledFilter())
+	if err != nil {
+		log.Printf("Failed to find payees to settle: %v", err)
+		return 0, fmt.Errorf("failed to find payees to settle: %v", err)
+	}
+
+	now := time.Now()
+	settled := s.resumeSettlements(ctx, now)
+	for _, id := range payeeIDs {
+		payeeID, _ := id.(string)
+		settlement, err := s.settlePayee(ctx, payeeID, now)
+		if err != nil {
+			log.Printf("Failed to settle payee %s: %v", payeeID, err)
+			continue
+		}
+		if settlement != nil && (settlement.Status == "PENDING" || settlement.Status == "SUCCEEDED") {
+			settled++
+		}
+	}
+	if settled > 0 {
+		log.Printf("Disbursed %d settlements", settled)
+	}
+	return settled, nil
+}
+
+// settlementRetryAfter is how long a PROCESSING settlement is left alone
+// before resumeSettlements takes it for stuck rather than in progress.
+const settlementRetryAfter = time.Minute
+
+// resumeSettlements retries the payouts of settlements left PROCESSING,
+// typically because Xendit never answered. The payout reference is the
+// settlement ID, so a payout Xendit did make is handed back, not repeated.
+// It returns the number of settlements disbursed.
+func (s *PaymentService) resumeSettlements(ctx context.Context, now time.Time) int {
+	findCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var settlements []models.Settlement
+	err := findAll(findCtx, s.db.Collection("settlements"), bson.M{
+		"status":          "PROCESSING",
+		"disbursement_id": "",
+		"updated_at":      bson.M{"$lt": now.Add(-settlementRetryAfter)},
+	}, &settlements)
+	if err != nil {
+		log.Printf("Failed to find settlements to resume: %v", err)
+		return 0
+	}
+
+	settled := 0
+	for i := range settlements {
+		settlement := &settlements[i]
+		payeeObjID, err := primitive.ObjectIDFromHex(settlement.PayeeID)
+		if err != nil {
+			log.Printf("Invalid payee_id %s on settlement %s", settlement.PayeeID, settlement.ID.Hex())
+			continue
+		}
+		var payee models.User
+		if err := s.db.Collection("user").FindOne(ctx, bson.M{"_id": payeeObjID}).Decode(&payee); err != nil {
+			log.Printf("Failed to fetch payee %s for settlement %s: %v", settlement.PayeeID, settlement.ID.Hex(), err)
+			continue
+		}
+		log.Printf("Resuming payout of settlement %s", settlement.ID.Hex())
+		payCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		s.disburseSettlement(payCtx, settlement, &payee)
+		cancel()
+		if settlement.Status == "PENDING" || settlement.Status == "SUCCEEDED" {
+			settled++
+		}
+	}
+	return settled
+}
+
+// settlePayee claims a payee's queued payments from before the window end and
+// disburses their combined net as one payout. When Xendit rejects the payout
+// the payments are released for the next run. It returns nil when nothing is
+// due.
+func (s *PaymentService) settlePayee(ctx context.Context, payeeID string, now time.Time) (*models.Settlement, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	payeeObjID, err := primitive.ObjectIDFromHex(payeeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payee_id format: %v", err)
+	}
+	var payee models.User
+	if err := s.db.Collection("user").FindOne(ctx, bson.M{"_id": payeeObjID}).Decode(&payee); err != nil {
+		return nil, fmt.Errorf("failed to fetch payee: %v", err)
+	}
+	schedule := payee.PayoutSchedule
+	windowEnd := now
+	if schedule == models.PayoutDaily || schedule == models.PayoutWeekly {
+		windowEnd = settlementWindowEnd(schedule, now)
+	} else {
+		schedule = models.PayoutInstant
+	}
+
+	settlement := &models.Settlement{
+		ID:        primitive.NewObjectID(),
+		PayeeID:   payeeID,
+		Schedule:  schedule,
+		WindowEnd: windowEnd,
+		Status:    "PROCESSING",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Claim the payments first so a concurrent run cannot pay them twice
+	filter := unsettledFilter()
+	filter["payee_id"] = payeeID
+	filter["created_at"] = bson.M{"$lt": windowEnd}
+	claim, err := s.db.Collection("payments").UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{"settlement_id": settlement.ID.Hex()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim payments: %v", err)
+	}
+	if claim.ModifiedCount == 0 {
+		return nil, nil
+	}
+
+	cur, err := s.db.Collection("payments").Find(ctx, bson.M{"settlement_id": settlement.ID.Hex()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch claimed payments: %v", err)
+	}
+	var payments []models.Payment
+	if err := cur.All(ctx, &payments); err != nil {
+		return nil, fmt.Errorf("failed to decode claimed payments: %v", err)
+	}
+	var gross, net int64
+	currency := "PHP"
+	for i := range payments {
+		gross += payments[i].Amount.Centavos
+		// Refunds made while queued already came out of the payee's share
+		if owed := payoutAmount(&payments[i]).Centavos - payments[i].RefundedAmount.Centavos; owed > 0 {
+			net += owed
+		}
+		currency = payments[i].Amount.Currency
+		settlement.PaymentIDs = append(settlement.PaymentIDs, payments[i].ID)
+	}
+	settlement.Count = len(payments)
+	settlement.Gross = models.NewMoney(gross, currency)
+	settlement.Net = models.NewMoney(net, currency)
+	settlement.Fees = models.NewMoney(gross-net, currency)
+	if _, err := s.db.Collection("settlements").InsertOne(ctx, settlement); err != nil {
+		s.releaseSettlement(ctx, settlement.ID.Hex())
+		return nil, fmt.Errorf("failed to save settlement: %v", err)
+	}
+
+	// Everything was refunded, so there is nothing left to pay out
+	if net == 0 {
+		settlement.Status = "SUCCEEDED"
+		s.updateSettlement(ctx, settlement)
+		return settlement, nil
+	}
+
+	s.disburseSettlement(ctx, settlement, &payee)
+	return settlement, nil
+}
+
+// disburseSettlement sends a saved settlement's net to the payee, unless the
+// payout is held for approval. When Xendit rejects the payout the payments
+// are released for the next run. When the outcome is unknown the settlement
+// stays PROCESSING with its payments claimed, and resumeSettlements tries
+// again under the same reference.
+func (s *PaymentService) disburseSettlement(ctx context.Context, settlement *models.Settlement, payee *models.User) {
+	approval, err := s.holdPayout(ctx, payee, settlement.Net, settlement.ApprovalID, "", settlement.ID.Hex())
+	if err != nil {
+		// An earlier attempt may have reached Xendit, so keep the claim
+		log.Printf("Failed to check payout approval for settlement %s: %v", settlement.ID.Hex(), err)
+		settlement.Error = err.Error()
+		s.updateSettlement(ctx, settlement)
+		return
+	}
+	if approval != nil {
+		settlement.ApprovalID = approval.ID.Hex()
+		settlement.Status = "AWAITING_APPROVAL"
+		if approval.Status == "REJECTED" {
+			settlement.Status = "REJECTED"
+		}
+		s.updateSettlement(ctx, settlement)
+		return
+	}
+
+	description := fmt.Sprintf("NotiPay settlement of %d payments to %s", settlement.Count, settlement.WindowEnd.In(manila).Format("Jan 2, 2006"))
+	disResp, err := sendDisbursement(ctx, payee, "settlement-"+settlement.ID.Hex(), description, settlement.Net)
+	if err != nil {
+		settlement.Error = err.Error()
+		if isPayoutRejection(err) {
+			s.releaseSettlement(ctx, settlement.ID.Hex())
+			settlement.Status = "FAILED"
+		} else {
+			log.Printf("Payout of settlement %s has an unknown outcome, retrying on the next run: %v", settlement.ID.Hex(), err)
+		}
+		s.updateSettlement(ctx, settlement)
+		return
+	}
+
+	settlement.DisbursementID = disResp.ID
+	settlement.Error = ""
+	settlement.PayoutChannel = disResp.Channel
+	settlement.Status = disResp.Status
+	s.updateSettlement(ctx, settlement)
+	_, err = s.db.Collection("payments").UpdateMany(ctx, bson.M{"settlement_id": settlement.ID.Hex()}, bson.M{
+		"$set": bson.M{
+			"disbursement_id": disResp.ID,
+			"payout_channel":  disResp.Channel,
+			"updated_at":      time.Now(),
+		},
+	})
+	if err == nil {
+		// Partially refunded payments keep their refund status
+		_, err = s.db.Collection("payments").UpdateMany(ctx, bson.M{"settlement_id": settlement.ID.Hex(), "status": "SUCCEEDED"}, bson.M{
+			"$set": bson.M{"status": disResp.Status},
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to mark payments of settlement %s as disbursed: %v", settlement.ID.Hex(), err)
+	}
+	s.publishPayments(ctx, bson.M{"settlement_id": settlement.ID.Hex()})
+	if disResp.Status == "SUCCEEDED" {
+		emitDisbursedWebhooks(ctx, s.db, bson.M{"settlement_id": settlement.ID.Hex()})
+	}
+	recordLedger(ctx, s.db, models.LedgerTransaction{
+		Key:  "settlement:" + settlement.ID.Hex(),
+		Kind: "DISBURSEMENT",
+		Memo: description,
+		Entries: []models.LedgerEntry{
+			debit(payeePayable(settlement.PayeeID), settlement.Net),
+			credit(accountXenditClearing, settlement.Net),
+		},
+	})
+
+	log.Printf("Settlement %s disbursed: Payee=%s, Payments=%d, Net=%s, Status=%s", settlement.ID.Hex(), settlement.PayeeID, settlement.Count, settlement.Net.String(), settlement.Status)
+}
+
+// releaseSettlement returns a settlement's payments to the queue.
+func (s *PaymentService) releaseSettlement(ctx context.Context, settlementID string) {
+	_, err := s.db.Collection("payments").UpdateMany(ctx, bson.M{"settlement_id": settlementID, "disbursement_id": ""}, bson.M{
+		"$unset": bson.M{"settlement_id": ""},
+	})
+	if err != nil {
+		log.Printf("Failed to release payments of settlement %s: %v", settlementID, err)
+	}
+}
+
+// failSettlement records that Xendit failed a settlement's payout: the money
+// is back in clearing and the payments return to the queue. It reports
+// whether a payment was updated.
+func (s *PaymentService) failSettlement(ctx context.Context, settlement *models.Settlement) (bool, error) {
+	id := settlement.ID.Hex()
+	result, err := s.db.Collection("settlements").UpdateOne(ctx, bson.M{"_id": settlement.ID, "status": bson.M{"$ne": "FAILED"}}, bson.M{
+		"$set": bson.M{
+			"status":     "FAILED",
+			"error":      "payout failed at Xendit",
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to mark settlement %s as failed: %v", id, err)
+		return false, fmt.Errorf("failed to update settlement: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return false, nil
+	}
+
+	// Statuses that followed the payout go back to the succeeded charge;
+	// refunded payments keep theirs
+	_, err = s.db.Collection("payments").UpdateMany(ctx, bson.M{
+		"settlement_id":   id,
+		"disbursement_id": settlement.DisbursementID,
+		"status":          bson.M{"$in": []string{"PENDING", "SUCCEEDED"}},
+	}, bson.M{"$set": bson.M{"status": "SUCCEEDED"}})
+	if err == nil {
+		_, err = s.db.Collection("payments").UpdateMany(ctx, bson.M{"settlement_id": id, "disbursement_id": settlement.DisbursementID}, bson.M{
+			"$set":   bson.M{"disbursement_id": "", "updated_at": time.Now()},
+			"$unset": bson.M{"payout_channel": ""},
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to reset payments of settlement %s: %v", id, err)
+		return false, fmt.Errorf("failed to reset payments: %v", err)
+	}
+	s.releaseSettlement(ctx, id)
+	recordLedger(ctx, s.db, models.LedgerTransaction{
+		Key:  "settlement-failed:" + id,
+		Kind: "DISBURSEMENT",
+		Memo: "Failed settlement payout",
+		Entries: []models.LedgerEntry{
+			debit(accountXenditClearing, settlement.Net),
+			credit(payeePayable(settlement.PayeeID), settlement.Net),
+		},
+	})
+	s.publishPayments(ctx, bson.M{"_id": bson.M{"$in": settlement.PaymentIDs}})
+
+	log.Printf("Settlement %s payout failed, %d payments returned to the queue", id, settlement.Count)
+	return true, nil
+}
+
+// updateSettlement saves a settlement's payout outcome.
+func (s *PaymentService) updateSettlement(ctx context.Context, settlement *models.Settlement) {
+	settlement.UpdatedAt = time.Now()
+	_, err := s.db.Collection("settlements").UpdateOne(ctx, bson.M{"_id": settlement.ID}, bson.M{
+		"$set": bson.M{
+			"disbursement_id": settlement.DisbursementID,
+			"approval_id":     settlement.ApprovalID,
+			"payout_channel":  settlement.PayoutChannel,
+			"status":          settlement.Status,
+			"error":           settlement.Error,
+			"updated_at":      settlement.UpdatedAt,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to update settlement %s: %v", settlement.ID.Hex(), err)
+	}
+}
+
+// GetSettlements lists settlements, newest first, optionally for one payee.
+func (s *PaymentService) GetSettlements(ctx context.Context, payeeID string) ([]models.Settlement, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if payeeID != "" {
+		filter["payee_id"] = payeeID
+	}
+	cur, err := s.db.Collection("settlements").Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(100))
+	if err != nil {
+		log.Printf("Failed to fetch settlements: %v", err)
+		return nil, fmt.Errorf("failed to fetch settlements: %v", err)
+	}
+	settlements := []models.Settlement{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &settlements); err != nil {
+		return nil, fmt.Errorf("failed to decode settlements: %v", err)
+	}
+	return settlements, nil
+}
+
+// GetSettlementReport returns a settlement with the payments it included.
+func (s *PaymentService) GetSettlementReport(ctx context.Context, id string) (*models.SettlementReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settlement_id format: %v", err)
+	}
+	var report models.SettlementReport
+	if err := s.db.Collection("settlements").FindOne(ctx, bson.M{"_id": objID}).Decode(&report.Settlement); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("settlement not found")
+		}
+		return nil, fmt.Errorf("failed to fetch settlement: %v", err)
+	}
+	cur, err := s.db.Collection("payments").Find(ctx, bson.M{"_id": bson.M{"$in": report.PaymentIDs}}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch settlement payments: %v", err)
+	}
+	report.Payments = []models.Payment{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &report.Payments); err != nil {
+		return nil, fmt.Errorf("failed to decode settlement payments: %v", err)
+	}
+	return &report, nil
+}
+
+// StartSettlementScheduler runs RunSettlements every interval until ctx is cancelled.
+func (s *PaymentService) StartSettlementScheduler(ctx context.Context, interval time.Duration) {
+	log.Printf("Settlement scheduler started: interval=%s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Settlement scheduler stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.RunSettlements(ctx); err != nil {
+				log.Printf("Settlement run failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// adjustmentLabels name adjustment types on statements.
+var adjustmentLabels = map[string]string{
+	"WAIVE":    "Waiver",
+	"DISCOUNT": "Discount",
+	"CREDIT":   "Credit",
+}
+
+// findAll runs a query and decodes every match into out.
+func findAll(ctx context.Context, coll *mongo.Collection, filter bson.M, out interface{}) error {
+	cur, err := coll.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	return cur.All(ctx, out)
+}
+
+// GetStatement builds a member's account statement from their obligations,
+// the payments and adjustments made on them, and refunds of those payments.
+// Everything before from goes into the opening balance; a nil from starts
+// at zero with the member's whole history. Refunds are listed for reference
+// but leave the balance alone: refunding a payment does not reopen what it
+// paid off.
+func (s *PaymentService) GetStatement(ctx context.Context, memberID string, from *time.Time, to time.Time) (*models.Statement, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	member, err := lookupUser(ctx, s.db, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	var obligations []models.Obligation
+	if err := findAll(ctx, s.db.Collection("obligations"), bson.M{"member_id": memberID}, &obligations); err != nil {
+		log.Printf("Failed to fetch obligations for statement of %s: %v", memberID, err)
+		return nil, fmt.Errorf("failed to fetch obligations: %v", err)
+	}
+	var invoices []models.Invoice
+	if err := findAll(ctx, s.db.Collection("invoices"), bson.M{"member_id": memberID}, &invoices); err != nil {
+		return nil, fmt.Errorf("failed to fetch invoices: %v", err)
+	}
+	invoiceNumbers := make(map[string]string, len(invoices))
+	for _, inv := range invoices {
+		invoiceNumbers[inv.ID.Hex()] = inv.Number
+	}
+
+	obligationIDs := make([]string, 0, len(obligations))
+	for _, o := range obligations {
+		obligationIDs = append(obligationIDs, o.ID.Hex())
+	}
+	var payments []models.Payment
+	err = findAll(ctx, s.db.Collection("payments"), bson.M{
+		"obligation_id": bson.M{"$in": obligationIDs},
+		"$or": bson.A{
+			bson.M{"status": bson.M{"$in": bson.A{"SUCCEEDED", "REFUNDED", "PARTIALLY_REFUNDED"}}},
+			bson.M{"disbursement_id": bson.M{"$nin": bson.A{nil, ""}}},
+		},
+	}, &payments)
+	if err != nil {
+		log.Printf("Failed to fetch payments for statement of %s: %v", memberID, err)
+		return nil, fmt.Errorf("failed to fetch payments: %v", err)
+	}
+	var adjustments []models.Adjustment
+	if err := findAll(ctx, s.db.Collection("adjustments"), bson.M{"member_id": memberID}, &adjustments); err != nil {
+		return nil, fmt.Errorf("failed to fetch adjustments: %v", err)
+	}
+	paymentIDs := make([]string, 0, len(payments))
+	paymentsByID := make(map[string]*models.Payment, len(payments))
+	for i := range payments {
+		paymentIDs = append(paymentIDs, payments[i].ID)
+		paymentsByID[payments[i].ID] = &payments[i]
+	}
+	var refunds []models.Refund
+	if err := findAll(ctx, s.db.Collection("refunds"), bson.M{"payment_id": bson.M{"$in": paymentIDs}, "status": "SUCCEEDED"}, &refunds); err != nil {
+		return nil, fmt.Errorf("failed to fetch refunds: %v", err)
+	}
+
+	var entries []models.StatementEntry
+	for _, o := range obligations {
+		entries = append(entries, models.StatementEntry{
+			Date:         o.CreatedAt,
+			Type:         "OBLIGATION",
+			Description:  o.Title,
+			Reference:    invoiceNumbers[o.InvoiceID],
+			ObligationID: o.ID.Hex(),
+			Amount:       o.Amount,
+		})
+	}
+	for _, p := range payments {
+		date := paymentPaidAt(&p)
+		reference := p.ReceiptNumber
+		if reference == "" {
+			reference = p.ReferenceID
+		}
+		// Late fees and discounts are booked on the obligation when paid
+		if b := p.Breakdown; b != nil {
+			if b.Penalty.IsPositive() {
+				entries = append(entries, models.StatementEntry{
+					Date: date, Type: "LATE_FEE", Description: "Late fees on " + p.Title,
+					Reference: reference, ObligationID: p.ObligationID, PaymentID: p.ID, Amount: b.Penalty,
+				})
+			}
+			if b.Discount.IsPositive() {
+				entries = append(entries, models.StatementEntry{
+					Date: date, Type: "DISCOUNT", Description: "Early payment discount on " + p.Title,
+					Reference: reference, ObligationID: p.ObligationID, PaymentID: p.ID,
+					Amount: models.NewMoney(-b.Discount.Centavos, b.Discount.Currency),
+				})
+			}
+		}
+		credited := creditedAmount(&p)
+		entries = append(entries, models.StatementEntry{
+			Date: date, Type: "PAYMENT", Description: "Payment for " + p.Title,
+			Reference: reference, ObligationID: p.ObligationID, PaymentID: p.ID,
+			Amount: models.NewMoney(-credited.Centavos, credited.Currency),
+		})
+	}
+	for _, a := range adjustments {
+		entries = append(entries, models.StatementEntry{
+			Date:         a.CreatedAt,
+			Type:         "ADJUSTMENT",
+			Description:  fmt.Sprintf("%s: %s", adjustmentLabels[a.Type], a.Reason),
+			ObligationID: a.ObligationID,
+			Amount:       models.NewMoney(-a.Amount.Centavos, a.Amount.Currency),
+		})
+	}
+	for _, r := range refunds {
+		p := paymentsByID[r.PaymentID]
+		entries = append(entries, models.StatementEntry{
+			Date:         r.UpdatedAt,
+			Type:         "REFUND",
+			Description:  "Refund of payment for " + p.Title,
+			Reference:    r.ReferenceID,
+			ObligationID: p.ObligationID,
+			PaymentID:    p.ID,
+			Amount:       r.Amount,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+
+	statement := &models.Statement{
+		MemberID:       memberID,
+		FullName:       member.FullName,
+		Email:          member.Email,
+		From:           from,
+		To:             to,
+		OpeningBalance: models.NewMoney(0, "PHP"),
+		Charges:        models.NewMoney(0, "PHP"),
+		Credits:        models.NewMoney(0, "PHP"),
+		Entries:        []models.StatementEntry{},
+	}
+	balance := int64(0)
+	for _, e := range entries {
+		if !e.Date.Before(to) {
+			break
+		}
+		if e.Type != "REFUND" {
+			balance += e.Amount.Centavos
+		}
+		if from != nil && e.Date.Before(*from) {
+			statement.OpeningBalance.Centavos = balance
+			continue
+		}
+		switch {
+		case e.Type == "REFUND":
+			// Listed for reference only
+		case e.Amount.Centavos > 0:
+			statement.Charges.Centavos += e.Amount.Centavos
+		default:
+			statement.Credits.Centavos -= e.Amount.Centavos
+		}
+		e.Balance = models.NewMoney(balance, "PHP")
+		statement.Entries = append(statement.Entries, e)
+	}
+	statement.ClosingBalance = models.NewMoney(balance, "PHP")
+	return statement, nil
+}
+
+// RenderStatementPDF draws a statement as a PDF, continuing onto new pages
+// as needed.
+func RenderStatementPDF(st *models.Statement) []byte {
+	const (
+		colDesc    = pdfMargin + 72
+		colAmount  = pdfPageWidth - pdfMargin - 90
+		colBalance = pdfPageWidth - pdfMargin
+	)
+	pdf := newPDF()
+	y := drawOrganization(pdf, loadOrganization())
+	pdf.Text(pdfMargin, y, 14, true, "STATEMENT OF ACCOUNT")
+	y += 18
+	pdf.Text(pdfMargin, y, 10, false, st.FullName)
+	period := "Through " + st.To.Add(-time.Second).In(manila).Format("January 2, 2006")
+	if st.From != nil {
+		period = st.From.In(manila).Format("January 2, 2006") + " to " + st.To.Add(-time.Second).In(manila).Format("January 2, 2006")
+	}
+	pdf.TextRight(colBalance, y, 9, false, period)
+	y += 14
+	pdf.Text(pdfMargin, y, 9, false, st.Email)
+	y += 28
+
+	header := func() {
+		pdf.Text(pdfMargin, y, 9, true, "Date")
+		pdf.Text(colDesc, y, 9, true, "Description")
+		pdf.TextRight(colAmount, y, 9, true, "Amount")
+		pdf.TextRight(colBalance, y, 9, true, "Balance")
+		y += 6
+		pdf.Line(pdfMargin, y, colBalance, y)
+		y += 14
+	}
+	header()
+	pdf.Text(colDesc, y, 9, false, "Opening balance")
+	pdf.TextRight(colBalance, y, 9, false, st.OpeningBalance.String())
+	y += 14
+	for _, e := range st.Entries {
+		lines := pdfWrap(e.Description, 48)
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+		if y+float64(len(lines))*12 > pdfPageHeight-pdfMargin {
+			pdf.AddPage()
+			y = pdfMargin + 10
+			header()
+		}
+		pdf.Text(pdfMargin, y, 9, false, e.Date.In(manila).Format("Jan 2, 2006"))
+		pdf.TextRight(colAmount, y, 9, false, e.Amount.String())
+		pdf.TextRight(colBalance, y, 9, false, e.Balance.String())
+		for _, line := range lines {
+			pdf.Text(colDesc, y, 9, false, line)
+			y += 12
+		}
+		y += 2
+	}
+
+	if y+80 > pdfPageHeight-pdfMargin {
+		pdf.AddPage()
+		y = pdfMargin + 10
+	}
+	pdf.Line(pdfMargin, y, colBalance, y)
+	y += 18
+	for _, row := range []struct {
+		label string
+		m     models.Money
+		bold  bool
+	}{
+		{"Opening balance", st.OpeningBalance, false},
+		{"Charges", st.Charges, false},
+		{"Payments and credits", models.NewMoney(-st.Credits.Centavos, st.Credits.Currency), false},
+		{"Closing balance", st.ClosingBalance, true},
+	} {
+		pdf.Text(colAmount-120, y, 10, row.bold, row.label)
+		pdf.TextRight(colBalance, y, 10, row.bold, row.m.Currency+" "+row.m.String())
+		y += 16
+	}
+	return pdf.Bytes()
+}
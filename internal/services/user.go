@@ -3,7 +3,9 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
@@ -121,3 +123,72 @@ func (s *UserService) Login(ctx context.Context, email, password string) (*model
 
 	return &user, nil
 }
+
+// UpdatePayout sets where disbursements to a user are sent. The account is a
+// mobile number registered on the chosen e-wallet channel.
+func (s *UserService) UpdatePayout(ctx context.Context, id, channelCode, account string) (*models.User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	code, channel, err := lookupChannel(models.MethodEWallet, channelCode)
+	if err != nil {
+		return nil, err
+	}
+	normalized, err := normalizeMobileNumber(account)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s account number: %v", channel.Name, err)
+	}
+	// Store the local 09XXXXXXXXX form, as GCashNumber is
+	account = "0" + strings.TrimPrefix(normalized, "+63")
+
+	update := bson.M{
+		"$set": bson.M{
+			"payout_channel":    code,
+			"payout_account":    account,
+			"payout_updated_at": time.Now(),
+		},
+	}
+	after := options.After
+	var user models.User
+	err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update, &options.FindOneAndUpdateOptions{
+		ReturnDocument: &after,
+		Projection:     bson.M{"password": 0},
+	}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UpdatePayoutSchedule sets whether a payee is paid per charge or in daily or
+// weekly settlements. Payments already queued are settled on the next run.
+func (s *UserService) UpdatePayoutSchedule(ctx context.Context, id, schedule string) (*models.User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule = strings.ToUpper(strings.TrimSpace(schedule))
+	switch schedule {
+	case models.PayoutInstant, models.PayoutDaily, models.PayoutWeekly:
+	default:
+		return nil, fmt.Errorf("invalid payout schedule %q, must be INSTANT, DAILY or WEEKLY", schedule)
+	}
+
+	after := options.After
+	var user models.User
+	err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, bson.M{
+		"$set": bson.M{"payout_schedule": schedule},
+	}, &options.FindOneAndUpdateOptions{
+		ReturnDocument: &after,
+		Projection:     bson.M{"password": 0},
+	}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
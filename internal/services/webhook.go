@@ -0,0 +1,531 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/markjakearzadon/notipay-gobackend.git/internal/models"
+)
+
+// Outbound webhook delivery limits. A delivery is retried with doubling
+// delays from webhookFirstRetry up to webhookMaxRetry, and fails for good
+// after webhookMaxAttempts tries (about four hours in all).
+const (
+	webhookMaxAttempts = 10
+	webhookFirstRetry  = 30 * time.Second
+	webhookMaxRetry    = 2 * time.Hour
+	webhookTimeout     = 10 * time.Second
+	webhookLease       = 2 * time.Minute // How long a claimed delivery is hidden from other dispatchers
+)
+
+// webhookKick wakes the dispatcher when an event is queued, so deliveries
+// go out without waiting for the next tick.
+var webhookKick = make(chan struct{}, 1)
+
+type WebhookService struct {
+	db     *mongo.Database
+	client *http.Client
+}
+
+func NewWebhookService(db *mongo.Database) *WebhookService {
+	_, err := db.Collection("webhook_deliveries").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "endpoint_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		log.Fatalf("error creating index for webhook deliveries: %v", err)
+	}
+	return &WebhookService{db: db, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// webhookPayload is the JSON body sent to endpoints.
+type webhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// emitWebhook queues event for every active endpoint subscribed to it.
+// Failures are logged; they never fail the change that raised the event.
+func emitWebhook(ctx context.Context, db *mongo.Database, event string, data interface{}) {
+	cur, err := db.Collection("webhook_endpoints").Find(ctx, bson.M{
+		"active": true,
+		"events": bson.M{"$in": bson.A{event, "*"}},
+	})
+	if err != nil {
+		log.Printf("Failed to find webhook endpoints for %s: %v", event, err)
+		return
+	}
+	var endpoints []models.WebhookEndpoint
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &endpoints); err != nil {
+		log.Printf("Failed to decode webhook endpoints for %s: %v", event, err)
+		return
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+	queueWebhook(ctx, db, endpoints, event, data)
+}
+
+// queueWebhook stores one delivery of the event per endpoint.
+func queueWebhook(ctx context.Context, db *mongo.Database, endpoints []models.WebhookEndpoint, event string, data interface{}) {
+	now := time.Now()
+	eventID := "evt_" + primitive.NewObjectID().Hex()
+	body, err := json.Marshal(webhookPayload{ID: eventID, Event: event, CreatedAt: now, Data: data})
+	if err != nil {
+		log.Printf("Failed to encode %s webhook: %v", event, err)
+		return
+	}
+
+	docs := make([]interface{}, 0, len(endpoints))
+	for _, e := range endpoints {
+		docs = append(docs, models.WebhookDelivery{
+			ID:            primitive.NewObjectID(),
+			EndpointID:    e.ID.Hex(),
+			EventID:       eventID,
+			Event:         event,
+			Payload:       string(body),
+			Status:        "PENDING",
+			Attempts:      []models.WebhookAttempt{},
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if _, err := db.Collection("webhook_deliveries").InsertMany(ctx, docs); err != nil {
+		log.Printf("Failed to queue %s webhook: %v", event, err)
+		return
+	}
+	select {
+	case webhookKick <- struct{}{}:
+	default:
+	}
+}
+
+// emitPaymentWebhook queues a payment event with the payment as its data.
+func emitPaymentWebhook(ctx context.Context, db *mongo.Database, event string, payment *models.Payment) {
+	emitWebhook(ctx, db, event, bson.M{"payment": payment})
+}
+
+// emitDisbursedWebhooks queues payment.disbursed for the paid-out payments
+// matching filter.
+func emitDisbursedWebhooks(ctx context.Context, db *mongo.Database, filter bson.M) {
+	filter["status"] = "SUCCEEDED"
+	var payments []models.Payment
+	if err := findAll(ctx, db.Collection("payments"), filter, &payments); err != nil {
+		log.Printf("Failed to fetch disbursed payments for webhooks: %v", err)
+		return
+	}
+	for i := range payments {
+		emitPaymentWebhook(ctx, db, models.EventPaymentDisbursed, &payments[i])
+	}
+}
+
+// signWebhook returns the X-NotiPay-Signature header for body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">".
+// Receivers should recompute it with their secret and reject old timestamps.
+func signWebhook(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the delay before retrying after the given number of attempts.
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookFirstRetry
+	for i := 1; i < attempts && delay < webhookMaxRetry; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxRetry {
+		delay = webhookMaxRetry
+	}
+	return delay
+}
+
+// newWebhookSecret returns a random signing secret.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// validateWebhookEndpoint checks the URL and events of an endpoint.
+func validateWebhookEndpoint(rawURL string, events []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid url, must be an absolute http or https URL")
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("invalid events, subscribe to at least one")
+	}
+	for _, event := range events {
+		if event != "*" && !slices.Contains(models.WebhookEvents, event) {
+			return fmt.Errorf("invalid event %s, must be one of %s or *", event, strings.Join(models.WebhookEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// CreateEndpoint registers a webhook endpoint. The returned endpoint carries
+// its signing secret, which is not shown again.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	endpoint.URL = strings.TrimSpace(endpoint.URL)
+	if err := validateWebhookEndpoint(endpoint.URL, endpoint.Events); err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	endpoint.ID = primitive.NewObjectID()
+	endpoint.Secret = secret
+	endpoint.Active = true
+	endpoint.CreatedAt = now
+	endpoint.UpdatedAt = now
+	if _, err := s.db.Collection("webhook_endpoints").InsertOne(ctx, endpoint); err != nil {
+		log.Printf("Failed to save webhook endpoint: %v", err)
+		return nil, fmt.Errorf("failed to save webhook endpoint: %v", err)
+	}
+	log.Printf("Webhook endpoint registered: ID=%s, URL=%s, Events=%v, By=%s", endpoint.ID.Hex(), endpoint.URL, endpoint.Events, endpoint.CreatedBy)
+	return endpoint, nil
+}
+
+// GetEndpoints lists the registered endpoints without their secrets.
+func (s *WebhookService) GetEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	endpoints := []models.WebhookEndpoint{}
+	err := findAll(ctx, s.db.Collection("webhook_endpoints"), bson.M{}, &endpoints)
+	if err != nil {
+		log.Printf("Failed to fetch webhook endpoints: %v", err)
+		return nil, fmt.Errorf("failed to fetch webhook endpoints: %v", err)
+	}
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+	}
+	return endpoints, nil
+}
+
+// getEndpoint fetches an endpoint, secret included.
+func (s *WebhookService) getEndpoint(ctx context.Context, endpointID string) (*models.WebhookEndpoint, error) {
+	objID, err := primitive.ObjectIDFromHex(endpointID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook_id format: %v", err)
+	}
+	var endpoint models.WebhookEndpoint
+	if err := s.db.Collection("webhook_endpoints").FindOne(ctx, bson.M{"_id": objID}).Decode(&endpoint); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("webhook endpoint not found")
+		}
+		return nil, fmt.Errorf("failed to fetch webhook endpoint: %v", err)
+	}
+	return &endpoint, nil
+}
+
+// WebhookEndpointUpdate holds the fields to change on an endpoint. Nil
+// fields are left as they are.
+type WebhookEndpointUpdate struct {
+	URL         *string
+	Description *string
+	Events      []string
+	Active      *bool
+}
+
+// UpdateEndpoint changes an endpoint's URL, description, events or whether
+// it is active. Deliveries already queued go to the new URL.
+func (s *WebhookService) UpdateEndpoint(ctx context.Context, endpointID string, update WebhookEndpointUpdate) (*models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	endpoint, err := s.getEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if update.URL != nil {
+		endpoint.URL = strings.TrimSpace(*update.URL)
+	}
+	if update.Description != nil {
+		endpoint.Description = *update.Description
+	}
+	if update.Events != nil {
+		endpoint.Events = update.Events
+	}
+	if update.Active != nil {
+		endpoint.Active = *update.Active
+	}
+	if err := validateWebhookEndpoint(endpoint.URL, endpoint.Events); err != nil {
+		return nil, err
+	}
+	endpoint.UpdatedAt = time.Now()
+	_, err = s.db.Collection("webhook_endpoints").UpdateOne(ctx, bson.M{"_id": endpoint.ID}, bson.M{"$set": bson.M{
+		"url":         endpoint.URL,
+		"description": endpoint.Description,
+		"events":      endpoint.Events,
+		"active":      endpoint.Active,
+		"updated_at":  endpoint.UpdatedAt,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook endpoint: %v", err)
+	}
+	endpoint.Secret = ""
+	return endpoint, nil
+}
+
+// RotateSecret gives an endpoint a new signing secret and returns it.
+func (s *WebhookService) RotateSecret(ctx context.Context, endpointID string) (*models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	endpoint, err := s.getEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Secret, err = newWebhookSecret(); err != nil {
+		return nil, err
+	}
+	endpoint.UpdatedAt = time.Now()
+	_, err = s.db.Collection("webhook_endpoints").UpdateOne(ctx, bson.M{"_id": endpoint.ID}, bson.M{"$set": bson.M{
+		"secret":     endpoint.Secret,
+		"updated_at": endpoint.UpdatedAt,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate webhook secret: %v", err)
+	}
+	log.Printf("Webhook endpoint %s secret rotated", endpointID)
+	return endpoint, nil
+}
+
+// DeleteEndpoint removes an endpoint. Its pending deliveries fail and its
+// delivery log is kept.
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, endpointID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	endpoint, err := s.getEndpoint(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Collection("webhook_endpoints").DeleteOne(ctx, bson.M{"_id": endpoint.ID}); err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %v", err)
+	}
+	_, err = s.db.Collection("webhook_deliveries").UpdateMany(ctx, bson.M{"endpoint_id": endpointID, "status": "PENDING"}, bson.M{
+		"$set": bson.M{"status": "FAILED", "last_error": "endpoint deleted", "updated_at": time.Now()},
+	})
+	if err != nil {
+		log.Printf("Failed to cancel deliveries of deleted webhook endpoint %s: %v", endpointID, err)
+	}
+	log.Printf("Webhook endpoint %s deleted", endpointID)
+	return nil
+}
+
+// PingEndpoint queues a webhook.ping event to one endpoint to test it.
+func (s *WebhookService) PingEndpoint(ctx context.Context, endpointID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	endpoint, err := s.getEndpoint(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+	queueWebhook(ctx, s.db, []models.WebhookEndpoint{*endpoint}, models.EventWebhookPing, bson.M{"endpoint_id": endpointID})
+	return nil
+}
+
+// GetDeliveries lists an endpoint's deliveries, newest first, optionally
+// only those with the given status.
+func (s *WebhookService) GetDeliveries(ctx context.Context, endpointID, status string, limit int64) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	filter := bson.M{"endpoint_id": endpointID}
+	if status != "" {
+		filter["status"] = status
+	}
+	cur, err := s.db.Collection("webhook_deliveries").Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		log.Printf("Failed to fetch deliveries for webhook endpoint %s: %v", endpointID, err)
+		return nil, fmt.Errorf("failed to fetch webhook deliveries: %v", err)
+	}
+	deliveries := []models.WebhookDelivery{}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook deliveries: %v", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a finished delivery to be sent again now, keeping its
+// attempt log.
+func (s *WebhookService) Redeliver(ctx context.Context, deliveryID string) (*models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delivery_id format: %v", err)
+	}
+	now := time.Now()
+	var delivery models.WebhookDelivery
+	err = s.db.Collection("webhook_deliveries").FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "status": bson.M{"$ne": "PENDING"}},
+		bson.M{"$set": bson.M{"status": "PENDING", "next_attempt_at": now, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("delivery not found or already pending")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue delivery: %v", err)
+	}
+	select {
+	case webhookKick <- struct{}{}:
+	default:
+	}
+	return &delivery, nil
+}
+
+// DispatchWebhooks sends every delivery that is due and returns how many it
+// attempted. Each delivery is claimed first, so several servers can share
+// the queue.
+func (s *WebhookService) DispatchWebhooks(ctx context.Context) (int, error) {
+	attempted := 0
+	for ctx.Err() == nil {
+		now := time.Now()
+		var delivery models.WebhookDelivery
+		err := s.db.Collection("webhook_deliveries").FindOneAndUpdate(ctx,
+			bson.M{"status": "PENDING", "next_attempt_at": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"next_attempt_at": now.Add(webhookLease)}},
+			options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1}),
+		).Decode(&delivery)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			return attempted, fmt.Errorf("failed to claim webhook delivery: %v", err)
+		}
+		s.deliver(ctx, &delivery)
+		attempted++
+	}
+	return attempted, nil
+}
+
+// deliver makes one attempt at a claimed delivery and records the outcome.
+func (s *WebhookService) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	endpoint, err := s.getEndpoint(ctx, delivery.EndpointID)
+	if err == nil && !endpoint.Active {
+		err = fmt.Errorf("webhook endpoint is disabled")
+	}
+	if err != nil {
+		s.finishDelivery(ctx, delivery, "FAILED", models.WebhookAttempt{At: time.Now(), Error: err.Error()})
+		return
+	}
+
+	start := time.Now()
+	attempt := models.WebhookAttempt{At: start}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "NotiPay-Webhooks/1.0")
+		req.Header.Set("X-NotiPay-Event", delivery.Event)
+		req.Header.Set("X-NotiPay-Delivery", delivery.ID.Hex())
+		req.Header.Set("X-NotiPay-Signature", signWebhook(endpoint.Secret, start, []byte(delivery.Payload)))
+		var resp *http.Response
+		if resp, err = s.client.Do(req); err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			attempt.StatusCode = resp.StatusCode
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				err = fmt.Errorf("endpoint responded %s", resp.Status)
+			}
+		}
+	}
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err == nil {
+		s.finishDelivery(ctx, delivery, "SUCCEEDED", attempt)
+		return
+	}
+	attempt.Error = err.Error()
+	if len(delivery.Attempts)+1 >= webhookMaxAttempts {
+		log.Printf("Webhook delivery %s of %s to %s failed for good: %v", delivery.ID.Hex(), delivery.Event, endpoint.URL, err)
+		s.finishDelivery(ctx, delivery, "FAILED", attempt)
+		return
+	}
+	s.finishDelivery(ctx, delivery, "PENDING", attempt)
+}
+
+// finishDelivery logs an attempt on a delivery and sets its new status,
+// scheduling the next try when it is still PENDING.
+func (s *WebhookService) finishDelivery(ctx context.Context, delivery *models.WebhookDelivery, status string, attempt models.WebhookAttempt) {
+	now := time.Now()
+	set := bson.M{"status": status, "last_error": attempt.Error, "updated_at": now}
+	switch status {
+	case "SUCCEEDED":
+		set["delivered_at"] = now
+	case "PENDING":
+		set["next_attempt_at"] = now.Add(webhookBackoff(len(delivery.Attempts) + 1))
+	}
+	_, err := s.db.Collection("webhook_deliveries").UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.M{
+		"$set":  set,
+		"$push": bson.M{"attempts": attempt},
+	})
+	if err != nil {
+		log.Printf("Failed to record attempt on webhook delivery %s: %v", delivery.ID.Hex(), err)
+	}
+}
+
+// StartWebhookDispatcher runs DispatchWebhooks every interval, and as soon as
+// an event is queued, until ctx is cancelled.
+func (s *WebhookService) StartWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting webhook dispatcher: interval=%s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	dispatch := func() {
+		if _, err := s.DispatchWebhooks(ctx); err != nil {
+			log.Printf("Webhook dispatch failed: %v", err)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+			dispatch()
+		case <-webhookKick:
+			dispatch()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const xenditBaseURL = "https://api.xendit.co"
+
+// xenditError is a response from Xendit outside the 2xx range. Other request
+// failures, such as timeouts, leave it unknown whether Xendit acted.
+type xenditError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *xenditError) Error() string {
+	return fmt.Sprintf("xendit request %s %s failed with status %d: %s", e.Method, e.Path, e.StatusCode, e.Body)
+}
+
+// isXenditRejection reports whether Xendit definitively refused a request,
+// so it is safe to give up on it. Timeouts, conflicts with a request still in
+// flight, rate limits and server errors are not rejections.
+func isXenditRejection(err error) bool {
+	var xe *xenditError
+	if !errors.As(err, &xe) {
+		return false
+	}
+	switch xe.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return xe.StatusCode >= 400 && xe.StatusCode < 500
+}
+
+// isXenditNotFound reports whether Xendit answered 404.
+func isXenditNotFound(err error) bool {
+	var xe *xenditError
+	return errors.As(err, &xe) && xe.StatusCode == http.StatusNotFound
+}
+
+// xenditRequest sends an authenticated request to the Xendit API and decodes
+// the JSON response into out when it is not nil. A nil body sends no payload.
+func xenditRequest(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	return xenditRequestWithHeaders(ctx, method, path, nil, body, out)
+}
+
+// xenditRequestWithHeaders is xenditRequest with extra request headers, such
+// as an idempotency key or the API version an endpoint should use.
+func xenditRequestWithHeaders(ctx context.Context, method, path string, headers map[string]string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal xendit request: %v", err)
+		}
+		reader = bytes.NewBuffer(reqBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, xenditBaseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create xendit request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(os.Getenv("XENDIT_SECRET_KEY")+":")))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("xendit request %s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &xenditError{Method: method, Path: path, StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode xendit response: %v", err)
+	}
+	return nil
+}